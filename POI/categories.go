@@ -12,8 +12,8 @@ type PlaceCategory string
 const (
 	PlaceCategoryVisit  = PlaceCategory("Visit")
 	PlaceCategoryEatery = PlaceCategory("Eatery")
-	// Categories below back the merchant/best-card nearby endpoint. They are not used by
-	// trip planning, which only slots Visit/Eatery places.
+	// Categories below were added for the merchant/best-card nearby endpoint. Trip planning
+	// slots them like Visit/Eatery places, the standard plan template only uses Visit/Eatery.
 	PlaceCategoryShopping = PlaceCategory("Shopping")
 	PlaceCategoryLodging  = PlaceCategory("Lodging")
 	PlaceCategoryWellness = PlaceCategory("Wellness")
@@ -412,6 +412,10 @@ const (
 	StayingTimeLocationTypeGallery       = StayingTime(2)
 	StayingTimeLocationTypeAmusementPark = StayingTime(3)
 	StayingTimeLocationTypePark          = StayingTime(2)
+	StayingTimeLocationTypeShoppingMall  = StayingTime(2)
+	StayingTimeLocationTypeSpa           = StayingTime(2)
+	// StayingTimeDefault applies to location types without a specific staying time
+	StayingTimeDefault = StayingTime(1)
 )
 
 func GetStayingTimeForLocationType(locationType LocationType) StayingTime {
@@ -422,7 +426,12 @@ func GetStayingTimeForLocationType(locationType LocationType) StayingTime {
 		LocationTypeGallery:       StayingTimeLocationTypeGallery,
 		LocationTypeAmusementPark: StayingTimeLocationTypeAmusementPark,
 		LocationTypePark:          StayingTimeLocationTypePark,
+		LocationTypeShoppingMall:  StayingTimeLocationTypeShoppingMall,
+		LocationTypeSpa:           StayingTimeLocationTypeSpa,
 	}
 
-	return stayingTimeMap[locationType]
+	if stayingTime, exists := stayingTimeMap[locationType]; exists {
+		return stayingTime
+	}
+	return StayingTimeDefault
 }
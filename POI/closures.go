@@ -0,0 +1,92 @@
+package POI
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const ClosureDateLayout = "2006-01-02"
+
+// Closure closes a place, or the places of a country, on a date whatever their weekly opening hours, e.g. a museum
+// closed for an exhibition change or a public holiday
+type Closure struct {
+	Date    string `json:"date"`               // yyyy-mm-dd
+	Country string `json:"country,omitempty"`  // country of a holiday, empty for the closure of a place
+	PlaceID string `json:"place_id,omitempty"` // place closed on the date, empty for a holiday
+	// Categories are the place categories closed on a holiday, e.g. museums close on Christmas while restaurants
+	// stay open. A holiday without categories closes every place of the country.
+	Categories []PlaceCategory `json:"categories,omitempty"`
+	Reason     string          `json:"reason"`
+}
+
+// ID identifies a closure among the closures on its date
+func (c *Closure) ID() string {
+	if c.PlaceID != "" {
+		return "place:" + c.PlaceID
+	}
+	return "country:" + strings.ToLower(strings.TrimSpace(c.Country))
+}
+
+func (c *Closure) Validate() error {
+	if _, err := time.Parse(ClosureDateLayout, c.Date); err != nil {
+		return errors.New("closure date format must be yyyy-mm-dd")
+	}
+	if (c.PlaceID == "") == (strings.TrimSpace(c.Country) == "") {
+		return errors.New("a closure closes either a place or the places of a country")
+	}
+	if c.PlaceID != "" && len(c.Categories) > 0 {
+		return errors.New("only holidays of a country close place categories")
+	}
+	for _, category := range c.Categories {
+		if _, ok := ParsePlaceCategory(string(category)); !ok {
+			return fmt.Errorf("unknown place category %q, expect one of %v", category, AllPlaceCategories)
+		}
+	}
+	if strings.TrimSpace(c.Reason) == "" {
+		return errors.New("a closure needs a reason")
+	}
+	return nil
+}
+
+// Closes reports whether the closure closes a place of a category
+func (c *Closure) Closes(placeID string, category PlaceCategory) bool {
+	if c.PlaceID != "" {
+		return c.PlaceID == placeID
+	}
+	return len(c.Categories) == 0 || slices.Contains(c.Categories, category)
+}
+
+// Closures are the closures of the places at a destination on a travel date
+type Closures []Closure
+
+// ClosureOf returns the closure closing a place of a category, closures of the place take precedence over holidays
+func (closures Closures) ClosureOf(placeID string, category PlaceCategory) (Closure, bool) {
+	var holiday *Closure
+	for idx := range closures {
+		closure := &closures[idx]
+		if !closure.Closes(placeID, category) {
+			continue
+		}
+		if closure.PlaceID != "" {
+			return *closure, true
+		}
+		if holiday == nil {
+			holiday = closure
+		}
+	}
+	if holiday == nil {
+		return Closure{}, false
+	}
+	return *holiday, true
+}
+
+// Exclusion explains why a place that fits a slot of a plan was left out of the plan
+type Exclusion struct {
+	PlaceID   string `json:"place_id"`
+	PlaceName string `json:"place_name"`
+	Date      string `json:"date"`
+	Reason    string `json:"reason"`
+}
@@ -43,6 +43,16 @@ func (w Weekday) Name() string {
 	return mapping[w]
 }
 
+// Next returns the weekday after w
+func (w Weekday) Next() Weekday {
+	return (w + 1) % 7
+}
+
+// Previous returns the weekday before w
+func (w Weekday) Previous() Weekday {
+	return (w + 6) % 7
+}
+
 // WeekdayFromTime converts Go's time.Weekday (Sunday=0) to POI's Weekday (Monday=0)
 func WeekdayFromTime(day time.Weekday) Weekday {
 	return Weekday((int(day) + 6) % 7)
@@ -94,9 +104,23 @@ type Place struct {
 type Location struct {
 	Latitude          float64 `json:"latitude"`
 	Longitude         float64 `json:"longitude"`
-	City              string  `json:"city"`              // name of the city where the location belongs to
-	AdminAreaLevelOne string  `json:"adminAreaLevelOne"` // e.g. state names in the United States
-	Country           string  `json:"country"`           // name of the country where the location belongs to
+	City              string  `json:"city"`               // name of the city where the location belongs to
+	AdminAreaLevelOne string  `json:"adminAreaLevelOne"`  // e.g. state names in the United States
+	Country           string  `json:"country"`            // name of the country where the location belongs to
+	Timezone          string  `json:"timezone,omitempty"` // IANA name of the timezone at the location, e.g. Asia/Tokyo
+}
+
+// LocalTime converts a time to the time of day at the location.
+// The time is returned unchanged if the timezone of the location is unknown.
+func (l *Location) LocalTime(t time.Time) time.Time {
+	if l.Timezone == "" {
+		return t
+	}
+	tz, err := time.LoadLocation(l.Timezone)
+	if err != nil {
+		return t
+	}
+	return t.In(tz)
 }
 
 // String formalizes a location to a format with capitalized locality, followed by upper-cased admin area one and country names
@@ -175,6 +199,29 @@ func (place *Place) GetHour(day Weekday) string {
 	return place.Hours[day]
 }
 
+// OpenIntervals parses the hours of the place on the given weekday, see ParseOpenIntervals
+func (place *Place) OpenIntervals(day Weekday) (OpenIntervals, error) {
+	return ParseOpenIntervals(place.GetHour(day))
+}
+
+// OpenIntervalsAround returns the intervals the place is open from 0:00 of the given weekday to 24:00 of the next one,
+// the hours after midnight of the previous weekday included. Hours of the next weekday count from EndOfDay, so that a
+// night out running to 02:00 is checked against the hours of both days. Unparsable hours of the adjacent weekdays are
+// left out.
+func (place *Place) OpenIntervalsAround(day Weekday) (OpenIntervals, error) {
+	intervals, err := place.OpenIntervals(day)
+	if err != nil {
+		return nil, err
+	}
+	if previous, err := place.OpenIntervals(day.Previous()); err == nil {
+		intervals = append(intervals, previous.Shift(-int(EndOfDay))...)
+	}
+	if next, err := place.OpenIntervals(day.Next()); err == nil {
+		intervals = append(intervals, next.Shift(int(EndOfDay))...)
+	}
+	return intervals.Merge(), nil
+}
+
 // HasRealOpeningHours reports whether any weekday carries hours that came from source data
 // rather than the DefaultOpeningHours placeholder.
 func (place *Place) HasRealOpeningHours() bool {
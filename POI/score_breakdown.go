@@ -0,0 +1,23 @@
+package POI
+
+// PlaceScoreBreakdown explains the score of a single place of a plan. ReviewCountFactor and BoostFactor multiply to
+// PlaceScore for scorers built on the default formula and are zero for scorers that do not factor their place score.
+type PlaceScoreBreakdown struct {
+	PlaceID           string  `json:"place_id"`
+	PlaceScore        float64 `json:"place_score"`
+	ReviewCountFactor float64 `json:"review_count_factor"` // log10 of one plus the number of reviews
+	BoostFactor       float64 `json:"boost_factor"`        // rating to price ratio
+}
+
+// ScoreBreakdown explains the score of a plan, which is the average place score minus the travel penalty and the
+// anchor penalty. Travel from the start of the day and to its end, e.g. the hotel of the traveler, is part of the travel
+// penalty. DistancePenalty is the average straight-line distance between consecutive stops normalised by the search
+// radius; it is reported for comparison with the travel penalty but is not part of the score.
+type ScoreBreakdown struct {
+	Places            []PlaceScoreBreakdown `json:"places"`
+	AveragePlaceScore float64               `json:"average_place_score"`
+	TravelPenalty     float64               `json:"travel_penalty"`
+	AnchorPenalty     float64               `json:"anchor_penalty"` // distance to the places pinned by the user
+	DistancePenalty   float64               `json:"distance_penalty"`
+	Score             float64               `json:"score"`
+}
@@ -1,16 +1,25 @@
 package POI
 
 import (
-	"errors"
+	"encoding/json"
+	"fmt"
+	"math"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
-
-	"github.com/weihesdlegend/Vacation-planner/utils"
 )
 
-var closedRe = regexp.MustCompile(`.*\s+Closed$`)
-var hourRegex = regexp.MustCompile(`\d{1,2}:\d{2}`)
+// dayPrefixRe matches the weekday name opening the hours of a day, e.g. "Monday: " or "lundi : "
+var dayPrefixRe = regexp.MustCompile(`^[^\d:]*:\s*`)
+
+// clockTokenRe matches a time on the 12-hour or the 24-hour clock, e.g. "9", "9:30 AM", "21:30" or "9 p.m."
+var clockTokenRe = regexp.MustCompile(`^(\d{1,2})(?:[:.h](\d{2}))?\s*(am|pm|a\.m\.|p\.m\.)?$`)
+
+// closedRe and openAllDayRe match the hours of a day a place is closed and open around the clock respectively
+var closedRe = regexp.MustCompile(`\bclosed\b`)
+var openAllDayRe = regexp.MustCompile(`\b(open 24 hours|24 hours|24/7)\b`)
 
 type Hour uint8
 
@@ -18,9 +27,111 @@ func (h Hour) ToString() string {
 	return strconv.Itoa(int(h))
 }
 
+// ClockTime is a time of day in minutes after midnight
+type ClockTime uint16
+
+// nextDaySuffix marks a time at or after EndOfDay, e.g. "2:00+1" for 02:00 the next day
+const nextDaySuffix = "+1"
+
+const (
+	// EndOfDay is the midnight a day ends with, times after it are on the next day
+	EndOfDay = ClockTime(24 * 60)
+	// LatestSlotEnd is the latest end of a time slot, 02:00 the next day, so that a night out can run past midnight
+	LatestSlotEnd = ClockTime(26 * 60)
+)
+
+func NewClockTime(hour, minute int) ClockTime {
+	return ClockTime(hour*60 + minute)
+}
+
+func (t ClockTime) Hour() int {
+	return int(t) / 60
+}
+
+func (t ClockTime) Minute() int {
+	return int(t) % 60
+}
+
+// ToString formats whole hours as the hour alone, as hour-based times were formatted, and other times as h:mm.
+// Times at or after EndOfDay are formatted as h:mm of the next day followed by "+1".
+func (t ClockTime) ToString() string {
+	if t >= EndOfDay {
+		next := t - EndOfDay
+		return fmt.Sprintf("%d:%02d%s", next.Hour(), next.Minute(), nextDaySuffix)
+	}
+	if t.Minute() == 0 {
+		return strconv.Itoa(t.Hour())
+	}
+	return fmt.Sprintf("%d:%02d", t.Hour(), t.Minute())
+}
+
+// String formats a time for display, e.g. in the HTML templates
+func (t ClockTime) String() string {
+	return t.ToString()
+}
+
+// ParseClockTime parses a time formatted by ToString or as hh:mm, possibly followed by "+1" for the next day
+func ParseClockTime(s string) (ClockTime, error) {
+	clock, nextDay := strings.CutSuffix(strings.TrimSpace(s), nextDaySuffix)
+	hourPart, minutePart, hasMinutes := strings.Cut(clock, ":")
+	hour, err := strconv.Atoi(hourPart)
+	if err != nil || hour < 0 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	var minute int
+	if hasMinutes {
+		if minute, err = strconv.Atoi(minutePart); err != nil || len(minutePart) != 2 || minute < 0 || minute >= 60 {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+	}
+	t := hour*60 + minute
+	if nextDay {
+		if hour >= 24 {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		t += int(EndOfDay)
+	}
+	if t < math.MaxUint16 {
+		return ClockTime(t), nil
+	}
+	return 0, fmt.Errorf("invalid time %q", s)
+}
+
+// MarshalJSON keeps whole hours of the day numbers, as hour-based times were, and writes other times as strings
+// formatted by ToString
+func (t ClockTime) MarshalJSON() ([]byte, error) {
+	if t < EndOfDay && t.Minute() == 0 {
+		return json.Marshal(t.Hour())
+	}
+	return json.Marshal(t.ToString())
+}
+
+// UnmarshalJSON accepts a number of hours, possibly fractional, or a time string accepted by ParseClockTime
+func (t *ClockTime) UnmarshalJSON(data []byte) error {
+	var hours float64
+	if err := json.Unmarshal(data, &hours); err == nil {
+		minutes := math.Round(hours * 60)
+		if minutes < 0 || minutes >= math.MaxUint16 {
+			return fmt.Errorf("invalid time %v", hours)
+		}
+		*t = ClockTime(minutes)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("time should be a number of hours or a h:mm string, got %s", data)
+	}
+	parsed, err := ParseClockTime(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
 type TimeInterval struct {
-	Start Hour `json:"start"`
-	End   Hour `json:"end"`
+	Start ClockTime `json:"start"`
+	End   ClockTime `json:"end"`
 }
 
 type ByStartTime []TimeInterval
@@ -52,48 +163,154 @@ func (interval *TimeInterval) Inclusive(newInterval *TimeInterval) bool {
 	return newInterval.Start >= interval.Start && newInterval.End <= interval.End
 }
 
-// ParseTimeInterval returns a TimeInterval with start and end hour in [0-24], given a string of form "Monday: 9:30AM - 8:00PM",
-func ParseTimeInterval(openingHour string) (interval TimeInterval, err error) {
-	closed := closedRe.MatchString(openingHour)
-	if closed {
-		interval.Start = 255
-		interval.End = 255
-		return
+// OpenIntervals are the intervals a place is open during a day sorted by start time, a place closed for the day has none.
+// Hours past midnight of a day open late end after EndOfDay.
+type OpenIntervals []TimeInterval
+
+// Include tells whether the place is open for the whole interval
+func (intervals OpenIntervals) Include(interval *TimeInterval) bool {
+	for _, open := range intervals {
+		if open.Inclusive(interval) {
+			return true
+		}
 	}
-	hours := hourRegex.FindAll([]byte(openingHour), -1)
+	return false
+}
 
-	amPmRe := regexp.MustCompile(`[apAP][mM]`)
-	amPm := amPmRe.FindAll([]byte(openingHour), -1)
+// ParseOpenIntervals parses the opening hours of a day such as "Monday: 11:30 AM – 2:30 PM, 5:00 – 10:00 PM",
+// "Monday: Open 24 hours", "Monday: Closed" or "Montag: 09:00–12:00, 14:00–18:00" into intervals starting between
+// 0:00 and 24:00. A time without AM or PM takes the one of the other end of its range, and a range without any is on
+// the 24-hour clock. A range ending at or after midnight, such as "6:00 PM – 2:00 AM", ends after EndOfDay.
+func ParseOpenIntervals(openingHour string) (OpenIntervals, error) {
+	hours := normalizeOpeningHour(openingHour)
+	if closedRe.MatchString(hours) {
+		return OpenIntervals{}, nil
+	}
+	if openAllDayRe.MatchString(hours) {
+		return OpenIntervals{{Start: 0, End: EndOfDay}}, nil
+	}
 
-	if len(hours) < 2 || len(amPm) < 2 {
-		return TimeInterval{}, errors.New("cannot parse opening hour")
+	var intervals OpenIntervals
+	for _, timeRange := range strings.FieldsFunc(hours, func(r rune) bool { return r == ',' || r == ';' }) {
+		start, end, found := strings.Cut(timeRange, "-")
+		if !found {
+			return nil, fmt.Errorf("cannot parse opening hour %q", openingHour)
+		}
+		interval, err := parseTimeRange(strings.TrimSpace(start), strings.TrimSpace(end))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse opening hour %q: %w", openingHour, err)
+		}
+		intervals = append(intervals, interval)
 	}
+	if len(intervals) == 0 {
+		return nil, fmt.Errorf("cannot parse opening hour %q", openingHour)
+	}
+	sort.Sort(ByStartTime(intervals))
+	return intervals, nil
+}
 
-	interval.Start = Hour(calculateHour(string(hours[0]), string(amPm[0])))
-	interval.End = Hour(calculateHour(string(hours[1]), string(amPm[1])))
+// Shift moves the intervals later by offset minutes, an offset of -EndOfDay moves the hours after midnight of a day
+// to the next day and drops the rest
+func (intervals OpenIntervals) Shift(offset int) OpenIntervals {
+	var shifted OpenIntervals
+	for _, interval := range intervals {
+		start, end := int(interval.Start)+offset, int(interval.End)+offset
+		if end <= 0 {
+			continue
+		}
+		shifted = append(shifted, TimeInterval{Start: ClockTime(max(start, 0)), End: ClockTime(end)})
+	}
+	return shifted
+}
 
-	if interval.Start > interval.End { // set end time the last hour of the day
-		interval.End = 24
+// Merge joins the intervals that overlap or touch, such as the hours of a day open until midnight and the hours of the
+// next day shifted by EndOfDay
+func (intervals OpenIntervals) Merge() OpenIntervals {
+	sorted := slices.Clone(intervals)
+	sort.Sort(ByStartTime(sorted))
+	var merged OpenIntervals
+	for _, interval := range sorted {
+		if last := len(merged) - 1; last >= 0 && interval.Start <= merged[last].End {
+			merged[last].End = max(merged[last].End, interval.End)
+			continue
+		}
+		merged = append(merged, interval)
 	}
+	return merged
+}
 
-	return
+// normalizeOpeningHour drops the weekday name and lower-cases the hours, replacing the dashes and the special
+// spaces of formatted hours with plain ones
+func normalizeOpeningHour(openingHour string) string {
+	hours := strings.NewReplacer("\u2013", "-", "\u2014", "-", "\u2011", "-", "\u202f", " ", "\u2009", " ", "\u00a0", " ").Replace(openingHour)
+	hours = strings.ToLower(strings.TrimSpace(hours))
+	return dayPrefixRe.ReplaceAllString(hours, "")
 }
 
-func calculateHour(time string, amPm string) uint8 {
-	t := strings.Split(time, ":")
-	hour, err := strconv.ParseUint(t[0], 10, 8)
-	utils.LogErrorWithLevel(err, utils.LogError)
+type clockToken struct {
+	hour, minute int
+	meridiem     string // am, pm or empty on the 24-hour clock
+}
 
-	if amPm == "AM" || amPm == "am" {
-		if hour == 12 {
-			return 24
-		}
-		return uint8(hour)
-	} else if amPm == "PM" || amPm == "pm" {
-		if hour == 12 {
-			return 12
+func parseClockToken(token string) (clockToken, error) {
+	match := clockTokenRe.FindStringSubmatch(token)
+	if match == nil {
+		return clockToken{}, fmt.Errorf("invalid time %q", token)
+	}
+	t := clockToken{meridiem: strings.ReplaceAll(match[3], ".", "")}
+	t.hour, _ = strconv.Atoi(match[1])
+	if match[2] != "" {
+		t.minute, _ = strconv.Atoi(match[2])
+	}
+	if t.minute >= 60 || t.meridiem == "" && t.hour > 24 || t.meridiem != "" && (t.hour == 0 || t.hour > 12) {
+		return clockToken{}, fmt.Errorf("invalid time %q", token)
+	}
+	return t, nil
+}
+
+func (t clockToken) clockTime(meridiem string) ClockTime {
+	hour := t.hour
+	switch meridiem {
+	case "am":
+		hour %= 12
+	case "pm":
+		hour = hour%12 + 12
+	}
+	return NewClockTime(hour, t.minute)
+}
+
+func parseTimeRange(startToken, endToken string) (TimeInterval, error) {
+	start, err := parseClockToken(startToken)
+	if err != nil {
+		return TimeInterval{}, err
+	}
+	end, err := parseClockToken(endToken)
+	if err != nil {
+		return TimeInterval{}, err
+	}
+
+	startMeridiem, endMeridiem := start.meridiem, end.meridiem
+	if startMeridiem == "" && endMeridiem != "" {
+		// "5:00 - 10:00 pm" is in the evening and "11:00 - 2:00 pm" starts in the morning, while "10:00 - 2:00 am"
+		// starts in the evening before
+		startMeridiem = endMeridiem
+		if start.clockTime(startMeridiem) > end.clockTime(endMeridiem) {
+			startMeridiem = map[string]string{"am": "pm", "pm": "am"}[endMeridiem]
 		}
-		return uint8(hour) + 12
+	} else if endMeridiem == "" && startMeridiem != "" {
+		endMeridiem = startMeridiem
+	}
+
+	interval := TimeInterval{Start: start.clockTime(startMeridiem), End: end.clockTime(endMeridiem)}
+	if interval.Start >= EndOfDay {
+		interval.Start = 0
+	}
+	if interval.End > EndOfDay {
+		return TimeInterval{}, fmt.Errorf("invalid time %q", endToken)
+	}
+	// a range ending at or before its start ends on the next day
+	if interval.End <= interval.Start {
+		interval.End += EndOfDay
 	}
-	return 255 // err
+	return interval, nil
 }
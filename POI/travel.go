@@ -0,0 +1,68 @@
+package POI
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/weihesdlegend/Vacation-planner/utils"
+)
+
+type TravelMode string
+
+const (
+	TravelModeAuto    TravelMode = "" // walk short legs and drive the rest
+	TravelModeWalking TravelMode = "walking"
+	TravelModeDriving TravelMode = "driving"
+	TravelModeTransit TravelMode = "transit"
+)
+
+// The travel model is computed locally from straight-line distances so that it can be evaluated for every plan
+// candidate without calling a routing API. Speeds are door-to-door averages for city travel.
+const (
+	WalkingSpeed         = 1.4 // meters per second, about 5 km/h
+	DrivingSpeed         = 8.3 // meters per second, about 30 km/h with traffic and lights
+	TransitSpeed         = 5.6 // meters per second, about 20 km/h including stops
+	DrivingOverheadMins  = 5.0 // parking at the destination
+	TransitOverheadMins  = 10.0
+	RouteDetourFactor    = 1.3    // ratio between the route distance and the straight-line distance
+	MaxAutoWalkingMeters = 1500.0 // legs up to this route distance are walked in auto mode
+)
+
+// TravelLeg is the estimated travel between two consecutive places of a plan
+type TravelLeg struct {
+	Mode     TravelMode `json:"mode"`
+	Distance float64    `json:"distance"` // route distance in meters
+	Minutes  int        `json:"minutes"`  // travel time rounded up to whole minutes
+}
+
+func ParseTravelMode(mode string) (TravelMode, error) {
+	switch m := TravelMode(strings.ToLower(strings.TrimSpace(mode))); m {
+	case TravelModeAuto, TravelModeWalking, TravelModeDriving, TravelModeTransit:
+		return m, nil
+	}
+	return TravelModeAuto, errors.New("travel mode must be one of walking, driving or transit")
+}
+
+// EstimateTravelLeg estimates route distance and travel time between two locations with the given travel mode
+func EstimateTravelLeg(from, to Location, mode TravelMode) TravelLeg {
+	distance := utils.HaversineDist([]float64{from.Latitude, from.Longitude}, []float64{to.Latitude, to.Longitude}) * RouteDetourFactor
+	if mode == TravelModeAuto {
+		mode = TravelModeDriving
+		if distance <= MaxAutoWalkingMeters {
+			mode = TravelModeWalking
+		}
+	}
+
+	var minutes float64
+	switch mode {
+	case TravelModeWalking:
+		minutes = distance / WalkingSpeed / 60
+	case TravelModeTransit:
+		minutes = distance/TransitSpeed/60 + TransitOverheadMins
+	default:
+		mode = TravelModeDriving
+		minutes = distance/DrivingSpeed/60 + DrivingOverheadMins
+	}
+	return TravelLeg{Mode: mode, Distance: math.Round(distance), Minutes: int(math.Ceil(minutes))}
+}
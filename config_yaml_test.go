@@ -4,6 +4,8 @@ import (
 	"os"
 	"testing"
 
+	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/planner"
 	"gopkg.in/yaml.v3"
 )
 
@@ -44,3 +46,47 @@ func TestDetailedSearchFieldsMask(t *testing.T) {
 		}
 	}
 }
+
+func TestPlanSolverScoring(t *testing.T) {
+	raw, err := os.ReadFile("config/config.yml")
+	if err != nil {
+		t.Fatalf("reading config/config.yml: %v", err)
+	}
+	var configs Configurations
+	if err := yaml.Unmarshal(raw, &configs); err != nil {
+		t.Fatalf("unmarshal config.yml: %v", err)
+	}
+
+	if _, err := matching.NewScorer(configs.Server.PlanSolver.Scorers); err != nil {
+		t.Errorf("plan_solver scorers are invalid: %v", err)
+	}
+	lambda, err := planner.ParseDiversityLambda(configs.Server.PlanSolver.DiversityLambda)
+	if err != nil {
+		t.Errorf("plan_solver diversity_lambda is invalid: %v", err)
+	}
+	// plans are ranked by score only unless a deployment opts in to diversity
+	if lambda != planner.DiversityLambdaDisabled {
+		t.Errorf("expected the default diversity_lambda to rank plans by score only, got %v", lambda)
+	}
+}
+
+// Cached plans are recomputed when they expire within refresh_before_minutes at a warming round, so a refresh window
+// shorter than the interval lets the plans of popular cities expire between two rounds.
+func TestCacheWarmingSchedule(t *testing.T) {
+	raw, err := os.ReadFile("config/config.yml")
+	if err != nil {
+		t.Fatalf("reading config/config.yml: %v", err)
+	}
+	var configs Configurations
+	if err := yaml.Unmarshal(raw, &configs); err != nil {
+		t.Fatalf("unmarshal config.yml: %v", err)
+	}
+
+	warming := configs.Server.CacheWarming
+	if warming.IntervalMinutes <= 0 || warming.MaxCities <= 0 || warming.DaysAhead <= 0 {
+		t.Errorf("cache_warming interval, city and day limits must be positive, got %+v", warming)
+	}
+	if warming.RefreshBeforeMinutes <= warming.IntervalMinutes {
+		t.Errorf("cache_warming refresh_before_minutes %d must exceed interval_minutes %d", warming.RefreshBeforeMinutes, warming.IntervalMinutes)
+	}
+}
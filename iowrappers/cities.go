@@ -9,4 +9,5 @@ type City struct {
 	Population int64   `json:"population"`
 	AdminArea1 string  `json:"adminArea1"`
 	Country    string  `json:"country"`
+	Timezone   string  `json:"timezone"`
 }
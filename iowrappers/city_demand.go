@@ -0,0 +1,103 @@
+package iowrappers
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CityDemand is the number of planning API calls for a city, as counted by CollectPlanningAPIStats
+type CityDemand struct {
+	City              string
+	AdminAreaLevelOne string
+	Country           string
+	Visits            int64
+}
+
+// PopularCities ranks the cities by the planning API calls counted in the hour buckets since a time, the most visited
+// first, and returns up to limit cities
+func (r *RedisClient) PopularCities(ctx context.Context, since time.Time, limit int) ([]CityDemand, error) {
+	keys, err := scanRedisKeys(ctx, r, NumVisitorsPlanningAPI+":")
+	if err != nil {
+		return nil, err
+	}
+
+	cityKeys := make([]string, 0, len(keys))
+	cities := make([]CityDemand, 0, len(keys))
+	for _, key := range keys {
+		city, bucket, ok := parseCityVisitorsKey(key)
+		if !ok || bucket.Before(since.UTC().Truncate(time.Hour)) {
+			continue
+		}
+		cityKeys = append(cityKeys, key)
+		cities = append(cities, city)
+	}
+	if len(cityKeys) == 0 {
+		return nil, nil
+	}
+
+	counts, err := r.Get().MGet(ctx, cityKeys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	demands := make(map[CityDemand]int64)
+	for idx, count := range counts {
+		// keys expired since the scan have no count
+		value, ok := count.(string)
+		if !ok {
+			continue
+		}
+		visits, parseErr := strconv.ParseInt(value, 10, 64)
+		if parseErr != nil {
+			Logger.Debugf("invalid visitor count %q of %s", value, cityKeys[idx])
+			continue
+		}
+		demands[cities[idx]] += visits
+	}
+
+	ranked := make([]CityDemand, 0, len(demands))
+	for city, visits := range demands {
+		city.Visits = visits
+		ranked = append(ranked, city)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Visits != ranked[j].Visits {
+			return ranked[i].Visits > ranked[j].Visits
+		}
+		return cityDemandName(ranked[i]) < cityDemandName(ranked[j])
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked, nil
+}
+
+// parseCityVisitorsKey parses the city and the hour bucket of a key of CollectPlanningAPIStats, either
+// visitor_count:planning_api:city:country:YYYYMMDD:HH or visitor_count:planning_api:city:region:country:YYYYMMDD:HH.
+// The keys counting all the cities are skipped.
+func parseCityVisitorsKey(key string) (city CityDemand, bucket time.Time, ok bool) {
+	fields := strings.Split(strings.TrimPrefix(key, NumVisitorsPlanningAPI+":"), ":")
+	if len(fields) < 4 || len(fields) > 5 {
+		return CityDemand{}, time.Time{}, false
+	}
+	bucket, err := time.Parse("20060102:15", strings.Join(fields[len(fields)-2:], ":"))
+	if err != nil {
+		return CityDemand{}, time.Time{}, false
+	}
+
+	location := fields[:len(fields)-2]
+	for idx := range location {
+		location[idx] = strings.ReplaceAll(location[idx], "_", " ")
+	}
+	city = CityDemand{City: location[0], Country: location[len(location)-1]}
+	if len(location) == 3 {
+		city.AdminAreaLevelOne = location[1]
+	}
+	return city, bucket, true
+}
+
+func cityDemandName(city CityDemand) string {
+	return strings.Join([]string{city.City, city.AdminAreaLevelOne, city.Country}, ":")
+}
@@ -0,0 +1,148 @@
+package iowrappers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/utils"
+)
+
+const (
+	ClosuresRedisKeyPrefix = "closures"
+	// closures are kept for a couple of days after their date, travel dates are dates at destinations ahead of UTC
+	closureRetention = 48 * time.Hour
+	// closedPlaceRadius bounds the distance from a destination to the places a plan can visit, searches of pricey
+	// eateries reach the farthest
+	closedPlaceRadius = GoogleNearbySearchMaxRadiusInMeters
+)
+
+func closuresKey(date string) string {
+	return strings.Join([]string{ClosuresRedisKeyPrefix, "date", date}, ":")
+}
+
+// SaveClosure adds a closure to the calendar, replacing the closure of the same place or country on its date
+func (r *RedisClient) SaveClosure(ctx context.Context, closure POI.Closure) error {
+	if err := closure.Validate(); err != nil {
+		return err
+	}
+	return r.saveClosure(ctx, closure, false)
+}
+
+func (r *RedisClient) saveClosure(ctx context.Context, closure POI.Closure, keepExisting bool) error {
+	data, err := json.Marshal(closure)
+	if err != nil {
+		return err
+	}
+	date, _ := time.Parse(POI.ClosureDateLayout, closure.Date)
+	key := closuresKey(closure.Date)
+	pipe := r.Get().TxPipeline()
+	if keepExisting {
+		pipe.HSetNX(ctx, key, closure.ID(), data)
+	} else {
+		pipe.HSet(ctx, key, closure.ID(), data)
+	}
+	pipe.ExpireAt(ctx, key, date.Add(24*time.Hour+closureRetention))
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// RemoveClosure removes a closure with the ID given by POI.Closure.ID and reports whether the closure existed
+func (r *RedisClient) RemoveClosure(ctx context.Context, date, closureID string) (bool, error) {
+	removed, err := r.Get().HDel(ctx, closuresKey(date), closureID).Result()
+	if err != nil {
+		return false, err
+	}
+	return removed > 0, nil
+}
+
+// Closures returns every closure on a date, sorted by ID
+func (r *RedisClient) Closures(ctx context.Context, date string) (POI.Closures, error) {
+	entries, err := r.Get().HGetAll(ctx, closuresKey(date)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	closures := make(POI.Closures, 0, len(entries))
+	for id, entry := range entries {
+		var closure POI.Closure
+		if err = json.Unmarshal([]byte(entry), &closure); err != nil {
+			Logger.Errorf("failed to parse closure %s on %s: %v", id, date, err)
+			continue
+		}
+		closures = append(closures, closure)
+	}
+	sort.Slice(closures, func(i, j int) bool { return closures[i].ID() < closures[j].ID() })
+	return closures, nil
+}
+
+// ClosuresAt returns the holidays of the country of a destination and the closures of places near the destination on a
+// travel date. Closures elsewhere are left out, so that they do not keep plans of the destination from being shared
+// across dates.
+func (r *RedisClient) ClosuresAt(ctx context.Context, date string, destination POI.Location) (POI.Closures, error) {
+	closures, err := r.Closures(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+
+	var placeIDs []string
+	for _, closure := range closures {
+		if closure.PlaceID != "" {
+			placeIDs = append(placeIDs, closure.PlaceID)
+		}
+	}
+	places, err := r.CachedPlaces(ctx, placeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return Filter(closures, func(closure POI.Closure) bool {
+		if closure.PlaceID == "" {
+			return sameCountry(closure.Country, destination.Country)
+		}
+		place, found := places[closure.PlaceID]
+		if !found {
+			return false
+		}
+		dist := utils.HaversineDist([]float64{destination.Latitude, destination.Longitude}, []float64{place.Location.Latitude, place.Location.Longitude})
+		return dist <= closedPlaceRadius
+	}), nil
+}
+
+// sameCountry compares countries by their codes, so that e.g. "USA" and "United States" match
+func sameCountry(a, b string) bool {
+	if codeA, codeB := countryCode(a), countryCode(b); codeA != "" && codeB != "" {
+		return codeA == codeB
+	}
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+// LoadClosures adds the closures listed in a JSON data file to the calendar and returns the number of valid closures in
+// the file. Closures edited by admins are kept, so the file only seeds the calendar.
+func (r *RedisClient) LoadClosures(ctx context.Context, path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var closures []POI.Closure
+	if err = json.Unmarshal(data, &closures); err != nil {
+		return 0, err
+	}
+
+	var loaded int
+	for _, closure := range closures {
+		if err = closure.Validate(); err != nil {
+			Logger.Errorf("skipping closure %+v in %s: %v", closure, path, err)
+			continue
+		}
+		if err = r.saveClosure(ctx, closure, true); err != nil {
+			return loaded, err
+		}
+		loaded++
+	}
+	return loaded, nil
+}
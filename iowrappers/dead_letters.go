@@ -0,0 +1,76 @@
+package iowrappers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const DeadLetterJobsRedisKey = "jobs:dead_letters"
+
+// DeadLetterJob is a job that failed every attempt of its retry policy, kept until an admin requeues or discards it
+type DeadLetterJob struct {
+	Job            Job       `json:"job"`
+	Error          string    `json:"error"`
+	DeadLetteredAt time.Time `json:"dead_lettered_at"`
+}
+
+// SaveDeadLetterJob adds a job to the dead-letter store, replacing an earlier dead letter of the job
+func (r *RedisClient) SaveDeadLetterJob(ctx context.Context, job *Job, reason string) error {
+	data, err := json.Marshal(DeadLetterJob{Job: *job, Error: reason, DeadLetteredAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	return r.Get().HSet(ctx, DeadLetterJobsRedisKey, job.ID, data).Err()
+}
+
+// DeadLetterJobs returns the dead-lettered jobs, the latest first
+func (r *RedisClient) DeadLetterJobs(ctx context.Context) ([]DeadLetterJob, error) {
+	entries, err := r.Get().HGetAll(ctx, DeadLetterJobsRedisKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]DeadLetterJob, 0, len(entries))
+	for id, entry := range entries {
+		var job DeadLetterJob
+		if err = json.Unmarshal([]byte(entry), &job); err != nil {
+			Logger.Errorf("failed to parse dead-lettered job %s: %v", id, err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].DeadLetteredAt.After(jobs[j].DeadLetteredAt) })
+	return jobs, nil
+}
+
+// GetDeadLetterJob returns a dead-lettered job, or an error wrapping ErrJobNotFound
+func (r *RedisClient) GetDeadLetterJob(ctx context.Context, id string) (*DeadLetterJob, error) {
+	entry, err := r.Get().HGet(ctx, DeadLetterJobsRedisKey, id).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+		}
+		return nil, err
+	}
+
+	job := new(DeadLetterJob)
+	if err = json.Unmarshal([]byte(entry), job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// RemoveDeadLetterJob removes a job from the dead-letter store and reports whether the job was there
+func (r *RedisClient) RemoveDeadLetterJob(ctx context.Context, id string) (bool, error) {
+	removed, err := r.Get().HDel(ctx, DeadLetterJobsRedisKey, id).Result()
+	if err != nil {
+		return false, err
+	}
+	return removed > 0, nil
+}
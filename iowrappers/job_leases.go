@@ -0,0 +1,87 @@
+package iowrappers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	JobLeaseRedisKeyPrefix = "job_lease:"
+	// the value of the lease key once the job completed, so that the same work is skipped until its results expire
+	jobLeaseCompleted = "completed"
+)
+
+var ErrJobLeaseLost = errors.New("job lease is lost")
+
+// JobLease is the exclusive right of a worker of any server to run the job identified by Key. The lease expires unless
+// it is renewed, so that the job of a crashed server runs again.
+type JobLease struct {
+	Key   string
+	token string // identifies the holder, only the holder renews and releases the lease
+}
+
+var (
+	renewJobLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	// a completed job keeps its key with the completed value ARGV[4] for ARGV[3] milliseconds, a failed job deletes it
+	releaseJobLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == "1" then
+	return redis.call("SET", KEYS[1], ARGV[4], "PX", ARGV[3]) and 1
+end
+return redis.call("DEL", KEYS[1])`)
+)
+
+// AcquireJobLease acquires the lease of a job for ttl. It reports false without an error when another worker holds the
+// lease or the job completed within JobExpirationTime.
+func (r *RedisClient) AcquireJobLease(ctx context.Context, jobKey string, ttl time.Duration) (*JobLease, bool, error) {
+	lease := &JobLease{Key: JobLeaseRedisKeyPrefix + jobKey, token: uuid.NewString()}
+	acquired, err := r.Get().SetNX(ctx, lease.Key, lease.token, ttl).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if !acquired {
+		return nil, false, nil
+	}
+	return lease, true, nil
+}
+
+// RenewJobLease extends a lease held by the caller to ttl from now, or returns ErrJobLeaseLost once the lease expired
+// and possibly went to another worker
+func (r *RedisClient) RenewJobLease(ctx context.Context, lease *JobLease, ttl time.Duration) error {
+	renewed, err := renewJobLeaseScript.Run(ctx, r.Get(), []string{lease.Key}, lease.token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if renewed == 0 {
+		return ErrJobLeaseLost
+	}
+	return nil
+}
+
+// ReleaseJobLease releases a lease held by the caller, a lease that went to another worker is left alone. The lease of a
+// completed job is kept as a completion mark for JobExpirationTime, the lease of a failed job is freed for a retry.
+func (r *RedisClient) ReleaseJobLease(ctx context.Context, lease *JobLease, completed bool) error {
+	completedFlag := "0"
+	if completed {
+		completedFlag = "1"
+	}
+	released, err := releaseJobLeaseScript.Run(ctx, r.Get(), []string{lease.Key}, lease.token, completedFlag, JobExpirationTime.Milliseconds(), jobLeaseCompleted).Int()
+	if err != nil {
+		return err
+	}
+	if released == 0 {
+		return ErrJobLeaseLost
+	}
+	return nil
+}
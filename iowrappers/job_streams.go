@@ -0,0 +1,188 @@
+package iowrappers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	JobStreamRedisKeyPrefix = "jobs:stream:"
+	jobStreamField          = "job"
+)
+
+// renewJobStreamEntryScript resets the idle time of an entry pending for the consumer ARGV[2], an entry claimed by
+// another consumer is left alone
+var renewJobStreamEntryScript = redis.NewScript(`
+local pending = redis.call("XPENDING", KEYS[1], ARGV[1], ARGV[3], ARGV[3], 1)
+if #pending == 0 or pending[1][2] ~= ARGV[2] then
+	return 0
+end
+redis.call("XCLAIM", KEYS[1], ARGV[1], ARGV[2], 0, ARGV[3], "JUSTID")
+return 1`)
+
+// JobStreamEntry is a job read from a job stream by a consumer, the job stays pending for the consumer until the entry
+// is acknowledged
+type JobStreamEntry struct {
+	Stream string
+	ID     string
+	Data   string
+}
+
+// EnqueuedAt returns when the entry was added to its stream, which is the time part of entry IDs
+func (e *JobStreamEntry) EnqueuedAt() time.Time {
+	milliseconds, err := strconv.ParseInt(strings.SplitN(e.ID, "-", 2)[0], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(milliseconds)
+}
+
+// JobStreamKey returns the key of the stream queuing the jobs of a priority
+func JobStreamKey(priority JobPriority) string {
+	switch priority {
+	case JobPriorityHigh:
+		return JobStreamRedisKeyPrefix + "high"
+	case JobPriorityLow:
+		return JobStreamRedisKeyPrefix + "low"
+	default:
+		return JobStreamRedisKeyPrefix + "normal"
+	}
+}
+
+// CreateJobStreamGroup creates a stream and its consumer group reading the stream from the first entry, creating an
+// existing group is a no-op
+func (r *RedisClient) CreateJobStreamGroup(ctx context.Context, stream, group string) error {
+	err := r.client.XGroupCreateMkStream(ctx, stream, group, "0").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// AddJobStreamEntry appends a serialized job to a stream and returns the ID of the entry
+func (r *RedisClient) AddJobStreamEntry(ctx context.Context, stream string, data string) (string, error) {
+	return r.client.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: []string{jobStreamField, data}}).Result()
+}
+
+// ReadJobStreams reads the next entry not yet delivered to the group from each of the streams with one, in the order of
+// the streams. A negative block returns right away, otherwise the read waits up to block for an entry of any stream.
+func (r *RedisClient) ReadJobStreams(ctx context.Context, group, consumer string, streams []string, block time.Duration) ([]*JobStreamEntry, error) {
+	args := make([]string, 0, 2*len(streams))
+	args = append(args, streams...)
+	for range streams {
+		args = append(args, ">")
+	}
+	results, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  args,
+		Count:    1,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*JobStreamEntry, 0, len(results))
+	for _, stream := range streams {
+		for _, result := range results {
+			if result.Stream != stream {
+				continue
+			}
+			for _, message := range result.Messages {
+				entries = append(entries, toJobStreamEntry(result.Stream, message))
+			}
+		}
+	}
+	return entries, nil
+}
+
+// ClaimJobStreamEntry transfers to a consumer an entry of a stream that was delivered to another consumer of the group
+// and has not been acknowledged for minIdle, e.g. the job of a worker that crashed. A nil entry means none was found.
+func (r *RedisClient) ClaimJobStreamEntry(ctx context.Context, stream, group, consumer string, minIdle time.Duration) (*JobStreamEntry, error) {
+	messages, _, err := r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Start:    "0",
+		Count:    1,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, message := range messages {
+		return toJobStreamEntry(stream, message), nil
+	}
+	return nil, nil
+}
+
+// RenewJobStreamEntry resets the idle time of an entry delivered to a consumer, so that other consumers do not claim it,
+// and reports whether the entry is still pending for the consumer. An entry already claimed by another consumer is not
+// taken back.
+func (r *RedisClient) RenewJobStreamEntry(ctx context.Context, entry *JobStreamEntry, group, consumer string) (bool, error) {
+	renewed, err := renewJobStreamEntryScript.Run(ctx, r.client, []string{entry.Stream}, group, consumer, entry.ID).Int()
+	if err != nil {
+		return false, err
+	}
+	return renewed == 1, nil
+}
+
+// AckJobStreamEntry acknowledges an entry for the group and removes it from the stream
+func (r *RedisClient) AckJobStreamEntry(ctx context.Context, stream, group, id string) error {
+	pipe := r.client.TxPipeline()
+	pipe.XAck(ctx, stream, group, id)
+	pipe.XDel(ctx, stream, id)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// JobStreamBacklog returns the number of entries of a stream waiting for the consumers of the group, which excludes
+// the entries delivered and not yet acknowledged
+func (r *RedisClient) JobStreamBacklog(ctx context.Context, stream, group string) (int64, error) {
+	pipe := r.client.Pipeline()
+	length := pipe.XLen(ctx, stream)
+	pending := pipe.XPending(ctx, stream, group)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+	var numPending int64
+	if pending.Val() != nil {
+		numPending = pending.Val().Count
+	}
+	return max(length.Val()-numPending, 0), nil
+}
+
+// OldestJobStreamEntry returns the oldest entry of a stream not yet delivered to the consumers of the group, or nil
+func (r *RedisClient) OldestJobStreamEntry(ctx context.Context, stream, group string) (*JobStreamEntry, error) {
+	groups, err := r.client.XInfoGroups(ctx, stream).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range groups {
+		if info.Name != group {
+			continue
+		}
+		messages, err := r.client.XRangeN(ctx, stream, "("+info.LastDeliveredID, "+", 1).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, message := range messages {
+			return toJobStreamEntry(stream, message), nil
+		}
+	}
+	return nil, nil
+}
+
+func toJobStreamEntry(stream string, message redis.XMessage) *JobStreamEntry {
+	data, _ := message.Values[jobStreamField].(string)
+	return &JobStreamEntry{Stream: stream, ID: message.ID, Data: data}
+}
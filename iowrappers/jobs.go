@@ -18,6 +18,8 @@ const (
 	JobStatusDuplicated JobStatus = "duplicated"
 	JobStatusRunning    JobStatus = "running"
 	JobStatusFailed     JobStatus = "failed"
+	JobStatusRetrying   JobStatus = "retrying"
+	JobStatusCancelled  JobStatus = "cancelled"
 	JobStatusCompleted  JobStatus = "completed"
 	JobStatusUnknown    JobStatus = "unknown"
 
@@ -41,16 +43,22 @@ type Job struct {
 	Priority    JobPriority `json:"priority"`
 	CreatedAt   time.Time   `json:"created_at"`
 	UpdatedAt   time.Time   `json:"updated_at"`
+	// EnqueuedAt is when the job was last queued, the wait of a job for a worker raises its priority
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	// Attempts is the number of times the job was run
+	Attempts int `json:"attempts"`
+	// Result is the outcome of a completed job and Error the reason a job failed, both kept for jobs whose callers poll
+	// their records
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
 }
 
-// JobExecution helps the service to determine if the job result is still valid
-type JobExecution struct {
-	JobID     string    `json:"job_id"`
-	Status    JobStatus `json:"status"`
-	ExpiresAt time.Time `json:"expires_at"`
-}
+const (
+	JobRedisKeyPrefix             = "job:"
+	JobCancellationRedisKeyPrefix = "job_cancellation:"
+)
 
-const JobRedisKeyPrefix = "job:"
+var ErrJobNotFound = errors.New("job does not exist")
 
 func (r *RedisClient) UpdateJob(ctx context.Context, job *Job) error {
 	if reflect2.IsNil(job) {
@@ -80,7 +88,7 @@ func (r *RedisClient) GetJob(ctx context.Context, id string) (*Job, error) {
 	if exists, err := r.Get().Exists(ctx, key).Result(); err != nil {
 		return nil, err
 	} else if exists == 0 {
-		return nil, fmt.Errorf("job %s does not exist", id)
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
 	}
 
 	result, err := r.Get().Get(ctx, key).Result()
@@ -99,3 +107,17 @@ func (r *RedisClient) DeleteJob(ctx context.Context, id string) error {
 	key := JobRedisKeyPrefix + id
 	return r.Get().Del(ctx, key).Err()
 }
+
+// CancelJob asks the workers of every server to cancel a job, the worker dequeuing or running the job stops it
+func (r *RedisClient) CancelJob(ctx context.Context, id string) error {
+	return r.Get().Set(ctx, JobCancellationRedisKeyPrefix+id, time.Now().Format(time.RFC3339), JobExpirationTime).Err()
+}
+
+// IsJobCancelled reports whether a job was cancelled
+func (r *RedisClient) IsJobCancelled(ctx context.Context, id string) (bool, error) {
+	exists, err := r.Get().Exists(ctx, JobCancellationRedisKeyPrefix+id).Result()
+	if err != nil {
+		return false, err
+	}
+	return exists > 0, nil
+}
@@ -8,7 +8,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -476,7 +478,7 @@ func (r *RedisClient) NearbyCities(ctx context.Context, lat, lng, radius float64
 
 // CachedPlaces resolves stored place records for the given IDs in one round trip, omitting IDs
 // with no usable record. Used by the maps client to avoid re-buying Place Details for places we
-// already have; see MapsClient.SetCachedPlaceLookup.
+// already have; see MapsClient.SetCachedPlaceLookup. The planner fills in the place details of optimal plans with it.
 func (r *RedisClient) CachedPlaces(ctx context.Context, placeIDs []string) (map[string]POI.Place, error) {
 	if len(placeIDs) == 0 {
 		return nil, nil
@@ -749,15 +751,27 @@ type PlanningSolutionRecord struct {
 	PlaceAddresses  []string            `json:"place_addresses"`
 	PlaceURLs       []string            `json:"place_urls"`
 	PlaceCategories []POI.PlaceCategory `json:"place_categories"`
+	PlaceTypes      []POI.LocationType  `json:"place_types,omitempty"`
 	Weekdays        []string            `json:"weekdays"`
 	TimeSlots       []string            `json:"time_slots"`
 	Destination     POI.Location        `json:"destination"`
 	PlanSpec        string              `json:"plan_spec"`
+	// PriceLevel is the price level the plan was searched with, plans cached before it was recorded have none
+	PriceLevel *POI.PriceLevel `json:"price_level,omitempty"`
+	// TravelDate is the date the plan was made for, in the format of yyyy-mm-dd
+	TravelDate     string              `json:"travel_date,omitempty"`
+	Legs           []POI.TravelLeg     `json:"legs,omitempty"`
+	StartLeg       *POI.TravelLeg      `json:"start_leg,omitempty"`
+	EndLeg         *POI.TravelLeg      `json:"end_leg,omitempty"`
+	ScoreBreakdown *POI.ScoreBreakdown `json:"score_breakdown,omitempty"`
+	Exclusions     []POI.Exclusion     `json:"exclusions,omitempty"`
 }
 
 type PlanningSolutionsResponse struct {
 	PlanningSpec            string                   `json:"planning_spec"`
 	PlanningSolutionRecords []PlanningSolutionRecord `json:"cached_planning_solutions"`
+	// TTL is the time left before the cached plans expire
+	TTL time.Duration `json:"-"`
 }
 
 type PlanningSolutionsSaveRequest struct {
@@ -766,6 +780,14 @@ type PlanningSolutionsSaveRequest struct {
 	PlaceCategories         []POI.PlaceCategory
 	Intervals               []POI.TimeInterval
 	Weekdays                []POI.Weekday
+	TravelMode              POI.TravelMode
+	PinnedPlaceIDs          []string             // place ID pinned for each slot, empty for slots without a pinned place
+	ExcludedPlaceIDs        []string             // sorted IDs of places blocked by the user making the request
+	LocationTypes           [][]POI.LocationType // place types narrowing each slot, empty for slots of a whole category
+	Keywords                []string             // brand keyword narrowing each slot, empty for slots without a brand
+	StartLocation           *POI.Location        // where the traveler starts the day, nil if not given
+	EndLocation             *POI.Location        // where the traveler finishes the day, nil if not given
+	ClosureDate             string               // travel date with closures at the location, empty without closures
 	PlanningSolutionRecords []PlanningSolutionRecord
 	NumPlans                int64
 }
@@ -791,27 +813,34 @@ func timeSlotsIndex(placeCategories []POI.PlaceCategory, intervals []POI.TimeInt
 	return strings.Join(parts, "_"), nil
 }
 
+// timeSlotCategoryCodes abbreviates place categories in cache keys, the codes of Visit and Eatery predate the other
+// categories and must not change so that existing cache entries remain valid
+var timeSlotCategoryCodes = map[POI.PlaceCategory]string{
+	POI.PlaceCategoryVisit:    "V",
+	POI.PlaceCategoryEatery:   "E",
+	POI.PlaceCategoryShopping: "S",
+	POI.PlaceCategoryLodging:  "L",
+	POI.PlaceCategoryWellness: "W",
+}
+
 func singleTimeSlotIndex(category POI.PlaceCategory, interval POI.TimeInterval, weekday POI.Weekday) (string, error) {
 	parts := make([]string, 0)
-	switch category {
-	case POI.PlaceCategoryVisit:
-		parts = append(parts, "V")
-	case POI.PlaceCategoryEatery:
-		parts = append(parts, "E")
-	default:
+	code, ok := timeSlotCategoryCodes[category]
+	if !ok {
 		return "", fmt.Errorf("unknown place category %s", category)
 	}
+	parts = append(parts, code)
 
-	if int(interval.Start) >= 24 || int(interval.Start) < 0 {
-		return "", fmt.Errorf("interval start time should be between 0 and 23 inclusive, got %s", interval.Start.ToString())
+	if interval.Start >= POI.LatestSlotEnd {
+		return "", fmt.Errorf("interval start time should be before %s, got %s", POI.LatestSlotEnd.ToString(), interval.Start.ToString())
 	}
 
-	if int(interval.End) >= 24 || int(interval.End) < 0 {
-		return "", fmt.Errorf("interval start time should be between 0 and 23 inclusive, got %s", interval.End.ToString())
+	if interval.End > POI.LatestSlotEnd {
+		return "", fmt.Errorf("interval end time should be at most %s, got %s", POI.LatestSlotEnd.ToString(), interval.End.ToString())
 	}
 
-	parts = append(parts, interval.Start.ToString())
-	parts = append(parts, interval.End.ToString())
+	parts = append(parts, clockTimeIndex(interval.Start))
+	parts = append(parts, clockTimeIndex(interval.End))
 
 	if int(weekday) > 6 || int(weekday) < 0 {
 		return "", fmt.Errorf("weekday falls between 0 and 6 inclusive, got %s", weekday.String())
@@ -821,6 +850,66 @@ func singleTimeSlotIndex(category POI.PlaceCategory, interval POI.TimeInterval,
 	return strings.Join(parts, "-"), nil
 }
 
+// clockTimeIndex encodes whole hours as the hour alone so that cache keys of slots in whole hours stay the same as
+// before slots had minutes, and other times as 12h30 since colons separate the parts of a cache key
+func clockTimeIndex(t POI.ClockTime) string {
+	if t.Minute() == 0 {
+		return strconv.Itoa(t.Hour())
+	}
+	return fmt.Sprintf("%dh%02d", t.Hour(), t.Minute())
+}
+
+// placeIDsIndex hashes place IDs for a cache key since the key is lower-cased and place IDs are case-sensitive
+func placeIDsIndex(name string, placeIDs []string) string {
+	if strings.Join(placeIDs, "") == "" {
+		return ""
+	}
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(placeIDs, ",")))
+	return name + "-" + strconv.FormatUint(h.Sum64(), 16)
+}
+
+// slotNarrowingIndex identifies the place types and brand keywords narrowing the slots of a plan for a cache key.
+// Place types are sorted and keywords normalized so that equivalent narrowings share cached plans.
+func slotNarrowingIndex(locationTypes [][]POI.LocationType, keywords []string) string {
+	narrowings := make([]string, max(len(locationTypes), len(keywords)))
+	for idx := range narrowings {
+		var types []string
+		if idx < len(locationTypes) {
+			seen := make(map[POI.LocationType]bool)
+			for _, locationType := range locationTypes[idx] {
+				if !seen[locationType] {
+					seen[locationType] = true
+					types = append(types, string(locationType))
+				}
+			}
+			sort.Strings(types)
+		}
+		var keyword string
+		if idx < len(keywords) {
+			keyword = POI.NormalizeBrandKey(keywords[idx])
+		}
+		if len(types) > 0 || keyword != "" {
+			narrowings[idx] = strings.Join(types, "+") + "|" + keyword
+		}
+	}
+	return placeIDsIndex("narrowed", narrowings)
+}
+
+// anchorsIndex identifies the start and end locations of a plan for a cache key, rounded to about 10 meters
+func anchorsIndex(start, end *POI.Location) string {
+	if start == nil && end == nil {
+		return ""
+	}
+	anchor := func(location *POI.Location) string {
+		if location == nil {
+			return ""
+		}
+		return fmt.Sprintf("%.4f,%.4f", location.Latitude, location.Longitude)
+	}
+	return placeIDsIndex("anchors", []string{anchor(start), anchor(end)})
+}
+
 func TravelPlansCacheKey(req *PlanningSolutionsSaveRequest) (string, error) {
 	country, region, city := req.Location.Country, req.Location.AdminAreaLevelOne, req.Location.City
 	slotsIndex, err := timeSlotsIndex(req.PlaceCategories, req.Intervals, req.Weekdays)
@@ -832,7 +921,29 @@ func TravelPlansCacheKey(req *PlanningSolutionsSaveRequest) (string, error) {
 	region = strings.ReplaceAll(strings.ToLower(region), " ", "_")
 	city = strings.ReplaceAll(strings.ToLower(city), " ", "_")
 
-	redisFieldKey := strings.ToLower(strings.Join([]string{TravelPlansRedisCacheKeyPrefix, country, region, city, strconv.Itoa(int(req.PriceLevel)), slotsIndex}, ":"))
+	parts := []string{TravelPlansRedisCacheKeyPrefix, country, region, city, strconv.Itoa(int(req.PriceLevel))}
+	// travel mode changes plan scores, keys of the default mode stay unchanged so existing cache entries remain valid
+	if req.TravelMode != POI.TravelModeAuto {
+		parts = append(parts, string(req.TravelMode))
+	}
+	if pinnedIndex := placeIDsIndex("pinned", req.PinnedPlaceIDs); pinnedIndex != "" {
+		parts = append(parts, pinnedIndex)
+	}
+	if excludedIndex := placeIDsIndex("excluded", req.ExcludedPlaceIDs); excludedIndex != "" {
+		parts = append(parts, excludedIndex)
+	}
+	if narrowingIndex := slotNarrowingIndex(req.LocationTypes, req.Keywords); narrowingIndex != "" {
+		parts = append(parts, narrowingIndex)
+	}
+	if anchorsIndex := anchorsIndex(req.StartLocation, req.EndLocation); anchorsIndex != "" {
+		parts = append(parts, anchorsIndex)
+	}
+	// plans on dates with closures differ from the plans of the same weekday on other dates
+	if req.ClosureDate != "" {
+		parts = append(parts, "closed-"+req.ClosureDate)
+	}
+	parts = append(parts, slotsIndex)
+	redisFieldKey := strings.ToLower(strings.Join(parts, ":"))
 	return redisFieldKey, nil
 }
 
@@ -913,6 +1024,17 @@ func (r *RedisClient) SavePlanningSolutions(ctx context.Context, request *Planni
 	return nil
 }
 
+// SavePlanningSolutionRecord stores a single plan under travel_plan:<id> without adding it to any cached plans set,
+// which suits plans that are built for one user and should not be served to other requests.
+func (r *RedisClient) SavePlanningSolutionRecord(ctx context.Context, record *PlanningSolutionRecord) error {
+	json_, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	solutionRedisKey := strings.Join([]string{TravelPlanRedisCacheKeyPrefix, record.ID}, ":")
+	return r.client.Set(ctx, solutionRedisKey, json_, PlanningSolutionsExpirationTime).Err()
+}
+
 func (r *RedisClient) PlanningSolutions(ctx context.Context, request *PlanningSolutionsSaveRequest) (*PlanningSolutionsResponse, error) {
 	Logger.Debugf("->RedisClient.PlanningSolutions(%v)", request)
 	var response = &PlanningSolutionsResponse{}
@@ -933,6 +1055,7 @@ func (r *RedisClient) PlanningSolutions(ctx context.Context, request *PlanningSo
 	}
 
 	ttl := r.Get().TTL(ctx, sortedSetKey).Val()
+	response.TTL = ttl
 
 	userId, ok := ctx.Value(ContextRequestUserId).(string)
 	if !ok {
@@ -982,6 +1105,20 @@ func (r *RedisClient) PlanningSolutions(ctx context.Context, request *PlanningSo
 	return response, nil
 }
 
+// TravelPlansTTL returns the time left before the cached plans of a request expire, 0 without cached plans
+func (r *RedisClient) TravelPlansTTL(ctx context.Context, request *PlanningSolutionsSaveRequest) (time.Duration, error) {
+	sortedSetKey, err := TravelPlansCacheKey(request)
+	if err != nil {
+		return 0, err
+	}
+	ttl, err := r.Get().TTL(ctx, sortedSetKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	// TTL is negative for missing keys and keys without expiration
+	return max(ttl, 0), nil
+}
+
 func (r *RedisClient) SaveAnnouncement(ctx context.Context, id, data string) error {
 	return r.Get().HSet(ctx, AnnouncementsRedisKey, id, data).Err()
 }
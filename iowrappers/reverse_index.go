@@ -24,7 +24,7 @@ type ReverseIndex struct {
 }
 
 func reverseIndexKey(metadata *IndexMetadata) string {
-	start, end := metadata.Interval.Start.ToString(), metadata.Interval.End.ToString()
+	start, end := clockTimeIndex(metadata.Interval.Start), clockTimeIndex(metadata.Interval.End)
 	return strings.Join([]string{ReverseIndexStringPrefix, metadata.Location.Country, metadata.Location.AdminAreaLevelOne, metadata.Location.City, metadata.Weekday.String(), start, end}, ":")
 }
 
@@ -0,0 +1,155 @@
+package iowrappers
+
+import (
+	_ "embed"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	_ "time/tzdata" // time.LoadLocation must find the zones looked up here on hosts without a zoneinfo database
+
+	"github.com/ringsaturn/tzf"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/utils"
+)
+
+//go:embed data/timezones.tsv
+var timezonesData string
+
+// timezoneReference is the principal location of an IANA timezone
+type timezoneReference struct {
+	countryCode string
+	latitude    float64
+	longitude   float64
+	zone        string
+}
+
+var (
+	timezonesOnce      sync.Once
+	timezoneReferences []timezoneReference
+	// point-in-polygon lookup in the embedded timezone boundaries of timezone-boundary-builder
+	timezoneFinder tzf.F
+	// maps lower-cased country codes, names and aliases to country codes
+	timezoneCountryCodes map[string]string
+	// maps zones to the codes of their countries
+	zoneCountryCodes map[string]string
+)
+
+// countries known under names other than those of the tz database
+var timezoneCountryAliases = map[string]string{
+	"usa":                      "US",
+	"united states of america": "US",
+	"united kingdom":           "GB",
+	"great britain":            "GB",
+	"england":                  "GB",
+	"scotland":                 "GB",
+	"korea":                    "KR",
+	"south korea":              "KR",
+	"north korea":              "KP",
+	"russian federation":       "RU",
+	"czechia":                  "CZ",
+	"burma":                    "MM",
+	"swaziland":                "SZ",
+	"uae":                      "AE",
+}
+
+var countryQualifierRe = regexp.MustCompile(`\s*\(.*\)`)
+
+func loadTimezones() {
+	timezoneCountryCodes = make(map[string]string, len(timezoneCountryAliases))
+	zoneCountryCodes = make(map[string]string)
+	for alias, code := range timezoneCountryAliases {
+		timezoneCountryCodes[alias] = code
+	}
+
+	finder, err := tzf.NewDefaultFinder()
+	if err != nil {
+		Logger.Errorf("failed to load timezone boundaries: %v", err)
+	} else {
+		timezoneFinder = finder
+	}
+
+	for _, line := range strings.Split(timezonesData, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			Logger.Errorf("invalid timezone reference %q", line)
+			continue
+		}
+		lat, latErr := strconv.ParseFloat(fields[2], 64)
+		lng, lngErr := strconv.ParseFloat(fields[3], 64)
+		if latErr != nil || lngErr != nil {
+			Logger.Errorf("invalid coordinates of timezone reference %q", line)
+			continue
+		}
+		code := fields[0]
+		timezoneReferences = append(timezoneReferences, timezoneReference{countryCode: code, latitude: lat, longitude: lng, zone: fields[4]})
+		zoneCountryCodes[fields[4]] = code
+
+		name := strings.ToLower(fields[1])
+		timezoneCountryCodes[strings.ToLower(code)] = code
+		timezoneCountryCodes[name] = code
+		// "Britain (UK)" is also known as "Britain"; ambiguous short names such as "Korea" are covered by aliases
+		if short := countryQualifierRe.ReplaceAllString(name, ""); short != name {
+			if _, exists := timezoneCountryCodes[short]; !exists {
+				timezoneCountryCodes[short] = code
+			}
+		}
+	}
+}
+
+// TimezoneAt returns the IANA name of the timezone at a coordinate, e.g. "Asia/Tokyo".
+// The zone is looked up offline in the embedded timezone boundaries; where boundaries overlap, as in disputed areas,
+// a zone of the country is preferred. The country can be a name or a code.
+func TimezoneAt(latitude, longitude float64, country string) string {
+	code := countryCode(country)
+	if timezoneFinder != nil {
+		zones, err := timezoneFinder.GetTimezoneNames(longitude, latitude)
+		if err == nil && len(zones) > 0 {
+			for _, zone := range zones {
+				if code != "" && zoneCountryCodes[zone] == code {
+					return zone
+				}
+			}
+			return zones[0]
+		}
+	}
+	return nearestTimezone(latitude, longitude, code)
+}
+
+// nearestTimezone returns the zone whose principal location is the nearest to a coordinate outside the boundaries,
+// among the zones of the country when the country is known
+func nearestTimezone(latitude, longitude float64, code string) string {
+	var zone, fallback string
+	minDist, minFallbackDist := math.Inf(1), math.Inf(1)
+	for _, ref := range timezoneReferences {
+		dist := utils.HaversineDist([]float64{latitude, longitude}, []float64{ref.latitude, ref.longitude})
+		if dist < minFallbackDist {
+			minFallbackDist, fallback = dist, ref.zone
+		}
+		if ref.countryCode == code && dist < minDist {
+			minDist, zone = dist, ref.zone
+		}
+	}
+	if zone == "" {
+		return fallback
+	}
+	return zone
+}
+
+// countryCode returns the ISO 3166 code of a country given by name or code, or an empty string for an unknown country
+func countryCode(country string) string {
+	timezonesOnce.Do(loadTimezones)
+	return timezoneCountryCodes[strings.ToLower(strings.TrimSpace(country))]
+}
+
+// SetTimezone stores the timezone of a location with coordinates
+func SetTimezone(location *POI.Location) {
+	if location.Latitude == 0 && location.Longitude == 0 {
+		return
+	}
+	location.Timezone = TimezoneAt(location.Latitude, location.Longitude, location.Country)
+}
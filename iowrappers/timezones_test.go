@@ -0,0 +1,60 @@
+package iowrappers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+)
+
+func TestTimezoneAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lng float64
+		country  string
+		want     string
+	}{
+		{"San Jose", 37.3382, -121.8863, "United States", "America/Los_Angeles"},
+		{"Boston by alias", 42.3601, -71.0589, "USA", "America/New_York"},
+		{"Chicago", 41.8781, -87.6298, "US", "America/Chicago"},
+		{"Tokyo", 35.6762, 139.6503, "Japan", "Asia/Tokyo"},
+		// Pyongyang is nearer than Shanghai, the country keeps Beijing on China Standard Time
+		{"Beijing", 39.9042, 116.4074, "China", "Asia/Shanghai"},
+		{"London", 51.5072, -0.1276, "United Kingdom", "Europe/London"},
+		{"Paris without country", 48.8566, 2.3522, "", "Europe/Paris"},
+		// cities near zone boundaries, nearer to the principal location of a neighbouring zone than to their own
+		{"Seattle", 47.6062, -122.3321, "US", "America/Los_Angeles"},
+		{"Nashville", 36.1627, -86.7816, "US", "America/Chicago"},
+		{"Atlanta", 33.7490, -84.3880, "US", "America/New_York"},
+		{"El Paso", 31.7619, -106.4850, "US", "America/Denver"},
+		{"Albuquerque", 35.0844, -106.6504, "US", "America/Denver"},
+		{"Phoenix", 33.4484, -112.0740, "US", "America/Phoenix"},
+		{"Detroit", 42.3314, -83.0458, "US", "America/Detroit"},
+		{"Windsor across the border from Detroit", 42.3149, -83.0364, "Canada", "America/Toronto"},
+		{"Sevilla", 37.3891, -5.9845, "Spain", "Europe/Madrid"},
+		{"Ceuta", 35.8894, -5.3213, "Spain", "Africa/Ceuta"},
+		{"Tijuana", 32.5149, -117.0382, "Mexico", "America/Tijuana"},
+	}
+	for _, tt := range tests {
+		if got := TimezoneAt(tt.lat, tt.lng, tt.country); got != tt.want {
+			t.Errorf("%s: TimezoneAt() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetTimezone_shouldGiveLocalWeekday(t *testing.T) {
+	tokyo := POI.Location{Latitude: 35.6762, Longitude: 139.6503, City: "Tokyo", Country: "Japan"}
+	SetTimezone(&tokyo)
+
+	// Friday evening in California is already Saturday in Tokyo
+	californiaEvening := time.Date(2026, 10, 16, 21, 0, 0, 0, time.FixedZone("PDT", -7*3600))
+	if day := POI.WeekdayFromTime(tokyo.LocalTime(californiaEvening).Weekday()); day != POI.DateSaturday {
+		t.Errorf("expected Saturday in %s, got %s", tokyo.Timezone, day.String())
+	}
+
+	unknown := POI.Location{City: "Tokyo", Country: "Japan"}
+	SetTimezone(&unknown)
+	if unknown.Timezone != "" || !unknown.LocalTime(californiaEvening).Equal(californiaEvening) {
+		t.Errorf("expected no timezone for a location without coordinates, got %q", unknown.Timezone)
+	}
+}
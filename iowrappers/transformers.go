@@ -58,6 +58,7 @@ func toCity(city gogeonames.City) (City, error) {
 		Population: city.Population,
 		AdminArea1: city.AdminArea1,
 		Country:    city.Country,
+		Timezone:   TimezoneAt(lat, lng, city.Country),
 	}, nil
 }
 
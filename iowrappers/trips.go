@@ -0,0 +1,97 @@
+package iowrappers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+)
+
+const (
+	TravelTripsRedisCacheKeyPrefix = "travel_trips"
+	TravelTripRedisCacheKeyPrefix  = "travel_trip"
+)
+
+// TripSolutionRecord groups the per-day plans of a multi-day trip. Each day is also stored as a
+// regular travel_plan:<id> record so the existing plan details page works for a single day.
+type TripSolutionRecord struct {
+	ID          string                   `json:"id"`
+	StartDate   string                   `json:"start_date"`
+	Dates       []string                 `json:"dates"`
+	Days        []PlanningSolutionRecord `json:"days"`
+	Score       float64                  `json:"score"`
+	Destination POI.Location             `json:"destination"`
+}
+
+// TravelTripsCacheKey derives the trip cache key from the per-day plan requests. It reuses the
+// TravelPlansCacheKey layout for location and price level, then appends the slot index of every
+// day, so a trip key never collides with a single-day key or with a trip of a different length.
+func TravelTripsCacheKey(days []*PlanningSolutionsSaveRequest) (string, error) {
+	if len(days) == 0 {
+		return "", errors.New("a trip needs at least one day")
+	}
+
+	// closures are indexed per day below
+	firstDay := *days[0]
+	firstDay.ClosureDate = ""
+	dayKey, err := TravelPlansCacheKey(&firstDay)
+	if err != nil {
+		return "", err
+	}
+	// drop the travel_plans prefix and the first day's slot index, keeping country:region:city:price and the travel mode
+	fields := strings.Split(dayKey, ":")
+	parts := append([]string{TravelTripsRedisCacheKeyPrefix}, fields[1:len(fields)-1]...)
+	parts = append(parts, strconv.Itoa(len(days)))
+
+	for _, day := range days {
+		slotsIndex, err := timeSlotsIndex(day.PlaceCategories, day.Intervals, day.Weekdays)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, slotsIndex)
+		if day.ClosureDate != "" {
+			parts = append(parts, "closed-"+day.ClosureDate)
+		}
+	}
+	return strings.ToLower(strings.Join(parts, ":")), nil
+}
+
+// SaveTripSolution stores the trip under its cache key and under travel_trip:<id>, together with
+// every per-day plan record. All keys share PlanningSolutionsExpirationTime.
+func (r *RedisClient) SaveTripSolution(ctx context.Context, cacheKey string, trip *TripSolutionRecord) error {
+	tripJson, err := json.Marshal(trip)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Get().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, day := range trip.Days {
+			dayJson, err := json.Marshal(day)
+			if err != nil {
+				return err
+			}
+			pipe.Set(ctx, strings.Join([]string{TravelPlanRedisCacheKeyPrefix, day.ID}, ":"), dayJson, PlanningSolutionsExpirationTime)
+		}
+		pipe.Set(ctx, strings.Join([]string{TravelTripRedisCacheKeyPrefix, trip.ID}, ":"), tripJson, PlanningSolutionsExpirationTime)
+		pipe.Set(ctx, cacheKey, tripJson, PlanningSolutionsExpirationTime)
+		return nil
+	})
+	return err
+}
+
+// TripSolution returns the cached trip for a trip cache key, or redis.Nil when there is none.
+func (r *RedisClient) TripSolution(ctx context.Context, cacheKey string) (*TripSolutionRecord, error) {
+	trip := &TripSolutionRecord{}
+	if err := r.FetchSingleRecord(ctx, cacheKey, trip); err != nil {
+		return nil, err
+	}
+	if len(trip.Days) == 0 || len(trip.Days) != len(trip.Dates) {
+		return nil, fmt.Errorf("cached trip with key %s has invalid days", cacheKey)
+	}
+	return trip, nil
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/mail"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -52,6 +53,7 @@ const (
 	UserSavedTravelPlansPrefix = "user_saved_travel_plans"
 	UserSavedTravelPlanPrefix  = "user_saved_travel_plan"
 	UserSearchHistoryPrefix    = "users:search_history"
+	UserBlockedPlacesPrefix    = "user_blocked_places"
 
 	//UserNamesKey maps usernames to IDs
 	UserNamesKey = "user_names"
@@ -581,3 +583,34 @@ func (r *RedisClient) UserFeedback(ctx context.Context, fb *UserFeedback) error
 	}
 	return nil
 }
+
+func userBlockedPlacesKey(userID string) string {
+	return strings.Join([]string{UserBlockedPlacesPrefix, "user", userID}, ":")
+}
+
+// BlockPlace adds a place to the user's blocklist. Blocked places are never used in plans made for the user.
+func (r *RedisClient) BlockPlace(ctx context.Context, userID, placeID string) error {
+	if userID == "" || placeID == "" {
+		return errors.New("user ID and place ID are required to block a place")
+	}
+	return r.Get().SAdd(ctx, userBlockedPlacesKey(userID), placeID).Err()
+}
+
+// UnblockPlace removes a place from the user's blocklist and reports whether the place was blocked
+func (r *RedisClient) UnblockPlace(ctx context.Context, userID, placeID string) (bool, error) {
+	removed, err := r.Get().SRem(ctx, userBlockedPlacesKey(userID), placeID).Result()
+	if err != nil {
+		return false, err
+	}
+	return removed > 0, nil
+}
+
+// BlockedPlaces returns the sorted IDs of places blocked by the user
+func (r *RedisClient) BlockedPlaces(ctx context.Context, userID string) ([]string, error) {
+	placeIDs, err := r.Get().SMembers(ctx, userBlockedPlacesKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(placeIDs)
+	return placeIDs, nil
+}
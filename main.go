@@ -43,10 +43,31 @@ type Configurations struct {
 		} `yaml:"google_maps"`
 
 		PlanSolver struct {
-			SamePlaceDedupeCountLimit int  `yaml:"same_place_dedupe_count_limit"`
-			NearbyCitiesCountLimit    int  `yaml:"nearby_cities_count_limit"`
-			EnableMapsPhotoClient     bool `yaml:"enable_maps_photo_client"`
+			SamePlaceDedupeCountLimit int                `yaml:"same_place_dedupe_count_limit"`
+			NearbyCitiesCountLimit    int                `yaml:"nearby_cities_count_limit"`
+			EnableMapsPhotoClient     bool               `yaml:"enable_maps_photo_client"`
+			SearchStrategy            string             `yaml:"search_strategy"`
+			Scorers                   map[string]float64 `yaml:"scorers"`
+			DiversityLambda           float64            `yaml:"diversity_lambda"`
 		} `yaml:"plan_solver"`
+
+		Closures struct {
+			DataFile string `yaml:"data_file"`
+		} `yaml:"closures"`
+
+		JobQueue struct {
+			Backend            string `yaml:"backend"`
+			ReclaimIdleSeconds int    `yaml:"reclaim_idle_seconds"`
+		} `yaml:"job_queue"`
+
+		CacheWarming struct {
+			Enabled              bool `yaml:"enabled"`
+			IntervalMinutes      int  `yaml:"interval_minutes"`
+			DemandWindowHours    int  `yaml:"demand_window_hours"`
+			MaxCities            int  `yaml:"max_cities"`
+			DaysAhead            int  `yaml:"days_ahead"`
+			RefreshBeforeMinutes int  `yaml:"refresh_before_minutes"`
+		} `yaml:"cache_warming"`
 	} `yaml:"server"`
 }
 
@@ -61,6 +82,18 @@ func flattenConfig(configs *Configurations) map[string]interface{} {
 	flattenedConfigs["server:plan_solver:same_place_dedupe_count_limit"] = configs.Server.PlanSolver.SamePlaceDedupeCountLimit
 	flattenedConfigs["server:plan_solver:nearby_cities_count_limit"] = configs.Server.PlanSolver.NearbyCitiesCountLimit
 	flattenedConfigs["server:plan_solver:enable_maps_photo_client"] = configs.Server.PlanSolver.EnableMapsPhotoClient
+	flattenedConfigs["server:plan_solver:search_strategy"] = configs.Server.PlanSolver.SearchStrategy
+	flattenedConfigs["server:plan_solver:scorers"] = configs.Server.PlanSolver.Scorers
+	flattenedConfigs["server:plan_solver:diversity_lambda"] = configs.Server.PlanSolver.DiversityLambda
+	flattenedConfigs["server:closures:data_file"] = configs.Server.Closures.DataFile
+	flattenedConfigs["server:job_queue:backend"] = configs.Server.JobQueue.Backend
+	flattenedConfigs["server:job_queue:reclaim_idle_seconds"] = configs.Server.JobQueue.ReclaimIdleSeconds
+	flattenedConfigs["server:cache_warming:enabled"] = configs.Server.CacheWarming.Enabled
+	flattenedConfigs["server:cache_warming:interval_minutes"] = configs.Server.CacheWarming.IntervalMinutes
+	flattenedConfigs["server:cache_warming:demand_window_hours"] = configs.Server.CacheWarming.DemandWindowHours
+	flattenedConfigs["server:cache_warming:max_cities"] = configs.Server.CacheWarming.MaxCities
+	flattenedConfigs["server:cache_warming:days_ahead"] = configs.Server.CacheWarming.DaysAhead
+	flattenedConfigs["server:cache_warming:refresh_before_minutes"] = configs.Server.CacheWarming.RefreshBeforeMinutes
 	return flattenedConfigs
 }
 
@@ -126,6 +159,11 @@ func listenForShutDownServer(ch <-chan os.Signal, svr *manners.GracefulServer, m
 
 	myPlanner.Dispatcher.Run(context.Background())
 
+	warmingCtx, stopWarming := context.WithCancel(context.Background())
+	if myPlanner.CacheWarmer != nil {
+		go myPlanner.CacheWarmer.Run(warmingCtx)
+	}
+
 	go func() {
 		// wait for shut-down signal
 		<-ch
@@ -134,6 +172,7 @@ func listenForShutDownServer(ch <-chan os.Signal, svr *manners.GracefulServer, m
 		close(myPlanner.PlanningEvents)
 		wg.Wait()
 
+		stopWarming()
 		myPlanner.Dispatcher.Stop()
 	}()
 
@@ -8,6 +8,9 @@ import (
 
 const SelectionThreshold = -1
 
+// KnapsackDistanceNorm normalizes the distances between the places picked by the knapsack when scoring them
+const KnapsackDistanceNorm = 20000
+
 type knapsackNodeRecord struct {
 	timeUsed uint8
 	cost     uint
@@ -31,14 +34,15 @@ func (recordTable *knapsackRecordTable) Init(timeLimit uint8, budget uint) {
 	recordTable.SavedRecord[recordTable.getKey(0, 0)] = start
 }
 
+// costs range from 0 to the budget inclusive, hence budget+1 distinct cost values per time limit
 func (recordTable *knapsackRecordTable) getKey(timeLimit uint8, budget uint) (key uint) {
-	key = uint(timeLimit)*recordTable.budget + budget
+	key = uint(timeLimit)*(recordTable.budget+1) + budget
 	return
 }
 
 func (recordTable *knapsackRecordTable) getTimeLimitAndCost(key uint) (timeLimit uint8, budget uint) {
-	budget = key % recordTable.budget
-	timeLimit = uint8((key - budget) / recordTable.budget)
+	budget = key % (recordTable.budget + 1)
+	timeLimit = uint8((key - budget) / (recordTable.budget + 1))
 	return
 }
 
@@ -60,9 +64,15 @@ KnapsackV1 v2 uses sparse matrix like storage for step values and saves memory
 KnapsackV1 v1 is migrated to knapsack_old_test_only.go
 */
 func Knapsack(places []Place, interval TimeInterval, budget uint) (results []Place, totalCost uint, totalTimeSpent uint8) {
+	return KnapsackWithScore(places, interval, budget, func(places []Place) float64 { return Score(places, KnapsackDistanceNorm) })
+}
+
+// KnapsackWithScore is Knapsack with a custom objective. The default Score averages the place scores, which favors
+// a few excellent places, while an objective like TotalScore rewards filling the time window.
+func KnapsackWithScore(places []Place, interval TimeInterval, budget uint, score func([]Place) float64) (results []Place, totalCost uint, totalTimeSpent uint8) {
 	//Initialize knapsack data structures
 	var recordTable knapsackRecordTable
-	timeLimit := interval.EndHour - interval.StartHour
+	timeLimit := uint8(interval.Duration() / 60)
 	rt := &recordTable
 	rt.Init(timeLimit, budget)
 	optimalNode := knapsackNodeRecord{0, 0, SelectionThreshold, make([]Place, 0)}
@@ -73,15 +83,15 @@ func Knapsack(places []Place, interval TimeInterval, budget uint) (results []Pla
 		stayTime = POI.GetStayingTimeForLocationType(place.Type())
 		for key, record := range rt.SavedRecord {
 			currentTimeSpent, curCost := rt.getTimeLimitAndCost(key)
-			currentQueryStartTime, _ := interval.AddOffsetHours(currentTimeSpent)
+			currentQueryStartTime, _ := interval.AddOffsetMinutes(uint16(currentTimeSpent) * 60)
 			newTimeSpent := currentTimeSpent + uint8(stayTime)
 			newCost := curCost + uint(math.Ceil(place.Price))
-			if newTimeSpent <= rt.timeLimit && newCost <= budget && place.IsOpenBetween(currentQueryStartTime, uint8(stayTime)) {
+			if newTimeSpent <= rt.timeLimit && newCost <= budget && place.IsOpenBetween(currentQueryStartTime, uint16(stayTime)*60) {
 				newKey := rt.getKey(newTimeSpent, newCost)
 				newSolution := make([]Place, len(record.Solution))
 				copy(newSolution, record.Solution)
 				newSolution = append(newSolution, place)
-				newScore := Score(newSolution, 20000)
+				newScore := score(newSolution)
 				newRecord := knapsackNodeRecord{newTimeSpent, newCost, newScore, newSolution}
 				if alreadyRecord, ok := rt.NewRecord[newKey]; ok {
 					if alreadyRecord.score < newRecord.score {
@@ -31,7 +31,7 @@ func KnapsackMatrixCopy(dst [][]knapsackNode, src [][]knapsackNode) {
 }
 
 func KnapsackV1(places []Place, interval TimeInterval, budget uint) (results []Place) {
-	timeLimit := interval.EndHour - interval.StartHour
+	timeLimit := uint8(interval.Duration() / 60)
 	//INIT KNAPSACK MATRIX
 	current := make([][]knapsackNode, timeLimit+1)
 	for i := 0; i < int(timeLimit)+1; i++ {
@@ -59,7 +59,7 @@ func KnapsackV1(places []Place, interval TimeInterval, budget uint) (results []P
 		KnapsackMatrixCopy(current, next)
 		staytime = POI.GetStayingTimeForLocationType(places[k].Type())
 		//INITIALIZE 0,0
-		if uint8(staytime) <= timeLimit && int(math.Ceil(places[k].Price)) <= int(budget) && places[k].IsOpenBetween(interval, uint8(staytime)) {
+		if uint8(staytime) <= timeLimit && int(math.Ceil(places[k].Price)) <= int(budget) && places[k].IsOpenBetween(interval, uint16(staytime)*60) {
 			tempPlaces = append(current[0][0].solution, places[k])
 			tempScore = Score(tempPlaces, 20000)
 			if tempScore > next[staytime][int(math.Ceil(places[k].PlacePrice()))].score {
@@ -75,8 +75,8 @@ func KnapsackV1(places []Place, interval TimeInterval, budget uint) (results []P
 		for i := 0; i < int(timeLimit); i++ {
 			for j := 0; j < int(budget); j++ {
 				if current[i][j].score > SelectionThreshold {
-					currentQueryStart, _ := interval.AddOffsetHours(uint8(i))
-					if i+int(staytime) <= int(timeLimit) && j+int(math.Ceil(places[k].Price)) <= int(budget) && places[k].IsOpenBetween(currentQueryStart, uint8(staytime)) {
+					currentQueryStart, _ := interval.AddOffsetMinutes(uint16(i) * 60)
+					if i+int(staytime) <= int(timeLimit) && j+int(math.Ceil(places[k].Price)) <= int(budget) && places[k].IsOpenBetween(currentQueryStart, uint16(staytime)*60) {
 						tempi = i + int(staytime)
 						tempj = j + int(math.Ceil(places[k].PlacePrice()))
 						tempPlaces = append(current[i][j].solution, places[k])
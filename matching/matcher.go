@@ -3,6 +3,8 @@ package matching
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 
 	"github.com/weihesdlegend/Vacation-planner/POI"
 	"github.com/weihesdlegend/Vacation-planner/iowrappers"
@@ -21,6 +23,8 @@ const (
 	FilterByTimePeriod              FilterCriteria = "filterByTimePeriod"
 	FilterByPriceRange              FilterCriteria = "filterByPriceRange"
 	FilterByUserRating              FilterCriteria = "filterByUserRating"
+	FilterByExcludedPlaces          FilterCriteria = "filterByExcludedPlaces"
+	FilterByLocationTypes           FilterCriteria = "filterByLocationTypes"
 )
 
 type Request struct {
@@ -29,6 +33,8 @@ type Request struct {
 	Category           POI.PlaceCategory
 	UsePreciseLocation bool
 	PriceLevel         POI.PriceLevel
+	// Keyword searches places of a brand instead of places of the category's place types
+	Keyword string
 }
 
 type FilterRequest struct {
@@ -52,12 +58,15 @@ func (matcher MatcherForPriceRange) Match(req *FilterRequest) ([]Place, error) {
 
 	priceRangeFilterParams := filterParams.(PriceRangeFilterParams)
 
-	// POI data from Google API does not have price range, therefore we only filter catering places on price
-	if priceRangeFilterParams.Category == POI.PlaceCategoryEatery {
+	// POI data from Google API only has reliable price levels for catering places, therefore we only filter them on
+	// price and keep places of the other categories regardless of the requested price level
+	switch priceRangeFilterParams.Category {
+	case POI.PlaceCategoryEatery:
 		return filterPlacesOnPriceLevel(req.Places, priceRangeFilterParams.PriceLevel), nil
+	case POI.PlaceCategoryVisit, POI.PlaceCategoryShopping, POI.PlaceCategoryLodging, POI.PlaceCategoryWellness:
+		return req.Places, nil
 	}
-
-	return req.Places, nil
+	return nil, fmt.Errorf("price range matcher received unknown place category %s", priceRangeFilterParams.Category)
 }
 
 type PriceRangeFilterParams struct {
@@ -68,6 +77,8 @@ type PriceRangeFilterParams struct {
 type TimeFilterParams struct {
 	Day          POI.Weekday
 	TimeInterval POI.TimeInterval
+	// Closures close places on the travel date whatever their weekly opening hours, e.g. museums on public holidays
+	Closures POI.Closures
 }
 
 type MatcherForTime struct {
@@ -81,6 +92,9 @@ func NearbySearchForCategory(ctx context.Context, searcher iowrappers.SearchClie
 		MinNumResults:      MinResultsForTimePeriodMatching,
 		UsePreciseLocation: req.UsePreciseLocation,
 		PriceLevel:         req.PriceLevel,
+		Keyword:            req.Keyword,
+		// keep places merely related to the brand out of the brand-scoped cache
+		StrictNameMatch: req.Keyword != "",
 	}
 	basicPlaces, err := searcher.NearbySearch(ctx, placeSearchRequest)
 	if err != nil {
@@ -107,18 +121,21 @@ func (m MatcherForTime) Match(req *FilterRequest) ([]Place, error) {
 	}
 	timeFilterParams := filterParams.(TimeFilterParams)
 
-	return filterPlacesOnTime(req.Places, timeFilterParams.Day, timeFilterParams.TimeInterval), nil
+	return filterPlacesOnTime(req.Places, timeFilterParams.Day, timeFilterParams.TimeInterval, timeFilterParams.Closures), nil
 }
 
-func filterPlacesOnTime(places []Place, day POI.Weekday, interval POI.TimeInterval) []Place {
+func filterPlacesOnTime(places []Place, day POI.Weekday, interval POI.TimeInterval, closures POI.Closures) []Place {
 	var results []Place
 	for _, place := range places {
-		openingHourForDay := place.Hours()[day]
-		timeInterval, err := POI.ParseTimeInterval(openingHourForDay)
+		if _, closed := closures.ClosureOf(place.Id(), place.Category); closed {
+			continue
+		}
+		// slots of a night out run past midnight into the hours of the next day
+		openIntervals, err := place.Place.OpenIntervalsAround(day)
 		if err != nil {
 			continue
 		}
-		if timeInterval.Inclusive(&interval) {
+		if openIntervals.Include(&interval) {
 			results = append(results, place)
 		}
 	}
@@ -162,3 +179,78 @@ func (m MatcherForUserRatings) Match(req *FilterRequest) ([]Place, error) {
 	}
 	return iowrappers.Filter(req.Places, userRatingCountFilter(params.MinUserRatings)), nil
 }
+
+type ExcludedPlacesFilterParams struct {
+	PlaceIDs []string
+}
+
+// MatcherForExcludedPlaces drops places blocked by the user making the request.
+// Requests without a user carry no excluded places params and keep all places.
+type MatcherForExcludedPlaces struct {
+}
+
+func (m MatcherForExcludedPlaces) MatcherName() string {
+	return "Matcher for Excluded Places"
+}
+
+func (m MatcherForExcludedPlaces) Match(req *FilterRequest) ([]Place, error) {
+	filterParams, exists := req.Params[FilterByExcludedPlaces]
+	if !exists {
+		return req.Places, nil
+	}
+	params, ok := filterParams.(ExcludedPlacesFilterParams)
+	if !ok {
+		return nil, errors.New("excluded places matcher received wrong filter params")
+	}
+	if len(params.PlaceIDs) == 0 {
+		return req.Places, nil
+	}
+
+	excluded := make(map[string]bool, len(params.PlaceIDs))
+	for _, id := range params.PlaceIDs {
+		excluded[id] = true
+	}
+	return iowrappers.Filter(req.Places, func(place Place) bool { return !excluded[place.Id()] }), nil
+}
+
+type LocationTypesFilterParams struct {
+	LocationTypes []POI.LocationType
+}
+
+// MatcherForLocationTypes keeps places of any of the requested place types, e.g. bakeries among eateries.
+// Places are matched on their Google Maps types, places cached before the types were recorded on the type they were
+// searched with. Requests without location types params keep all places.
+type MatcherForLocationTypes struct {
+}
+
+func (m MatcherForLocationTypes) MatcherName() string {
+	return "Matcher for Location Types"
+}
+
+func (m MatcherForLocationTypes) Match(req *FilterRequest) ([]Place, error) {
+	filterParams, exists := req.Params[FilterByLocationTypes]
+	if !exists {
+		return req.Places, nil
+	}
+	params, ok := filterParams.(LocationTypesFilterParams)
+	if !ok {
+		return nil, errors.New("location types matcher received wrong filter params")
+	}
+	if len(params.LocationTypes) == 0 {
+		return req.Places, nil
+	}
+
+	return iowrappers.Filter(req.Places, func(place Place) bool { return hasLocationType(place, params.LocationTypes) }), nil
+}
+
+func hasLocationType(place Place, locationTypes []POI.LocationType) bool {
+	if len(place.Place.Types) == 0 {
+		return slices.Contains(locationTypes, place.Type())
+	}
+	for _, placeType := range place.Place.Types {
+		if slices.Contains(locationTypes, POI.LocationType(placeType)) {
+			return true
+		}
+	}
+	return false
+}
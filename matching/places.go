@@ -63,8 +63,10 @@ func (place *Place) SetCategory(category POI.PlaceCategory) {
 	place.Category = category
 }
 
-func (place *Place) IsOpenBetween(interval TimeInterval, stayingDurationInHour uint8) bool {
-	if interval.StartHour+stayingDurationInHour > interval.EndHour {
+// IsOpenBetween tells whether the place is open for stayingMinutes from the start of the interval, which must end by
+// the end of the interval
+func (place *Place) IsOpenBetween(interval TimeInterval, stayingMinutes uint16) bool {
+	if uint32(interval.Start)+uint32(stayingMinutes) > uint32(interval.End) {
 		return false
 	}
 
@@ -73,27 +75,21 @@ func (place *Place) IsOpenBetween(interval TimeInterval, stayingDurationInHour u
 		return false
 	}
 
-	openingHour := hours[interval.Day]
-	if openingHour == "" {
+	if hours[interval.Day] == "" {
 		// No hours data available; assume open to avoid false negatives
 		return true
 	}
 
-	placeInterval, err := POI.ParseTimeInterval(openingHour)
+	openIntervals, err := place.Place.OpenIntervalsAround(interval.Day)
 	if err != nil {
 		return false
 	}
 
-	// Closed marker (ParseTimeInterval returns 255,255 for "Closed")
-	if placeInterval.Start == 255 && placeInterval.End == 255 {
-		return false
-	}
-
 	requestedSlot := POI.TimeInterval{
-		Start: POI.Hour(interval.StartHour),
-		End:   POI.Hour(interval.StartHour + stayingDurationInHour),
+		Start: interval.Start,
+		End:   interval.Start + POI.ClockTime(stayingMinutes),
 	}
-	return placeInterval.Inclusive(&requestedSlot)
+	return openIntervals.Include(&requestedSlot)
 }
 
 func CreatePlace(place POI.Place, category POI.PlaceCategory) Place {
@@ -4,6 +4,7 @@ package matching
 import (
 	"math"
 
+	"github.com/weihesdlegend/Vacation-planner/POI"
 	"github.com/weihesdlegend/Vacation-planner/utils"
 	"gonum.org/v1/gonum/stat"
 )
@@ -24,14 +25,22 @@ func Score(places []Place, distNorm int) float64 {
 	return avgScore - avgDistance
 }
 
+// TotalScore sums up the place scores instead of averaging them, with the same distance penalty for every place,
+// so that a plan with more good places scores higher
+func TotalScore(places []Place, distNorm int) float64 {
+	return float64(len(places)) * Score(places, distNorm)
+}
+
 func PlaceScore(place Place) float64 {
-	var boostFactor float64
-	if place.PlacePrice() == 0 {
-		boostFactor = float64(place.Rating()) / MaxPlaceRating
-	} else {
-		boostFactor = float64(place.Rating()) / place.PlacePrice()
+	return math.Log10(float64(1+place.UserRatingsCount())) * boostFactor(float64(place.Rating()), place.PlacePrice())
+}
+
+// boostFactor is the rating to price ratio, free places are compared with the maximum rating instead
+func boostFactor(rating, price float64) float64 {
+	if price == 0 {
+		return rating / MaxPlaceRating
 	}
-	return math.Log10(float64(1+place.UserRatingsCount())) * boostFactor
+	return rating / price
 }
 
 // calculate Haversine distances between places
@@ -55,3 +64,40 @@ func avgPlacesScore(places []Place) float64 {
 	}
 	return stat.Mean(placeScores, nil)
 }
+
+// ScoreWithTravel replaces the distance term of Score with the average travel time between consecutive places,
+// normalised by the time needed to drive across the search radius
+func ScoreWithTravel(places []Place, legs []POI.TravelLeg, distNorm int) float64 {
+	return PlanScore(DefaultScorer{}, places, legs, distNorm)
+}
+
+// AnchorDistancePenalty is the average distance from each place not pinned by the user to the closest pinned place,
+// normalised by distNorm. It pulls the remaining places of a plan toward the places the user has already chosen.
+func AnchorDistancePenalty(places []Place, pinned []bool, distNorm int) float64 {
+	var anchors [][]float64
+	for idx, place := range places {
+		if idx < len(pinned) && pinned[idx] {
+			anchors = append(anchors, []float64{place.Location().Latitude, place.Location().Longitude})
+		}
+	}
+	if len(anchors) == 0 {
+		return 0
+	}
+
+	distances := make([]float64, 0, len(places))
+	for idx, place := range places {
+		if idx < len(pinned) && pinned[idx] {
+			continue
+		}
+		location := []float64{place.Location().Latitude, place.Location().Longitude}
+		closest := math.MaxFloat64
+		for _, anchor := range anchors {
+			closest = math.Min(closest, utils.HaversineDist(location, anchor))
+		}
+		distances = append(distances, closest)
+	}
+	if len(distances) == 0 {
+		return 0
+	}
+	return stat.Mean(distances, nil) / float64(distNorm)
+}
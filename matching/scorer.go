@@ -0,0 +1,232 @@
+package matching
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"gonum.org/v1/gonum/stat"
+)
+
+// Scorer rates places and the travel between them. A plan scores the average PlaceScore of its places minus the
+// TravelPenalty of its legs, see PlanScore.
+type Scorer interface {
+	PlaceScore(place Place) float64
+	// TravelPenalty must not decrease when a leg gets longer, the best-first plan search relies on it to bound the
+	// score of partial plans by treating the legs not yet chosen as zero-minute legs.
+	TravelPenalty(legs []POI.TravelLeg, distNorm int) float64
+}
+
+const (
+	ScorerNameDefault       = "default"
+	ScorerNameBayesian      = "bayesian"
+	ScorerNameDistanceHeavy = "distance_heavy"
+)
+
+const (
+	// BayesianPriorRating is the rating a place is assumed to have before any review
+	BayesianPriorRating = 4.0
+	// BayesianPriorCount is the number of reviews the prior rating is worth
+	BayesianPriorCount = 50
+	// DistanceHeavyTravelWeight multiplies the travel penalty of the distance-heavy scorer
+	DistanceHeavyTravelWeight = 3.0
+)
+
+// PlanScore scores places visited in order with the travel legs between them
+func PlanScore(scorer Scorer, places []Place, legs []POI.TravelLeg, distNorm int) float64 {
+	placeScores := make([]float64, len(places))
+	for idx, place := range places {
+		placeScores[idx] = scorer.PlaceScore(place)
+	}
+	if len(places) == 1 || len(legs) == 0 {
+		return placeScores[0]
+	}
+	return stat.Mean(placeScores, nil) - scorer.TravelPenalty(legs, distNorm)
+}
+
+// placeScoreFactors is implemented by scorers whose place score is a review count factor times a boost factor
+type placeScoreFactors interface {
+	placeScoreFactors(place Place) (reviewCount, boost float64)
+}
+
+// ExplainPlanScore breaks the PlanScore of a plan down into the scores of its places and its penalties
+func ExplainPlanScore(scorer Scorer, places []Place, legs []POI.TravelLeg, distNorm int) POI.ScoreBreakdown {
+	return ExplainAnchoredPlanScore(scorer, places, legs, Anchors{}, distNorm)
+}
+
+// ExplainAnchoredPlanScore is ExplainPlanScore for a plan that starts and ends at anchors. The travel from the start
+// anchor and to the end anchor counts toward the travel penalty and the distance penalty like the legs between places,
+// so a plan with a single place is penalized for its distance to the anchors as well.
+func ExplainAnchoredPlanScore(scorer Scorer, places []Place, legs []POI.TravelLeg, anchors Anchors, distNorm int) POI.ScoreBreakdown {
+	breakdown := POI.ScoreBreakdown{Places: make([]POI.PlaceScoreBreakdown, len(places))}
+	factors, hasFactors := scoreFactors(scorer)
+	placeScores := make([]float64, len(places))
+	for idx, place := range places {
+		placeScores[idx] = scorer.PlaceScore(place)
+		breakdown.Places[idx] = POI.PlaceScoreBreakdown{PlaceID: place.Id(), PlaceScore: placeScores[idx]}
+		if hasFactors {
+			breakdown.Places[idx].ReviewCountFactor, breakdown.Places[idx].BoostFactor = factors.placeScoreFactors(place)
+		}
+	}
+	if len(places) == 0 {
+		return breakdown
+	}
+
+	breakdown.AveragePlaceScore = stat.Mean(placeScores, nil)
+	if len(places) > 1 && len(legs) > 0 || anchors.StartLeg != nil || anchors.EndLeg != nil {
+		breakdown.TravelPenalty = scorer.TravelPenalty(anchors.legs(legs), distNorm)
+		breakdown.DistancePenalty = stat.Mean(anchors.distances(places), nil) / float64(distNorm)
+	}
+	breakdown.Score = breakdown.AveragePlaceScore - breakdown.TravelPenalty
+	return breakdown
+}
+
+// DefaultScorer is the original formula: the log of the review count times the rating to price ratio, minus the
+// average travel time normalised by the time needed to drive across the search radius
+type DefaultScorer struct{}
+
+func (DefaultScorer) PlaceScore(place Place) float64 {
+	return PlaceScore(place)
+}
+
+func (DefaultScorer) placeScoreFactors(place Place) (reviewCount, boost float64) {
+	return math.Log10(float64(1 + place.UserRatingsCount())), boostFactor(float64(place.Rating()), place.PlacePrice())
+}
+
+func (DefaultScorer) TravelPenalty(legs []POI.TravelLeg, distNorm int) float64 {
+	if len(legs) == 0 {
+		return 0
+	}
+	minutes := make([]float64, len(legs))
+	for idx, leg := range legs {
+		minutes[idx] = float64(leg.Minutes)
+	}
+	travelNorm := float64(distNorm) / POI.DrivingSpeed / 60
+	return stat.Mean(minutes, nil) / travelNorm
+}
+
+// BayesianScorer replaces the rating of the default formula with its Bayesian average, which pulls the ratings of
+// places with few reviews toward PriorRating so that a handful of perfect reviews cannot outrank a well-known place
+type BayesianScorer struct {
+	PriorRating float64
+	PriorCount  float64
+}
+
+func (s BayesianScorer) PlaceScore(place Place) float64 {
+	reviewCount, boost := s.placeScoreFactors(place)
+	return reviewCount * boost
+}
+
+func (s BayesianScorer) placeScoreFactors(place Place) (reviewCount, boost float64) {
+	count := float64(place.UserRatingsCount())
+	rating := (s.PriorRating*s.PriorCount + float64(place.Rating())*count) / (s.PriorCount + count)
+	return math.Log10(1 + count), boostFactor(rating, place.PlacePrice())
+}
+
+func (BayesianScorer) TravelPenalty(legs []POI.TravelLeg, distNorm int) float64 {
+	return DefaultScorer{}.TravelPenalty(legs, distNorm)
+}
+
+// DistanceHeavyScorer keeps the default place score and weighs travel TravelWeight times as much, which favors
+// compact plans over plans with slightly better places
+type DistanceHeavyScorer struct {
+	TravelWeight float64
+}
+
+func (DistanceHeavyScorer) PlaceScore(place Place) float64 {
+	return PlaceScore(place)
+}
+
+func (DistanceHeavyScorer) placeScoreFactors(place Place) (reviewCount, boost float64) {
+	return DefaultScorer{}.placeScoreFactors(place)
+}
+
+func (s DistanceHeavyScorer) TravelPenalty(legs []POI.TravelLeg, distNorm int) float64 {
+	return s.TravelWeight * DefaultScorer{}.TravelPenalty(legs, distNorm)
+}
+
+// WeightedScorer is a weighted sum of scorers
+type WeightedScorer struct {
+	Scorers []Scorer
+	Weights []float64
+}
+
+func (s WeightedScorer) PlaceScore(place Place) float64 {
+	var score float64
+	for idx, scorer := range s.Scorers {
+		score += s.Weights[idx] * scorer.PlaceScore(place)
+	}
+	return score
+}
+
+func (s WeightedScorer) TravelPenalty(legs []POI.TravelLeg, distNorm int) float64 {
+	var penalty float64
+	for idx, scorer := range s.Scorers {
+		penalty += s.Weights[idx] * scorer.TravelPenalty(legs, distNorm)
+	}
+	return penalty
+}
+
+// ProximityScorer keeps the place score of Scorer and multiplies its travel penalty by ProximityWeight, so that the
+// preference for places close to each other can be turned up, down or off without changing how places compare
+type ProximityScorer struct {
+	Scorer
+	ProximityWeight float64
+}
+
+func (s ProximityScorer) TravelPenalty(legs []POI.TravelLeg, distNorm int) float64 {
+	return s.ProximityWeight * s.Scorer.TravelPenalty(legs, distNorm)
+}
+
+// scoreFactors returns the place score factors of a scorer, looking through the travel weight of a ProximityScorer
+func scoreFactors(scorer Scorer) (placeScoreFactors, bool) {
+	if proximity, ok := scorer.(ProximityScorer); ok {
+		scorer = proximity.Scorer
+	}
+	factors, ok := scorer.(placeScoreFactors)
+	return factors, ok
+}
+
+// NewScorer creates a scorer from scorer names and their weights. A single scorer is returned as is, several scorers
+// are combined into a WeightedScorer. No weight selects the DefaultScorer.
+func NewScorer(weights map[string]float64) (Scorer, error) {
+	names := make([]string, 0, len(weights))
+	for name, weight := range weights {
+		if weight < 0 {
+			return nil, fmt.Errorf("weight of scorer %s cannot be negative", name)
+		}
+		if weight > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		if len(weights) > 0 {
+			return nil, fmt.Errorf("at least one scorer needs a positive weight")
+		}
+		return DefaultScorer{}, nil
+	}
+	// sort names so that the order of summing up scores does not depend on map iteration
+	slices.Sort(names)
+
+	combined := WeightedScorer{}
+	for _, name := range names {
+		var scorer Scorer
+		switch name {
+		case ScorerNameDefault:
+			scorer = DefaultScorer{}
+		case ScorerNameBayesian:
+			scorer = BayesianScorer{PriorRating: BayesianPriorRating, PriorCount: BayesianPriorCount}
+		case ScorerNameDistanceHeavy:
+			scorer = DistanceHeavyScorer{TravelWeight: DistanceHeavyTravelWeight}
+		default:
+			return nil, fmt.Errorf("unknown scorer %s", name)
+		}
+		combined.Scorers = append(combined.Scorers, scorer)
+		combined.Weights = append(combined.Weights, weights[name])
+	}
+	if len(names) == 1 && weights[names[0]] == 1 {
+		return combined.Scorers[0], nil
+	}
+	return combined, nil
+}
@@ -0,0 +1,143 @@
+package matching
+
+import (
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+)
+
+func TestBayesianScorer_shouldShrinkRatingsWithFewReviews(t *testing.T) {
+	scorer := BayesianScorer{PriorRating: BayesianPriorRating, PriorCount: BayesianPriorCount}
+	fewPerfectReviews := Place{Place: &POI.Place{UserRatingsTotal: 9, Rating: 5.0}, Price: 0}
+	fewGoodReviews := Place{Place: &POI.Place{UserRatingsTotal: 9, Rating: 4.5}, Price: 0}
+
+	if got := scorer.PlaceScore(fewPerfectReviews); got >= PlaceScore(fewPerfectReviews) {
+		t.Errorf("expected the Bayesian score %v to be below the default score %v", got, PlaceScore(fewPerfectReviews))
+	}
+	// ratings above the prior are pulled down, so a 0.5 rating lead shrinks with few reviews
+	defaultGap := PlaceScore(fewPerfectReviews) - PlaceScore(fewGoodReviews)
+	bayesianGap := scorer.PlaceScore(fewPerfectReviews) - scorer.PlaceScore(fewGoodReviews)
+	if bayesianGap >= defaultGap {
+		t.Errorf("expected the Bayesian gap %v to be smaller than the default gap %v", bayesianGap, defaultGap)
+	}
+}
+
+func TestDistanceHeavyScorer_shouldWeighTravelMore(t *testing.T) {
+	legs := []POI.TravelLeg{{Minutes: 20}, {Minutes: 10}}
+	defaultPenalty := DefaultScorer{}.TravelPenalty(legs, 10000)
+	heavyPenalty := DistanceHeavyScorer{TravelWeight: DistanceHeavyTravelWeight}.TravelPenalty(legs, 10000)
+	if heavyPenalty != DistanceHeavyTravelWeight*defaultPenalty {
+		t.Errorf("expected penalty %v, got %v", DistanceHeavyTravelWeight*defaultPenalty, heavyPenalty)
+	}
+}
+
+func TestProximityScorer_shouldScaleTravelAndKeepFactors(t *testing.T) {
+	legs := []POI.TravelLeg{{Minutes: 20}, {Minutes: 10}}
+	places := []Place{
+		{Place: &POI.Place{UserRatingsTotal: 99, Rating: 4.0}, Price: 0},
+		{Place: &POI.Place{UserRatingsTotal: 999, Rating: 4.0}, Price: 2},
+		{Place: &POI.Place{UserRatingsTotal: 9, Rating: 5.0}, Price: 1},
+	}
+	scorer := ProximityScorer{Scorer: DefaultScorer{}, ProximityWeight: 0.5}
+	defaultPenalty := DefaultScorer{}.TravelPenalty(legs, 10000)
+	if got := scorer.TravelPenalty(legs, 10000); got != 0.5*defaultPenalty {
+		t.Errorf("expected penalty %v, got %v", 0.5*defaultPenalty, got)
+	}
+
+	breakdown := ExplainPlanScore(scorer, places, legs, 10000)
+	if breakdown.Places[0].ReviewCountFactor == 0 {
+		t.Error("expected the score factors of the wrapped scorer")
+	}
+	off := ProximityScorer{Scorer: DefaultScorer{}}
+	if ExplainPlanScore(off, places, legs, 10000).Score != breakdown.AveragePlaceScore {
+		t.Error("expected a zero proximity weight to rank plans by place scores alone")
+	}
+}
+
+func TestPlanScore_shouldMatchScoreWithTravel(t *testing.T) {
+	places := []Place{
+		{Place: &POI.Place{UserRatingsTotal: 99, Rating: 4.0}, Price: 0},
+		{Place: &POI.Place{UserRatingsTotal: 999, Rating: 4.0}, Price: 2},
+	}
+	legs := []POI.TravelLeg{{Minutes: 15}}
+	if got, want := PlanScore(DefaultScorer{}, places, legs, 10000), ScoreWithTravel(places, legs, 10000); got != want {
+		t.Errorf("PlanScore() = %v, want %v", got, want)
+	}
+}
+
+func TestNewScorer(t *testing.T) {
+	if scorer, err := NewScorer(nil); err != nil || scorer != (DefaultScorer{}) {
+		t.Errorf("expected the default scorer without weights, got %v, %v", scorer, err)
+	}
+	if scorer, err := NewScorer(map[string]float64{ScorerNameBayesian: 1}); err != nil {
+		t.Error(err)
+	} else if _, ok := scorer.(BayesianScorer); !ok {
+		t.Errorf("expected a Bayesian scorer, got %T", scorer)
+	}
+
+	scorer, err := NewScorer(map[string]float64{ScorerNameDefault: 0.5, ScorerNameDistanceHeavy: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	place := Place{Place: &POI.Place{UserRatingsTotal: 999, Rating: 4.0}, Price: 2}
+	if got := scorer.PlaceScore(place); got != PlaceScore(place) {
+		t.Errorf("expected weights summing to one to keep the place score %v, got %v", PlaceScore(place), got)
+	}
+
+	for _, weights := range []map[string]float64{{"popularity": 1}, {ScorerNameDefault: -1}, {ScorerNameDefault: 0}} {
+		if _, err = NewScorer(weights); err == nil {
+			t.Errorf("expected an error for weights %v", weights)
+		}
+	}
+}
+
+func TestExplainPlanScore_shouldAddUpToPlanScore(t *testing.T) {
+	places := []Place{
+		{Place: &POI.Place{ID: "park", UserRatingsTotal: 99, Rating: 4.0, Location: POI.Location{Latitude: 37.7880, Longitude: -122.4075}}, Price: 0},
+		{Place: &POI.Place{ID: "pizza", UserRatingsTotal: 999, Rating: 4.0, Location: POI.Location{Latitude: 37.7906, Longitude: -122.4058}}, Price: 2},
+	}
+	legs := []POI.TravelLeg{{Minutes: 15}}
+	for _, scorer := range []Scorer{DefaultScorer{}, BayesianScorer{PriorRating: BayesianPriorRating, PriorCount: BayesianPriorCount}} {
+		breakdown := ExplainPlanScore(scorer, places, legs, 10000)
+		if want := PlanScore(scorer, places, legs, 10000); breakdown.Score != want {
+			t.Errorf("%T: breakdown score %v, want %v", scorer, breakdown.Score, want)
+		}
+		for idx, place := range breakdown.Places {
+			if place.PlaceID != places[idx].Id() || place.ReviewCountFactor*place.BoostFactor != place.PlaceScore {
+				t.Errorf("%T: place %d factors do not explain its score: %+v", scorer, idx, place)
+			}
+		}
+		if breakdown.DistancePenalty <= 0 {
+			t.Errorf("%T: expected a distance penalty, got %v", scorer, breakdown.DistancePenalty)
+		}
+	}
+}
+
+func TestExplainAnchoredPlanScore_shouldPenalizeTravelToAnchors(t *testing.T) {
+	places := []Place{
+		{Place: &POI.Place{ID: "park", UserRatingsTotal: 99, Rating: 4.0, Location: POI.Location{Latitude: 37.7880, Longitude: -122.4075}}, Price: 0},
+		{Place: &POI.Place{ID: "pizza", UserRatingsTotal: 999, Rating: 4.0, Location: POI.Location{Latitude: 37.7906, Longitude: -122.4058}}, Price: 2},
+	}
+	legs := TravelLegs(places, POI.TravelModeDriving)
+	near := &POI.Location{Latitude: 37.7890, Longitude: -122.4070}
+	far := &POI.Location{Latitude: 37.6890, Longitude: -122.4070}
+
+	plain := ExplainPlanScore(DefaultScorer{}, places, legs, 10000)
+	if anchored := ExplainAnchoredPlanScore(DefaultScorer{}, places, legs, Anchors{}, 10000); anchored.Score != plain.Score {
+		t.Errorf("expected no anchors to keep the score %v, got %v", plain.Score, anchored.Score)
+	}
+
+	nearHotel := ExplainAnchoredPlanScore(DefaultScorer{}, places, legs, NewAnchors(places, near, near, POI.TravelModeDriving), 10000)
+	farHotel := ExplainAnchoredPlanScore(DefaultScorer{}, places, legs, NewAnchors(places, far, far, POI.TravelModeDriving), 10000)
+	if farHotel.Score >= nearHotel.Score || farHotel.DistancePenalty <= nearHotel.DistancePenalty {
+		t.Errorf("expected a hotel far from the places to lower the score, got %+v near and %+v far", nearHotel, farHotel)
+	}
+	if want := nearHotel.AveragePlaceScore - nearHotel.TravelPenalty; nearHotel.Score != want {
+		t.Errorf("breakdown score %v, want %v", nearHotel.Score, want)
+	}
+
+	single := ExplainAnchoredPlanScore(DefaultScorer{}, places[:1], nil, NewAnchors(places[:1], far, nil, POI.TravelModeDriving), 10000)
+	if single.TravelPenalty <= 0 {
+		t.Errorf("expected the travel from the hotel to penalize a single place plan, got %+v", single)
+	}
+}
@@ -11,14 +11,33 @@ type TimeSlot struct {
 	Slot POI.TimeInterval `json:"slot"`
 }
 
+// ToString formats slots in whole hours as "from 10 to 12", the format of the time slots of cached plans saved before
+// slots had minutes, and other slots as "from 12:30 to 13:30"
 func (t *TimeSlot) ToString() string {
-	return fmt.Sprintf("from %d to %d", t.Slot.Start, t.Slot.End)
+	return fmt.Sprintf("from %s to %s", t.Slot.Start.ToString(), t.Slot.End.ToString())
+}
+
+// ParseTimeSlot parses a time slot formatted by ToString
+func ParseTimeSlot(s string) (TimeSlot, error) {
+	var start, end string
+	if _, err := fmt.Sscanf(s, "from %s to %s", &start, &end); err != nil {
+		return TimeSlot{}, fmt.Errorf("invalid time slot %q", s)
+	}
+	startTime, err := POI.ParseClockTime(start)
+	if err != nil {
+		return TimeSlot{}, err
+	}
+	endTime, err := POI.ParseClockTime(end)
+	if err != nil {
+		return TimeSlot{}, err
+	}
+	return TimeSlot{Slot: POI.TimeInterval{Start: startTime, End: endTime}}, nil
 }
 
 type TimeInterval struct {
-	Day       POI.Weekday
-	StartHour uint8
-	EndHour   uint8
+	Day   POI.Weekday
+	Start POI.ClockTime
+	End   POI.ClockTime
 }
 
 type PlacesClusterForTime struct {
@@ -26,15 +45,23 @@ type PlacesClusterForTime struct {
 	Slot   TimeSlot `json:"time slot"`
 }
 
-func (interval *TimeInterval) AddOffsetHours(offsetHour uint8) (intervalOut TimeInterval, valid bool) {
-	//If a stay time after the start time exceeds the end of day, return false
-	if intervalOut.StartHour+offsetHour > interval.EndHour {
+// Duration returns the minutes from the start to the end of the interval
+func (interval *TimeInterval) Duration() uint16 {
+	if interval.End <= interval.Start {
+		return 0
+	}
+	return uint16(interval.End - interval.Start)
+}
+
+// AddOffsetMinutes returns the interval starting offset minutes later, it is not valid if it would start after its end
+func (interval *TimeInterval) AddOffsetMinutes(offset uint16) (intervalOut TimeInterval, valid bool) {
+	if uint32(interval.Start)+uint32(offset) > uint32(interval.End) {
 		valid = false
 		return
 	}
 	intervalOut.Day = interval.Day
-	intervalOut.StartHour = interval.StartHour + offsetHour
-	intervalOut.EndHour = interval.EndHour
+	intervalOut.Start = interval.Start + POI.ClockTime(offset)
+	intervalOut.End = interval.End
 	valid = true
 	return
 }
@@ -0,0 +1,122 @@
+package matching
+
+import (
+	"math"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/utils"
+)
+
+// MinSlotStayRatio is the minimum share of a time slot spent at its place, the rest of the slot can be used for travel
+const MinSlotStayRatio = 0.5
+
+// TravelLegs estimates the travel between each pair of consecutive places
+func TravelLegs(places []Place, mode POI.TravelMode) []POI.TravelLeg {
+	if len(places) < 2 {
+		return nil
+	}
+	legs := make([]POI.TravelLeg, len(places)-1)
+	for i := range legs {
+		legs[i] = POI.EstimateTravelLeg(places[i].Location(), places[i+1].Location(), mode)
+	}
+	return legs
+}
+
+// FitsTimeSlots checks whether the travel legs fit between the time slots of a plan.
+// It schedules every visit as early as possible, staying MinSlotStayRatio of each slot before leaving for the next place,
+// and fails if the traveller arrives too late to stay that long in a later slot.
+func FitsTimeSlots(legs []POI.TravelLeg, slots []TimeSlot) bool {
+	if len(slots) != len(legs)+1 {
+		return true
+	}
+
+	var clock float64
+	for idx, slot := range slots {
+		slotStart, slotEnd := float64(slot.Slot.Start), float64(slot.Slot.End)
+		arrival := math.Max(clock, slotStart)
+		departure := arrival + math.Ceil((slotEnd-slotStart)*MinSlotStayRatio)
+		if departure > slotEnd {
+			return false
+		}
+		if idx < len(legs) {
+			clock = departure + float64(legs[idx].Minutes)
+		}
+	}
+	return true
+}
+
+// Anchors are the locations a plan starts from and ends at, e.g. the hotel of the traveler, together with the travel
+// from the start to the first place and from the last place to the end. Unset anchors and their legs are nil.
+type Anchors struct {
+	Start    *POI.Location
+	End      *POI.Location
+	StartLeg *POI.TravelLeg
+	EndLeg   *POI.TravelLeg
+}
+
+// NewAnchors estimates the travel between the anchors and the places visited in order
+func NewAnchors(places []Place, start, end *POI.Location, mode POI.TravelMode) Anchors {
+	anchors := Anchors{Start: start, End: end}
+	if len(places) == 0 {
+		return anchors
+	}
+	if start != nil {
+		leg := POI.EstimateTravelLeg(*start, places[0].Location(), mode)
+		anchors.StartLeg = &leg
+	}
+	if end != nil {
+		leg := POI.EstimateTravelLeg(places[len(places)-1].Location(), *end, mode)
+		anchors.EndLeg = &leg
+	}
+	return anchors
+}
+
+// Count is the number of anchors set
+func (a Anchors) Count() int {
+	var count int
+	if a.Start != nil {
+		count++
+	}
+	if a.End != nil {
+		count++
+	}
+	return count
+}
+
+// legs surrounds the legs between places with the legs from the start anchor and to the end anchor
+func (a Anchors) legs(legs []POI.TravelLeg) []POI.TravelLeg {
+	if a.StartLeg == nil && a.EndLeg == nil {
+		return legs
+	}
+	res := make([]POI.TravelLeg, 0, len(legs)+2)
+	if a.StartLeg != nil {
+		res = append(res, *a.StartLeg)
+	}
+	res = append(res, legs...)
+	if a.EndLeg != nil {
+		res = append(res, *a.EndLeg)
+	}
+	return res
+}
+
+// distances are the straight-line distances between consecutive stops of the plan, including the anchors
+func (a Anchors) distances(places []Place) []float64 {
+	locations := make([][]float64, 0, len(places)+2)
+	if a.Start != nil {
+		locations = append(locations, []float64{a.Start.Latitude, a.Start.Longitude})
+	}
+	for _, place := range places {
+		locations = append(locations, []float64{place.Location().Latitude, place.Location().Longitude})
+	}
+	if a.End != nil {
+		locations = append(locations, []float64{a.End.Latitude, a.End.Longitude})
+	}
+	if len(locations) < 2 {
+		return nil
+	}
+	distances := make([]float64, len(locations)-1)
+	for i := range distances {
+		distances[i] = utils.HaversineDist(locations[i], locations[i+1])
+	}
+	return distances
+}
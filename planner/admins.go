@@ -2,12 +2,14 @@ package planner
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-contrib/requestid"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/weihesdlegend/Vacation-planner/POI"
 	"github.com/weihesdlegend/Vacation-planner/iowrappers"
 	"github.com/weihesdlegend/Vacation-planner/user"
 )
@@ -57,3 +59,164 @@ func (p *MyPlanner) announce(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, announcement)
 }
+
+// closuresGetHandler lists the closures of places and the holidays on a date
+func (p *MyPlanner) closuresGetHandler(ctx *gin.Context) {
+	if _, authErr := p.UserAuthentication(ctx, user.LevelAdmin); authErr != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": authErr.GetErrorMessage()})
+		return
+	}
+
+	date := ctx.Query("date")
+	if err := validateDate(date); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	closures, err := p.RedisClient.Closures(ctx, date)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"closures": closures})
+}
+
+// closuresPostHandler adds a closure to the calendar or replaces the closure of the same place or country on its date
+func (p *MyPlanner) closuresPostHandler(ctx *gin.Context) {
+	if _, authErr := p.UserAuthentication(ctx, user.LevelAdmin); authErr != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": authErr.GetErrorMessage()})
+		return
+	}
+
+	var closure POI.Closure
+	if err := ctx.ShouldBindJSON(&closure); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := closure.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := p.RedisClient.SaveClosure(ctx, closure); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, closure)
+}
+
+// closureDeleteHandler removes the closure of a place or the holiday of a country on a date
+func (p *MyPlanner) closureDeleteHandler(ctx *gin.Context) {
+	if _, authErr := p.UserAuthentication(ctx, user.LevelAdmin); authErr != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": authErr.GetErrorMessage()})
+		return
+	}
+
+	closure := POI.Closure{Date: ctx.Query("date"), PlaceID: ctx.Query("place_id"), Country: ctx.Query("country")}
+	if err := validateDate(closure.Date); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (closure.PlaceID == "") == (closure.Country == "") {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "either place_id or country is required"})
+		return
+	}
+
+	removed, err := p.RedisClient.RemoveClosure(ctx, closure.Date, closure.ID())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !removed {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "closure not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "closure is removed"})
+}
+
+// deadLettersGetHandler lists the jobs that failed every attempt of their retry policy, the latest first
+func (p *MyPlanner) deadLettersGetHandler(ctx *gin.Context) {
+	if _, authErr := p.UserAuthentication(ctx, user.LevelAdmin); authErr != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": authErr.GetErrorMessage()})
+		return
+	}
+
+	jobs, err := p.RedisClient.DeadLetterJobs(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// deadLetterGetHandler returns a dead-lettered job with its parameters and last error
+func (p *MyPlanner) deadLetterGetHandler(ctx *gin.Context) {
+	if _, authErr := p.UserAuthentication(ctx, user.LevelAdmin); authErr != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": authErr.GetErrorMessage()})
+		return
+	}
+
+	job, err := p.RedisClient.GetDeadLetterJob(ctx, ctx.Param("id"))
+	if errors.Is(err, iowrappers.ErrJobNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
+
+// deadLetterRequeueHandler queues a dead-lettered job again with a fresh retry budget
+func (p *MyPlanner) deadLetterRequeueHandler(ctx *gin.Context) {
+	if _, authErr := p.UserAuthentication(ctx, user.LevelAdmin); authErr != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": authErr.GetErrorMessage()})
+		return
+	}
+
+	deadLetter, err := p.RedisClient.GetDeadLetterJob(ctx, ctx.Param("id"))
+	if errors.Is(err, iowrappers.ErrJobNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the parameters of the stored job are decoded with the type of the job, as workers expect
+	data, err := json.Marshal(deadLetter.Job)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	job, err := decodeJob(string(data))
+	if err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	job.Attempts = 0
+	job.Status = iowrappers.JobStatusNew
+	job.Error = ""
+	createJobRecord(ctx, job, p.RedisClient)
+
+	if !p.Dispatcher.JobQueue.Enqueue(job) {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "job queue is closed"})
+		return
+	}
+	if _, err = p.RedisClient.RemoveDeadLetterJob(ctx, job.ID); err != nil {
+		iowrappers.Logger.Error(err)
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{"job_id": job.ID, "status": job.Status})
+}
+
+// jobQueueStatsHandler returns the depth and the wait times of the queued jobs of each priority
+func (p *MyPlanner) jobQueueStatsHandler(ctx *gin.Context) {
+	if _, authErr := p.UserAuthentication(ctx, user.LevelAdmin); authErr != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": authErr.GetErrorMessage()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, p.Dispatcher.JobQueue.WaitStats())
+}
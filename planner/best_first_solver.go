@@ -0,0 +1,182 @@
+package planner
+
+import (
+	"cmp"
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+type SearchStrategy string
+
+const (
+	SearchStrategyExhaustive SearchStrategy = "exhaustive"
+	SearchStrategyBestFirst  SearchStrategy = "best_first"
+)
+
+// MaxBestFirstFrontierSize bounds the memory of the best-first search.
+// When the frontier grows beyond it, only the most promising half of the partial plans is kept.
+const MaxBestFirstFrontierSize = 200000
+
+func ParseSearchStrategy(strategy string) (SearchStrategy, error) {
+	switch s := SearchStrategy(strategy); s {
+	case SearchStrategyExhaustive, SearchStrategyBestFirst:
+		return s, nil
+	case "":
+		return SearchStrategyExhaustive, nil
+	}
+	return SearchStrategyExhaustive, fmt.Errorf("unknown solver search strategy %s", strategy)
+}
+
+// searchNode is a partial plan with places chosen for the first len(placeIndexes) slots
+type searchNode struct {
+	placeIndexes []int
+	legs         []POI.TravelLeg
+	startLeg     *POI.TravelLeg // travel from the start location to the first place, nil without a start location
+	placeScores  float64        // sum of the place scores of the chosen places
+	bound        float64        // upper bound of the score of any complete plan extending this node
+	solution     *PlanningSolution
+}
+
+// Key makes MinPriorityQueue pop the node with the highest bound first
+func (n searchNode) Key() float64 {
+	return -n.bound
+}
+
+// FindBestPlanningSolutionsBestFirst returns the same top plans as FindBestPlanningSolutions without enumerating
+// every combination of places. Partial plans are expanded in the order of their score upper bounds, which use the
+// best place score of each remaining slot and assume no further travel, so complete plans come out in descending
+// score order and the search stops as soon as enough plans pass the place deduplication check.
+// A complete plan is pushed back with its exact score once evaluated, so score terms not covered by the bound,
+// such as the distance to pinned places, cannot change the order.
+func (s *Solver) FindBestPlanningSolutionsBestFirst(ctx context.Context, placeClusters [][]matching.Place, maxSolutionsToSaveCount int, req *PlanningRequest) *PlanningResp {
+	if maxSolutionsToSaveCount <= 0 {
+		maxSolutionsToSaveCount = TopSolutionsCountDefault
+	}
+	numSlots := len(placeClusters)
+	if numSlots == 0 || slices.ContainsFunc(placeClusters, func(places []matching.Place) bool { return len(places) == 0 }) {
+		return &PlanningResp{ErrorCode: NoValidSolution, Err: errors.New(CategorizedPlaceIterInitFailureErrMsg)}
+	}
+
+	placeScores := make([][]float64, numSlots)
+	// remainingBest[i] is the sum of the best place scores of slots i to the last slot
+	remainingBest := make([]float64, numSlots+1)
+	for i := numSlots - 1; i >= 0; i-- {
+		placeScores[i] = make([]float64, len(placeClusters[i]))
+		best := s.Scorer().PlaceScore(placeClusters[i][0])
+		for j, place := range placeClusters[i] {
+			placeScores[i][j] = s.Scorer().PlaceScore(place)
+			best = max(best, placeScores[i][j])
+		}
+		remainingBest[i] = remainingBest[i+1] + best
+	}
+
+	// legs not chosen yet count as zero-minute legs, which cannot raise the travel penalty of the scorer;
+	// the legs from the start location and to the end location are in the same positions as in complete plans
+	numLegs := numSlots - 1 + matching.Anchors{Start: req.StartLocation, End: req.EndLocation}.Count()
+	zeroLegs := make([]POI.TravelLeg, numLegs)
+	upperBound := func(depth int, node searchNode) float64 {
+		bound := (node.placeScores + remainingBest[depth]) / float64(numSlots)
+		if numLegs > 0 {
+			legs := slices.Clip(node.legs)
+			if node.startLeg != nil {
+				legs = append([]POI.TravelLeg{*node.startLeg}, legs...)
+			}
+			bound -= s.Scorer().TravelPenalty(append(legs, zeroLegs[len(legs):]...), int(req.SearchRadius))
+		}
+		return bound
+	}
+
+	var timeSlots []matching.TimeSlot
+	if len(req.Slots) == numSlots {
+		timeSlots = toTimeSlots(req.Slots)
+	}
+
+	frontier := &MinPriorityQueue[searchNode]{}
+	heap.Push(frontier, searchNode{bound: upperBound(0, searchNode{})})
+	includedPlaces := make(map[string]int8)
+	res := make([]PlanningSolution, 0, maxSolutionsToSaveCount)
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, SolverTimeout)
+	defer cancel()
+	for frontier.Len() > 0 && len(res) < maxSolutionsToSaveCount {
+		select {
+		case <-ctxWithTimeout.Done():
+			iowrappers.Logger.Errorf("(Solver)FindBestPlanningSolutionsBestFirst -> computation timeout with frontier size %d", frontier.Len())
+			return &PlanningResp{Solutions: res}
+		default:
+		}
+
+		node := heap.Pop(frontier).(searchNode)
+		depth := len(node.placeIndexes)
+		if node.solution != nil {
+			if !s.isPlanDuplicate(includedPlaces, *node.solution) {
+				res = append(res, *node.solution)
+			}
+			continue
+		}
+		if depth == numSlots {
+			candidate, err := createPlanningSolutionCandidate(node.placeIndexes, placeClusters, req, s.Scorer())
+			if err != nil {
+				continue
+			}
+			node.solution, node.bound = &candidate, candidate.Score
+			heap.Push(frontier, node)
+			continue
+		}
+
+		for idx, place := range placeClusters[depth] {
+			if isPlaceChosen(node, placeClusters, place.Id()) {
+				continue
+			}
+			child := searchNode{
+				placeIndexes: append(slices.Clip(node.placeIndexes), idx),
+				legs:         node.legs,
+				startLeg:     node.startLeg,
+				placeScores:  node.placeScores + placeScores[depth][idx],
+			}
+			if depth == 0 && req.StartLocation != nil {
+				leg := POI.EstimateTravelLeg(*req.StartLocation, place.Location(), req.TravelMode)
+				child.startLeg = &leg
+			}
+			if depth > 0 {
+				prev := placeClusters[depth-1][node.placeIndexes[depth-1]]
+				leg := POI.EstimateTravelLeg(prev.Location(), place.Location(), req.TravelMode)
+				child.legs = append(slices.Clip(node.legs), leg)
+				// a partial plan that cannot be scheduled cannot be completed either
+				if timeSlots != nil && !matching.FitsTimeSlots(child.legs, timeSlots[:depth+1]) {
+					continue
+				}
+			}
+			child.bound = upperBound(depth+1, child)
+			heap.Push(frontier, child)
+		}
+
+		if frontier.Len() > MaxBestFirstFrontierSize {
+			trimFrontier(frontier)
+		}
+	}
+	return &PlanningResp{Solutions: res}
+}
+
+func isPlaceChosen(node searchNode, placeClusters [][]matching.Place, placeID string) bool {
+	for slot, placeIdx := range node.placeIndexes {
+		if placeClusters[slot][placeIdx].Id() == placeID {
+			return true
+		}
+	}
+	return false
+}
+
+// trimFrontier keeps the half of the partial plans with the highest bounds
+func trimFrontier(frontier *MinPriorityQueue[searchNode]) {
+	slices.SortFunc(frontier.items, func(a, b searchNode) int { return cmp.Compare(a.Key(), b.Key()) })
+	frontier.items = frontier.items[:len(frontier.items)/2]
+	heap.Init(frontier)
+}
@@ -0,0 +1,132 @@
+package planner
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+func randomPlaceClusters(r *rand.Rand, numSlots, placesPerSlot int) ([][]matching.Place, *PlanningRequest) {
+	req := &PlanningRequest{SearchRadius: DefaultPlaceSearchRadius}
+	clusters := make([][]matching.Place, numSlots)
+	for slot := range clusters {
+		for i := 0; i < placesPerSlot; i++ {
+			id := strconv.Itoa(slot) + "-" + strconv.Itoa(i)
+			clusters[slot] = append(clusters[slot], matching.Place{
+				Place: &POI.Place{
+					ID:               id,
+					Rating:           float32(3 + 2*r.Float64()),
+					UserRatingsTotal: r.Intn(5000),
+					Location:         POI.Location{Latitude: 37.7 + 0.1*r.Float64(), Longitude: -122.5 + 0.1*r.Float64()},
+				},
+				Price: 1,
+			})
+		}
+		start := POI.NewClockTime(9+2*slot, 0)
+		req.Slots = append(req.Slots, SlotRequest{
+			TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: start, End: start + 120}},
+			Category: POI.PlaceCategoryVisit,
+		})
+	}
+	return clusters, req
+}
+
+func TestFindBestPlanningSolutionsBestFirst_shouldMatchExhaustiveSearch(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	for _, numSlots := range []int{1, 2, 3, 4} {
+		for _, mode := range []POI.TravelMode{POI.TravelModeAuto, POI.TravelModeWalking} {
+			clusters, req := randomPlaceClusters(r, numSlots, 6)
+			req.TravelMode = mode
+			// a dedupe limit above the number of plans keeps the top plans independent of the search order
+			s := &Solver{placeDedupeCountLimit: 1000}
+
+			iterator := &MultiDimIterator{}
+			if err := iterator.Init(toPlaceCategories(req.Slots), clusters); err != nil {
+				t.Fatal(err)
+			}
+			exhaustive := s.FindBestPlanningSolutions(context.Background(), clusters, 10, iterator, req)
+			bestFirst := s.FindBestPlanningSolutionsBestFirst(context.Background(), clusters, 10, req)
+
+			if len(exhaustive.Solutions) != len(bestFirst.Solutions) {
+				t.Fatalf("%d slots, mode %q: expected %d plans, got %d", numSlots, mode, len(exhaustive.Solutions), len(bestFirst.Solutions))
+			}
+			for idx := range exhaustive.Solutions {
+				expected, actual := exhaustive.Solutions[idx], bestFirst.Solutions[idx]
+				if math.Abs(expected.Score-actual.Score) > 1e-9 {
+					t.Errorf("%d slots, mode %q, plan %d: expected score %f, got %f", numSlots, mode, idx, expected.Score, actual.Score)
+				}
+				if !slices.Equal(expected.PlaceIDS, actual.PlaceIDS) {
+					t.Errorf("%d slots, mode %q, plan %d: expected places %v, got %v", numSlots, mode, idx, expected.PlaceIDS, actual.PlaceIDS)
+				}
+			}
+		}
+	}
+}
+
+func TestFindBestPlanningSolutionsBestFirst_shouldLimitRepeatedPlaces(t *testing.T) {
+	clusters, req := randomPlaceClusters(rand.New(rand.NewSource(11)), 3, 5)
+	s := &Solver{placeDedupeCountLimit: 2}
+
+	resp := s.FindBestPlanningSolutionsBestFirst(context.Background(), clusters, 5, req)
+	if len(resp.Solutions) == 0 {
+		t.Fatal("expected plans")
+	}
+	counts := make(map[string]int)
+	for idx, solution := range resp.Solutions {
+		if idx > 0 && solution.Score > resp.Solutions[idx-1].Score {
+			t.Errorf("plans should be sorted by score descending")
+		}
+		for _, id := range solution.PlaceIDS {
+			counts[id]++
+			if counts[id] > s.placeDedupeCountLimit {
+				t.Errorf("place %s appears in more than %d plans", id, s.placeDedupeCountLimit)
+			}
+		}
+	}
+}
+
+func TestFindBestPlanningSolutionsBestFirst_shouldRejectEmptySlot(t *testing.T) {
+	clusters, req := randomPlaceClusters(rand.New(rand.NewSource(3)), 2, 3)
+	clusters[1] = nil
+	s := &Solver{placeDedupeCountLimit: 2}
+	if resp := s.FindBestPlanningSolutionsBestFirst(context.Background(), clusters, 5, req); resp.Err == nil {
+		t.Error("expected an error for a slot without candidate places")
+	}
+}
+
+func TestFindBestPlanningSolutionsBestFirst_shouldMatchExhaustiveSearchWithAnchors(t *testing.T) {
+	r := rand.New(rand.NewSource(13))
+	hotel := &POI.Location{Latitude: 37.71, Longitude: -122.49}
+	for _, numSlots := range []int{1, 2, 3} {
+		clusters, req := randomPlaceClusters(r, numSlots, 6)
+		req.StartLocation, req.EndLocation = hotel, hotel
+		s := &Solver{placeDedupeCountLimit: 1000}
+
+		iterator := &MultiDimIterator{}
+		if err := iterator.Init(toPlaceCategories(req.Slots), clusters); err != nil {
+			t.Fatal(err)
+		}
+		exhaustive := s.FindBestPlanningSolutions(context.Background(), clusters, 10, iterator, req)
+		bestFirst := s.FindBestPlanningSolutionsBestFirst(context.Background(), clusters, 10, req)
+
+		if len(exhaustive.Solutions) != len(bestFirst.Solutions) {
+			t.Fatalf("%d slots: expected %d plans, got %d", numSlots, len(exhaustive.Solutions), len(bestFirst.Solutions))
+		}
+		for idx := range exhaustive.Solutions {
+			expected, actual := exhaustive.Solutions[idx], bestFirst.Solutions[idx]
+			if math.Abs(expected.Score-actual.Score) > 1e-9 || !slices.Equal(expected.PlaceIDS, actual.PlaceIDS) {
+				t.Errorf("%d slots, plan %d: expected %v with score %f, got %v with score %f", numSlots, idx,
+					expected.PlaceIDS, expected.Score, actual.PlaceIDS, actual.Score)
+			}
+			if actual.StartLeg == nil || actual.EndLeg == nil {
+				t.Errorf("%d slots, plan %d: expected the legs from and to the hotel", numSlots, idx)
+			}
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+const (
+	// cacheWarmingLeaseKey is the lease of a cache warming round, so that the servers warm the cache once per interval
+	cacheWarmingLeaseKey = "cache_warming"
+	// cacheWarmingPriceLevel is the default price level of the planning API
+	cacheWarmingPriceLevel = POI.PriceLevelTwo
+)
+
+// CacheWarmingConfig sets which cached plans are computed again before they expire
+type CacheWarmingConfig struct {
+	// Interval is how often the cities are ranked and their warming jobs queued
+	Interval time.Duration
+	// DemandWindow is the period of the planning API calls ranking the cities
+	DemandWindow time.Duration
+	// MaxCities is the number of the most visited cities whose plans are warmed
+	MaxCities int
+	// DaysAhead is the number of upcoming days from today whose plans are warmed, 7 covers every weekday
+	DaysAhead int
+	// RefreshBefore is how long before they expire the cached plans are computed again, it is kept longer than
+	// Interval so that the plans do not expire between two rounds
+	RefreshBefore time.Duration
+}
+
+// DefaultCacheWarmingConfig applies to the settings missing from the cache_warming config section
+var DefaultCacheWarmingConfig = CacheWarmingConfig{
+	Interval:      time.Hour,
+	DemandWindow:  24 * time.Hour,
+	MaxCities:     10,
+	DaysAhead:     7,
+	RefreshBefore: 2 * time.Hour,
+}
+
+// withDefaults returns the config with the defaults of the missing settings
+func (c CacheWarmingConfig) withDefaults() CacheWarmingConfig {
+	if c.Interval <= 0 {
+		c.Interval = DefaultCacheWarmingConfig.Interval
+	}
+	if c.DemandWindow <= 0 {
+		c.DemandWindow = DefaultCacheWarmingConfig.DemandWindow
+	}
+	if c.MaxCities <= 0 {
+		c.MaxCities = DefaultCacheWarmingConfig.MaxCities
+	}
+	if c.DaysAhead <= 0 {
+		c.DaysAhead = DefaultCacheWarmingConfig.DaysAhead
+	}
+	if c.RefreshBefore <= c.Interval {
+		c.RefreshBefore = 2 * c.Interval
+	}
+	return c
+}
+
+// CacheWarmer keeps the cached plans of the most visited cities warm. Every interval it ranks the cities by the planning
+// API calls counted by CollectPlanningAPIStats, and queues low priority jobs computing the plans of the standard
+// template on the upcoming days whose cached plans are missing or about to expire.
+type CacheWarmer struct {
+	redis    *iowrappers.RedisClient
+	jobQueue JobQueue
+	config   CacheWarmingConfig
+}
+
+// NewCacheWarmer creates a cache warmer queueing jobs to jobQueue, e.g. the job queue of the Dispatcher
+func NewCacheWarmer(redis *iowrappers.RedisClient, jobQueue JobQueue, config CacheWarmingConfig) *CacheWarmer {
+	return &CacheWarmer{
+		redis:    redis,
+		jobQueue: jobQueue,
+		config:   config.withDefaults(),
+	}
+}
+
+// Run warms the cache every interval until ctx is done
+func (w *CacheWarmer) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+	for {
+		if queued, err := w.warm(ctx, time.Now()); err != nil {
+			iowrappers.Logger.Errorf("failed to warm the travel plans cache: %v", err)
+		} else if queued > 0 {
+			iowrappers.Logger.Infof("queued %d cache warming jobs", queued)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// warm queues the cache warming jobs of a round and returns the number of queued jobs. A round runs on one server per
+// interval, the other servers skip it.
+func (w *CacheWarmer) warm(ctx context.Context, now time.Time) (int, error) {
+	// the lease expires before the next round of the server taking it
+	_, acquired, err := w.redis.AcquireJobLease(ctx, cacheWarmingLeaseKey, w.config.Interval-w.config.Interval/10)
+	if err != nil {
+		return 0, err
+	}
+	if !acquired {
+		return 0, nil
+	}
+
+	cities, err := w.redis.PopularCities(ctx, now.Add(-w.config.DemandWindow), w.config.MaxCities)
+	if err != nil {
+		return 0, err
+	}
+
+	var queued int
+	for _, city := range cities {
+		location := POI.Location{City: city.City, AdminAreaLevelOne: city.AdminAreaLevelOne, Country: city.Country}
+		for day := range w.config.DaysAhead {
+			date := now.AddDate(0, 0, day).Format("2006-01-02")
+			req := standardRequest(date, toWeekday(date), NumPlansDefault, cacheWarmingPriceLevel)
+			req.Location = location
+			req.SearchRadius = DefaultPlaceSearchRadius
+
+			// the handler checks the cached plans again, e.g. with the closures on the date
+			ttl, ttlErr := w.redis.TravelPlansTTL(ctx, toSolutionsSaveRequest(&req, nil))
+			if ttlErr != nil {
+				iowrappers.Logger.Error(ttlErr)
+				continue
+			}
+			if ttl > w.config.RefreshBefore {
+				continue
+			}
+
+			job := &iowrappers.Job{
+				ID:          uuid.NewString(),
+				Name:        CacheWarmingJobType,
+				Description: "Warm Cached Planning Solutions",
+				Parameters:  &req,
+				Status:      iowrappers.JobStatusNew,
+				Priority:    iowrappers.JobPriorityLow,
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			}
+			if !w.jobQueue.Enqueue(job) {
+				return queued, fmt.Errorf("failed to enqueue job %s: queue is closed", job.ID)
+			}
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+// CacheWarmingJobHandler implements JobHandler for cache warming jobs. Unlike a planning job, a cache warming job
+// computes the plans again when the cached plans expire within refreshWithin.
+type CacheWarmingJobHandler struct {
+	solver        *Solver
+	redis         *iowrappers.RedisClient
+	refreshWithin time.Duration
+}
+
+// NewCacheWarmingJobHandler creates a new cache warming job handler
+func NewCacheWarmingJobHandler(solver *Solver, redis *iowrappers.RedisClient, refreshWithin time.Duration) *CacheWarmingJobHandler {
+	return &CacheWarmingJobHandler{
+		solver:        solver,
+		redis:         redis,
+		refreshWithin: refreshWithin,
+	}
+}
+
+// Execute computes the plans of a cache warming job unless they are cached and fresh. Jobs warming the same plans run
+// one at a time across the servers, and the jobs after the first one find the plans fresh. As for a planning job, a
+// failed job is recorded by the worker.
+func (h *CacheWarmingJobHandler) Execute(ctx context.Context, job *iowrappers.Job) error {
+	req, ok := job.Parameters.(*PlanningRequest)
+	if !ok {
+		return nonRetryableJobError{fmt.Errorf("invalid job parameters type for cache warming job")}
+	}
+
+	jobKey, err := toSolutionKey(req)
+	if err != nil {
+		return err
+	}
+
+	lease, acquired, err := h.redis.AcquireJobLease(ctx, CacheWarmingJobType+":"+jobKey, JobLeaseTTL)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		job.Status = iowrappers.JobStatusDuplicated
+		return nil
+	}
+
+	job.Status = iowrappers.JobStatusRunning
+	ctx, cancel := context.WithCancel(ctx)
+	stopRenewal := keepJobLease(ctx, h.redis, lease, cancel)
+	req.refreshWithin = h.refreshWithin
+	resp := h.solver.Solve(ctx, req)
+	stopRenewal()
+	cancel()
+
+	// the lease is not kept after the job, the next warming of the plans is due before they expire
+	releaseCtx := context.WithoutCancel(ctx)
+	if err = h.redis.ReleaseJobLease(releaseCtx, lease, false); err != nil {
+		iowrappers.Logger.Errorf("failed to release the lease of job %s: %v", job.ID, err)
+	}
+	if resp.Err != nil {
+		return planningJobError(resp.Err, resp.ErrorCode)
+	}
+	job.Status = iowrappers.JobStatusCompleted
+	job.Error = ""
+	createJobRecord(releaseCtx, job, h.redis)
+	return nil
+}
+
+// JobType returns the job type identifier
+func (h *CacheWarmingJobHandler) JobType() string {
+	return CacheWarmingJobType
+}
@@ -0,0 +1,77 @@
+package planner
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/test/redis_client_mocks"
+)
+
+func TestCacheWarmer_shouldQueueJobsOfPopularCitiesWithoutFreshPlans(t *testing.T) {
+	ctx := context.Background()
+	redisClient := redis_client_mocks.RedisClient
+	for _, key := range redis_client_mocks.RedisMockSvr.Keys() {
+		if strings.HasPrefix(key, iowrappers.NumVisitorsPlanningAPI) || strings.HasPrefix(key, iowrappers.TravelPlansRedisCacheKeyPrefix) {
+			redis_client_mocks.RedisMockSvr.Del(key)
+		}
+	}
+	redis_client_mocks.RedisMockSvr.Del(iowrappers.JobLeaseRedisKeyPrefix + cacheWarmingLeaseKey)
+
+	now := time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)
+	for _, city := range []struct {
+		name, country string
+		visits        int
+	}{{"Rome", "Italy", 3}, {"Madrid", "Spain", 1}} {
+		for range city.visits {
+			redisClient.CollectPlanningAPIStats(ctx, iowrappers.PlanningEvent{City: city.name, Country: city.country, Timestamp: now.Format(time.RFC3339)}, 0)
+		}
+	}
+
+	// the plans of Rome on Sunday 2026-10-18 are fresh
+	fresh := standardRequest("2026-10-18", POI.DateSunday, NumPlansDefault, cacheWarmingPriceLevel)
+	fresh.Location = POI.Location{City: "rome", Country: "italy"}
+	freshKey, err := toSolutionKey(&fresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = redis_client_mocks.RedisMockSvr.ZAdd(freshKey, 1, "travel_plan:fresh"); err != nil {
+		t.Fatal(err)
+	}
+	redis_client_mocks.RedisMockSvr.SetTTL(freshKey, iowrappers.PlanningSolutionsExpirationTime)
+
+	queue := NewPriorityJobQueue(10)
+	defer queue.Close()
+	warmer := NewCacheWarmer(redisClient, queue, CacheWarmingConfig{Interval: time.Hour, MaxCities: 1, DaysAhead: 2})
+	queued, err := warmer.warm(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued != 1 || queue.Len() != 1 {
+		t.Fatalf("expected a job for the plans of Rome on Monday, got %d", queue.Len())
+	}
+	job := queue.Dequeue()
+	req := job.Parameters.(*PlanningRequest)
+	if job.Name != CacheWarmingJobType || job.Priority != iowrappers.JobPriorityLow || req.Location.City != "rome" ||
+		req.TravelDate != "2026-10-19" || req.Slots[0].Weekday != POI.DateMonday {
+		t.Errorf("expected a low priority job warming the plans of Rome on Monday, got %+v with %+v", job, req)
+	}
+
+	// another server warming the cache in the same interval skips the round
+	if queued, err = warmer.warm(ctx, now); err != nil || queued != 0 {
+		t.Errorf("expected the round to be skipped, got %d jobs (%v)", queued, err)
+	}
+}
+
+func TestCacheWarmingConfig_shouldRefreshPlansBeforeTheNextRound(t *testing.T) {
+	config := CacheWarmingConfig{Interval: 3 * time.Hour, RefreshBefore: time.Hour}.withDefaults()
+	if config.RefreshBefore <= config.Interval {
+		t.Errorf("expected plans to be refreshed earlier than the interval, got %s", config.RefreshBefore)
+	}
+	if config.MaxCities != DefaultCacheWarmingConfig.MaxCities || config.DaysAhead != DefaultCacheWarmingConfig.DaysAhead {
+		t.Errorf("expected the default city and day limits, got %+v", config)
+	}
+}
@@ -9,18 +9,13 @@ import (
 
 const NumWorkers = 10
 
-// JobStore tracks and deduplicates concurrent job executions
-type JobStore struct {
-	mu    *sync.RWMutex
-	execs map[string]*iowrappers.JobExecution
-}
-
 type Dispatcher struct {
-	JobQueue *PriorityJobQueue
+	JobQueue JobQueue
 	workers  []*GenericWorker
 	solver   *Solver
 	c        *iowrappers.RedisClient
 	wg       *sync.WaitGroup
+	handlers []JobHandler // handlers of the job types other than planning
 }
 
 func NewDispatcher(s *Solver, c *iowrappers.RedisClient) *Dispatcher {
@@ -33,22 +28,25 @@ func NewDispatcher(s *Solver, c *iowrappers.RedisClient) *Dispatcher {
 	}
 }
 
-func (d *Dispatcher) Run(ctx context.Context) {
-	store := &JobStore{
-		mu:    &sync.RWMutex{},
-		execs: make(map[string]*iowrappers.JobExecution),
-	}
+// RegisterHandler adds the handler of a job type to the workers started by Run
+func (d *Dispatcher) RegisterHandler(handler JobHandler) {
+	d.handlers = append(d.handlers, handler)
+}
 
+func (d *Dispatcher) Run(ctx context.Context) {
 	// Create job handlers
-	planningHandler := NewPlanningJobHandler(d.solver, store, d.c)
+	planningHandler := NewPlanningJobHandler(d.solver, d.c)
 
 	ctx = context.WithValue(ctx, iowrappers.ContextRequestUserId, "worker")
 	d.wg.Add(NumWorkers)
 	for i := range NumWorkers {
-		worker := NewGenericWorker(i, d.JobQueue, d.wg)
+		worker := NewGenericWorker(i, d.JobQueue, d.c, d.wg)
 
 		// Register job handlers
 		worker.RegisterHandler(planningHandler)
+		for _, handler := range d.handlers {
+			worker.RegisterHandler(handler)
+		}
 
 		d.workers = append(d.workers, worker)
 		worker.Run(ctx)
@@ -0,0 +1,151 @@
+package planner
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/weihesdlegend/Vacation-planner/utils"
+)
+
+const (
+	// DiversityLambdaDisabled ranks plans by score only
+	DiversityLambdaDisabled = 1.0
+	// DiversityCandidatePoolFactor is the number of candidate plans per returned plan that the diversity selection
+	// chooses from
+	DiversityCandidatePoolFactor = 5
+)
+
+// ParseDiversityLambda validates the trade-off between score and diversity, where 1 ranks plans by score only and
+// smaller values favor plans unlike the ones already selected. Zero is taken as unset and disables the selection.
+func ParseDiversityLambda(lambda float64) (float64, error) {
+	if lambda == 0 {
+		return DiversityLambdaDisabled, nil
+	}
+	if lambda < 0 || lambda > 1 {
+		return DiversityLambdaDisabled, fmt.Errorf("diversity lambda must be between 0 and 1, got %v", lambda)
+	}
+	return lambda, nil
+}
+
+// DiversityLambda returns the trade-off between score and diversity, a solver that is not initialized ranks by score
+func (s *Solver) DiversityLambda() float64 {
+	if s.diversityLambda == 0 {
+		return DiversityLambdaDisabled
+	}
+	return s.diversityLambda
+}
+
+// diversityPoolSize is the number of top plans the diversity selection needs to pick numPlans plans
+func (s *Solver) diversityPoolSize(numPlans int) int {
+	if s.DiversityLambda() >= DiversityLambdaDisabled {
+		return numPlans
+	}
+	return min(numPlans*DiversityCandidatePoolFactor, MaxSolutionsToSaveCount)
+}
+
+// selectDiverseSolutions picks numPlans plans with maximal marginal relevance: each pick maximizes
+// lambda * normalized score - (1 - lambda) * highest similarity to the plans already picked.
+// With lambda of 1 this returns the plans with the highest scores in descending order.
+func (s *Solver) selectDiverseSolutions(solutions []PlanningSolution, numPlans int, searchRadius uint) []PlanningSolution {
+	numPlans = min(numPlans, len(solutions))
+	if numPlans <= 0 {
+		return nil
+	}
+	lambda := s.DiversityLambda()
+	if lambda >= DiversityLambdaDisabled {
+		results := slices.Clone(solutions)
+		slices.SortStableFunc(results, func(a, b PlanningSolution) int { return cmp.Compare(b.Score, a.Score) })
+		return results[:numPlans]
+	}
+
+	lowest, highest := solutions[0].Score, solutions[0].Score
+	for _, solution := range solutions {
+		lowest, highest = min(lowest, solution.Score), max(highest, solution.Score)
+	}
+	normalizedScore := func(score float64) float64 {
+		if highest == lowest {
+			return 1
+		}
+		return (score - lowest) / (highest - lowest)
+	}
+
+	if searchRadius == 0 {
+		searchRadius = DefaultPlaceSearchRadius
+	}
+	// maxSimilarity[i] is the highest similarity between candidate i and the plans selected so far
+	maxSimilarity := make([]float64, len(solutions))
+	selected := make([]bool, len(solutions))
+	results := make([]PlanningSolution, 0, numPlans)
+	for len(results) < numPlans {
+		best, bestValue := -1, math.Inf(-1)
+		for idx, solution := range solutions {
+			if selected[idx] {
+				continue
+			}
+			value := lambda*normalizedScore(solution.Score) - (1-lambda)*maxSimilarity[idx]
+			if value > bestValue {
+				best, bestValue = idx, value
+			}
+		}
+
+		selected[best] = true
+		results = append(results, solutions[best])
+		for idx, solution := range solutions {
+			if !selected[idx] {
+				maxSimilarity[idx] = max(maxSimilarity[idx], planSimilarity(solutions[best], solution, searchRadius))
+			}
+		}
+	}
+	return results
+}
+
+// planSimilarity is the average of the overlap of the places, the closeness of the place centroids and the overlap
+// of the place types of two plans, each between 0 and 1
+func planSimilarity(a, b PlanningSolution, searchRadius uint) float64 {
+	places := multisetJaccard(a.PlaceIDS, b.PlaceIDS)
+
+	var centroids float64
+	if len(a.PlaceLocations) > 0 && len(b.PlaceLocations) > 0 {
+		distance := utils.HaversineDist(centroid(a.PlaceLocations), centroid(b.PlaceLocations))
+		centroids = max(0, 1-distance/float64(searchRadius))
+	}
+
+	var types float64
+	if len(a.PlaceTypes) > 0 && len(b.PlaceTypes) > 0 {
+		types = multisetJaccard(a.PlaceTypes, b.PlaceTypes)
+	} else {
+		// plans cached before place types were recorded only have categories
+		types = multisetJaccard(a.PlaceCategories, b.PlaceCategories)
+	}
+	return (places + centroids + types) / 3
+}
+
+func centroid(locations [][2]float64) []float64 {
+	var lat, lng float64
+	for _, location := range locations {
+		lat += location[0]
+		lng += location[1]
+	}
+	return []float64{lat / float64(len(locations)), lng / float64(len(locations))}
+}
+
+// multisetJaccard is the size of the intersection over the size of the union of two multisets
+func multisetJaccard[T comparable](a, b []T) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	counts := make(map[T]int)
+	for _, item := range a {
+		counts[item]++
+	}
+	var intersection int
+	for _, item := range b {
+		if counts[item] > 0 {
+			counts[item]--
+			intersection++
+		}
+	}
+	return float64(intersection) / float64(len(a)+len(b)-intersection)
+}
@@ -0,0 +1,89 @@
+package planner
+
+import (
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+)
+
+func diversityTestPlan(id string, score float64, placeIDs []string, lat float64, types ...POI.LocationType) PlanningSolution {
+	plan := PlanningSolution{ID: id, Score: score, PlaceIDS: placeIDs, PlaceTypes: types}
+	for range placeIDs {
+		plan.PlaceLocations = append(plan.PlaceLocations, [2]float64{lat, -74.0060})
+	}
+	return plan
+}
+
+func TestSelectDiverseSolutions_shouldRankByScoreWhenDisabled(t *testing.T) {
+	s := &Solver{}
+	plans := []PlanningSolution{
+		diversityTestPlan("b", 2, []string{"p1", "p3"}, 40.7128),
+		diversityTestPlan("a", 3, []string{"p1", "p2"}, 40.7128),
+		diversityTestPlan("c", 1, []string{"p4", "p5"}, 40.8128),
+	}
+
+	results := s.selectDiverseSolutions(plans, 2, DefaultPlaceSearchRadius)
+	if len(results) != 2 || results[0].ID != "a" || results[1].ID != "b" {
+		t.Errorf("expected plans a and b, got %+v", results)
+	}
+}
+
+func TestSelectDiverseSolutions_shouldPreferDifferentPlans(t *testing.T) {
+	s := &Solver{}
+	s.SetDiversityLambda(0.5)
+	plans := []PlanningSolution{
+		diversityTestPlan("best", 3, []string{"p1", "p2"}, 40.7128, POI.LocationTypeMuseum, POI.LocationTypeRestaurant),
+		diversityTestPlan("one-restaurant-apart", 2.9, []string{"p1", "p3"}, 40.7128, POI.LocationTypeMuseum, POI.LocationTypeRestaurant),
+		diversityTestPlan("different", 2.5, []string{"p4", "p5"}, 40.8128, POI.LocationTypePark, POI.LocationTypeCafe),
+		diversityTestPlan("worst", 1, []string{"p6", "p7"}, 40.9128, POI.LocationTypeGallery, POI.LocationTypeCafe),
+	}
+
+	results := s.selectDiverseSolutions(plans, 2, DefaultPlaceSearchRadius)
+	if len(results) != 2 || results[0].ID != "best" || results[1].ID != "different" {
+		t.Errorf("expected plans best and different, got %+v", results)
+	}
+}
+
+func TestSelectSolutions_shouldKeepCandidatePoolForNearbyCities(t *testing.T) {
+	s := &Solver{}
+	s.SetDiversityLambda(0.5)
+	plans := []PlanningSolution{
+		diversityTestPlan("a", 3, []string{"p1", "p2"}, 40.7128),
+		diversityTestPlan("b", 2, []string{"p1", "p3"}, 40.7128),
+		diversityTestPlan("c", 1, []string{"p4", "p5"}, 40.8128),
+	}
+
+	if results := s.selectSolutions(&PlanningRequest{NumPlans: 1, SearchRadius: DefaultPlaceSearchRadius}, plans); len(results) != 1 {
+		t.Errorf("expected 1 selected plan, got %+v", results)
+	}
+	// the plans of a nearby city are selected together with the plans of the other cities
+	if results := s.selectSolutions(&PlanningRequest{NumPlans: 1, candidatePool: true}, plans); len(results) != len(plans) {
+		t.Errorf("expected the %d candidate plans, got %+v", len(plans), results)
+	}
+}
+
+func TestPlanSimilarity(t *testing.T) {
+	a := diversityTestPlan("a", 1, []string{"p1", "p2"}, 40.7128, POI.LocationTypeMuseum, POI.LocationTypeRestaurant)
+	if similarity := planSimilarity(a, a, DefaultPlaceSearchRadius); similarity != 1 {
+		t.Errorf("expected a plan to be identical to itself, got %f", similarity)
+	}
+
+	b := diversityTestPlan("b", 1, []string{"p3", "p4"}, 41.7128, POI.LocationTypePark, POI.LocationTypeCafe)
+	if similarity := planSimilarity(a, b, DefaultPlaceSearchRadius); similarity != 0 {
+		t.Errorf("expected plans without common places, area or types to have no similarity, got %f", similarity)
+	}
+}
+
+func TestParseDiversityLambda(t *testing.T) {
+	if lambda, err := ParseDiversityLambda(0); err != nil || lambda != DiversityLambdaDisabled {
+		t.Errorf("expected an unset lambda to disable the diversity selection, got %f, %v", lambda, err)
+	}
+	if lambda, err := ParseDiversityLambda(0.7); err != nil || lambda != 0.7 {
+		t.Errorf("expected lambda 0.7, got %f, %v", lambda, err)
+	}
+	for _, lambda := range []float64{-0.1, 1.5} {
+		if _, err := ParseDiversityLambda(lambda); err == nil {
+			t.Errorf("expected an error for lambda %f", lambda)
+		}
+	}
+}
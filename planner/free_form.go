@@ -0,0 +1,304 @@
+package planner
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+// MaxFreeFormStopsPerCategory bounds the candidate places of each category, which keeps the knapsack search small
+const MaxFreeFormStopsPerCategory = 10
+
+// FreeFormPlanningRequest describes a day where the solver decides how many places to visit, in which order and for
+// how long. CategoryMix caps the number of stops of each category, e.g. at most 3 visits and 2 eateries.
+type FreeFormPlanningRequest struct {
+	Location        POI.Location              `json:"location"`
+	TravelDate      string                    `json:"travel_date"` // yyyy-mm-dd
+	StartHour       uint8                     `json:"start_hour"`
+	EndHour         uint8                     `json:"end_hour"`
+	Budget          uint                      `json:"budget"`
+	CategoryMix     map[POI.PlaceCategory]int `json:"category_mix"`
+	SearchRadius    uint                      `json:"radius"`
+	PriceLevel      *POI.PriceLevel           `json:"price_level,omitempty"` // defaults to POI.PriceLevelDefault
+	PreciseLocation bool                      `json:"precise_location"`
+	TravelMode      POI.TravelMode            `json:"travel_mode"`
+
+	closures   POI.Closures    // closures at the destination on the travel date
+	exclusions []POI.Exclusion // best places of the category mix that closures left out
+}
+
+// FreeFormStop is a place of a free-form plan with its arrival and departure times in the format of hh:mm
+type FreeFormStop struct {
+	PlaceID      string            `json:"place_id"`
+	PlaceName    string            `json:"place_name"`
+	Address      string            `json:"address"`
+	Location     [2]float64        `json:"location"` // lat,lng
+	URL          string            `json:"url"`
+	Category     POI.PlaceCategory `json:"category"`
+	StartTime    string            `json:"start_time"`
+	EndTime      string            `json:"end_time"`
+	StayingHours uint8             `json:"staying_hours"`
+}
+
+type FreeFormPlan struct {
+	ID             string              `json:"id"`
+	Date           string              `json:"date"`
+	Weekday        POI.Weekday         `json:"weekday"`
+	Stops          []FreeFormStop      `json:"stops"`
+	Legs           []POI.TravelLeg     `json:"legs"` // travel from each stop to the next one
+	TotalCost      uint                `json:"total_cost"`
+	Score          float64             `json:"score"`
+	ScoreBreakdown *POI.ScoreBreakdown `json:"score_breakdown,omitempty"`
+	Exclusions     []POI.Exclusion     `json:"exclusions,omitempty"` // places left out by closures on the travel date
+}
+
+type FreeFormPlanningResp struct {
+	Plan      *FreeFormPlan
+	Err       error
+	ErrorCode int
+}
+
+// SolveFreeForm plans a day with only a time window, a budget and a category mix. Candidate places are ordered into a
+// nearest-neighbour route from the destination and the knapsack picks the subset that fits the window and the
+// budget with the best total score. Stops are then scheduled one after another with their staying times and the estimated
+// travel time in between; a stop that no longer fits the window or the opening hours once travel is added is dropped.
+func (s *Solver) SolveFreeForm(ctx context.Context, req *FreeFormPlanningRequest) *FreeFormPlanningResp {
+	if err := validateFreeFormRequest(req); err != nil {
+		return &FreeFormPlanningResp{Err: err, ErrorCode: InvalidRequestLocation}
+	}
+	travelMode, err := POI.ParseTravelMode(string(req.TravelMode))
+	if err != nil {
+		return &FreeFormPlanningResp{Err: err, ErrorCode: BadRequest}
+	}
+	req.TravelMode = travelMode
+	if req.SearchRadius == 0 {
+		req.SearchRadius = DefaultPlaceSearchRadius
+	}
+	if resp := s.resolveLocation(ctx, &req.Location, req.PreciseLocation); resp != nil {
+		return &FreeFormPlanningResp{Err: resp.Err, ErrorCode: resp.ErrorCode}
+	}
+
+	req.closures = s.closuresAt(ctx, req.TravelDate, req.Location)
+
+	candidates, err := s.freeFormCandidates(ctx, req, s.blockedPlaces(ctx))
+	if err != nil {
+		return &FreeFormPlanningResp{Err: err, ErrorCode: InternalError}
+	}
+
+	weekday := toWeekday(req.TravelDate)
+	window := matching.TimeInterval{Day: weekday, Start: POI.NewClockTime(int(req.StartHour), 0), End: POI.NewClockTime(int(req.EndHour), 0)}
+	// the knapsack maximizes the sum of the place scores so that it fills the window rather than picking one great place
+	totalScore := func(places []matching.Place) float64 {
+		legs := matching.TravelLegs(places, req.TravelMode)
+		return float64(len(places)) * matching.PlanScore(s.Scorer(), places, legs, int(req.SearchRadius))
+	}
+	places, totalCost, _ := matching.KnapsackWithScore(nearestNeighbourRoute(req.Location, candidates), window, req.Budget, totalScore)
+
+	plan := scheduleFreeFormStops(places, window, req.TravelMode)
+	if len(plan.Stops) == 0 {
+		return &FreeFormPlanningResp{Err: errors.New("cannot fit any place into the time window and budget"), ErrorCode: NoValidSolution}
+	}
+	plan.ID = uuid.NewString()
+	plan.Date = req.TravelDate
+	plan.Weekday = weekday
+	plan.TotalCost = totalCost
+	plan.Exclusions = req.exclusions
+	if len(plan.Stops) < len(places) {
+		places = scheduledPlaces(places, plan.Stops)
+		plan.TotalCost = freeFormCost(places)
+	}
+	breakdown := matching.ExplainPlanScore(s.Scorer(), places, plan.Legs, int(req.SearchRadius))
+	plan.Score, plan.ScoreBreakdown = breakdown.Score, &breakdown
+
+	record := toFreeFormSolutionRecord(req, plan)
+	if err = s.Searcher.GetRedisClient().SavePlanningSolutionRecord(ctx, &record); err != nil {
+		iowrappers.Logger.Error(err)
+	}
+	return &FreeFormPlanningResp{Plan: plan}
+}
+
+func validateFreeFormRequest(req *FreeFormPlanningRequest) error {
+	if err := validateDate(req.TravelDate); err != nil {
+		return err
+	}
+	if req.StartHour >= req.EndHour || req.EndHour > 24 {
+		return errors.New("the day window must start before it ends and end no later than 24")
+	}
+	if len(req.CategoryMix) == 0 {
+		req.CategoryMix = map[POI.PlaceCategory]int{POI.PlaceCategoryVisit: 3, POI.PlaceCategoryEatery: 2}
+	}
+	if req.PriceLevel == nil {
+		priceLevel := POI.PriceLevel(POI.PriceLevelDefault)
+		req.PriceLevel = &priceLevel
+	}
+	for category, count := range req.CategoryMix {
+		if _, ok := POI.ParsePlaceCategory(string(category)); !ok {
+			return fmt.Errorf("unknown place category %s", category)
+		}
+		if count < 0 || count > MaxFreeFormStopsPerCategory {
+			return fmt.Errorf("number of %s stops must be between 0 and %d", category, MaxFreeFormStopsPerCategory)
+		}
+	}
+	return nil
+}
+
+// freeFormCandidates returns the best rated places of each category of the mix, at most as many as the mix allows, so
+// that the knapsack cannot pick more stops of a category than requested. Places closed on the travel date give way to
+// the next best places and are added to the exclusions of the request. Opening hours are checked by the knapsack.
+func (s *Solver) freeFormCandidates(ctx context.Context, req *FreeFormPlanningRequest, blockedPlaceIDs []string) ([]matching.Place, error) {
+	categories := make([]POI.PlaceCategory, 0, len(req.CategoryMix))
+	for category, count := range req.CategoryMix {
+		if count > 0 {
+			categories = append(categories, category)
+		}
+	}
+	slices.Sort(categories)
+
+	matchers := []matching.Matcher{&matching.MatcherForUserRatings{}, &matching.MatcherForPriceRange{}, &matching.MatcherForExcludedPlaces{}}
+	var candidates []matching.Place
+	for _, category := range categories {
+		places, err := matching.NearbySearchForCategory(ctx, s.Searcher, &matching.Request{
+			Radius:             req.SearchRadius,
+			Location:           req.Location,
+			Category:           category,
+			UsePreciseLocation: req.PreciseLocation,
+			PriceLevel:         *req.PriceLevel,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		filterParams := map[matching.FilterCriteria]interface{}{
+			matching.FilterByUserRating: matching.UserRatingFilterParams{MinUserRatings: 1},
+			matching.FilterByPriceRange: matching.PriceRangeFilterParams{Category: category, PriceLevel: *req.PriceLevel},
+		}
+		if len(blockedPlaceIDs) > 0 {
+			filterParams[matching.FilterByExcludedPlaces] = matching.ExcludedPlacesFilterParams{PlaceIDs: blockedPlaceIDs}
+		}
+		for _, m := range matchers {
+			places, err = s.placeMatcher.MatchPlaces(&matching.FilterRequest{Places: places, Params: filterParams}, m)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		slices.SortFunc(places, func(a, b matching.Place) int { return cmp.Compare(s.Scorer().PlaceScore(b), s.Scorer().PlaceScore(a)) })
+		var numPlaces, numExclusions int
+		for _, place := range places {
+			if numPlaces == req.CategoryMix[category] {
+				break
+			}
+			if closure, closed := req.closures.ClosureOf(place.Id(), place.Category); closed {
+				if numExclusions < MaxExclusionsPerSlot {
+					req.exclusions = append(req.exclusions, POI.Exclusion{PlaceID: place.Id(), PlaceName: place.Name(), Date: closure.Date, Reason: closure.Reason})
+					numExclusions++
+				}
+				continue
+			}
+			candidates = append(candidates, place)
+			numPlaces++
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("failed to find any place for location %+v", req.Location)
+	}
+	return candidates, nil
+}
+
+// nearestNeighbourRoute orders places by repeatedly visiting the closest unvisited place, starting from the origin.
+// matching.Knapsack keeps the input order of the places it picks, so the order becomes the visiting order of the day.
+func nearestNeighbourRoute(origin POI.Location, places []matching.Place) []matching.Place {
+	remaining := slices.Clone(places)
+	route := make([]matching.Place, 0, len(places))
+	current := origin
+	for len(remaining) > 0 {
+		nearest := 0
+		for idx := range remaining {
+			if travelDistance(current, remaining[idx].Location()) < travelDistance(current, remaining[nearest].Location()) {
+				nearest = idx
+			}
+		}
+		route = append(route, remaining[nearest])
+		current = remaining[nearest].Location()
+		remaining = slices.Delete(remaining, nearest, nearest+1)
+	}
+	return route
+}
+
+func travelDistance(from, to POI.Location) float64 {
+	return POI.EstimateTravelLeg(from, to, POI.TravelModeWalking).Distance
+}
+
+// scheduleFreeFormStops assigns start and end times to the places picked by the knapsack in minutes after midnight.
+// The first stop starts at the beginning of the window and every later stop starts after the travel from the previous one.
+func scheduleFreeFormStops(places []matching.Place, window matching.TimeInterval, mode POI.TravelMode) *FreeFormPlan {
+	plan := &FreeFormPlan{}
+	var chosen []matching.Place
+	windowEnd := int(window.End)
+	clock := int(window.Start)
+	for _, place := range places {
+		start := clock
+		var leg POI.TravelLeg
+		if len(chosen) > 0 {
+			leg = POI.EstimateTravelLeg(chosen[len(chosen)-1].Location(), place.Location(), mode)
+			start += leg.Minutes
+		}
+		stayingHours := uint8(POI.GetStayingTimeForLocationType(place.Type()))
+		end := start + int(stayingHours)*60
+		if end > windowEnd || !place.IsOpenBetween(matching.TimeInterval{Day: window.Day, Start: POI.ClockTime(start), End: window.End}, uint16(end-start)) {
+			continue
+		}
+
+		if len(chosen) > 0 {
+			plan.Legs = append(plan.Legs, leg)
+		}
+		chosen = append(chosen, place)
+		plan.Stops = append(plan.Stops, FreeFormStop{
+			PlaceID:      place.Id(),
+			PlaceName:    place.Name(),
+			Address:      place.PlaceAddress(),
+			Location:     [2]float64{place.Location().Latitude, place.Location().Longitude},
+			URL:          place.Url(),
+			Category:     place.PlaceCategory(),
+			StartTime:    minutesToClock(start),
+			EndTime:      minutesToClock(end),
+			StayingHours: stayingHours,
+		})
+		clock = end
+	}
+	return plan
+}
+
+// scheduledPlaces returns the places picked by the knapsack that made it into the schedule
+func scheduledPlaces(places []matching.Place, stops []FreeFormStop) []matching.Place {
+	kept := make(map[string]bool, len(stops))
+	for _, stop := range stops {
+		kept[stop.PlaceID] = true
+	}
+	return iowrappers.Filter(places, func(place matching.Place) bool { return kept[place.Id()] })
+}
+
+func freeFormCost(places []matching.Place) uint {
+	var cost uint
+	for _, place := range places {
+		cost += uint(math.Ceil(place.PlacePrice()))
+	}
+	return cost
+}
+
+// minutesToClock formats minutes after midnight as hh:mm, times after midnight as hh:mm of the next day followed by "+1"
+func minutesToClock(minutes int) string {
+	if minutes >= int(POI.EndOfDay) {
+		minutes -= int(POI.EndOfDay)
+		return fmt.Sprintf("%02d:%02d+1", minutes/60, minutes%60)
+	}
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
@@ -0,0 +1,97 @@
+package planner
+
+import (
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+func TestNearestNeighbourRoute_shouldVisitClosestPlaceFirst(t *testing.T) {
+	origin := POI.Location{Latitude: 40.7128, Longitude: -74.0060}
+	places := []matching.Place{
+		makePlace("far", 40.7528, -74.0060),
+		makePlace("near", 40.7138, -74.0060),
+		makePlace("middle", 40.7328, -74.0060),
+	}
+
+	route := nearestNeighbourRoute(origin, places)
+	expected := []string{"near", "middle", "far"}
+	for idx, place := range route {
+		if place.Id() != expected[idx] {
+			t.Errorf("stop %d: expected %s, got %s", idx, expected[idx], place.Id())
+		}
+	}
+	if places[0].Id() != "far" {
+		t.Error("input places should not be reordered")
+	}
+}
+
+func TestScheduleFreeFormStops_shouldAddTravelAndStayingTimes(t *testing.T) {
+	museum := makePlace("museum", 40.7128, -74.0060)
+	museum.Place.LocationType = POI.LocationTypeMuseum
+	cafe := makePlace("cafe", 40.7228, -74.0060)
+	cafe.Place.LocationType = POI.LocationTypeCafe
+	window := matching.TimeInterval{Day: POI.DateMonday, Start: POI.NewClockTime(9, 0), End: POI.NewClockTime(21, 0)}
+
+	plan := scheduleFreeFormStops([]matching.Place{museum, cafe}, window, POI.TravelModeWalking)
+	if len(plan.Stops) != 2 || len(plan.Legs) != 1 {
+		t.Fatalf("expected 2 stops and 1 leg, got %d stops and %d legs", len(plan.Stops), len(plan.Legs))
+	}
+	if plan.Stops[0].StartTime != "09:00" || plan.Stops[0].EndTime != "12:00" {
+		t.Errorf("expected the museum from 09:00 to 12:00, got %s to %s", plan.Stops[0].StartTime, plan.Stops[0].EndTime)
+	}
+	expectedStart := minutesToClock(12*60 + plan.Legs[0].Minutes)
+	expectedEnd := minutesToClock(13*60 + plan.Legs[0].Minutes)
+	if plan.Stops[1].StartTime != expectedStart || plan.Stops[1].EndTime != expectedEnd {
+		t.Errorf("expected the cafe from %s to %s, got %s to %s", expectedStart, expectedEnd, plan.Stops[1].StartTime, plan.Stops[1].EndTime)
+	}
+}
+
+func TestScheduleFreeFormStops_shouldDropStopsPastWindow(t *testing.T) {
+	museum := makePlace("museum", 40.7128, -74.0060)
+	museum.Place.LocationType = POI.LocationTypeMuseum
+	cafe := makePlace("cafe", 40.7228, -74.0060)
+	cafe.Place.LocationType = POI.LocationTypeCafe
+	// the knapsack fits both places into 4 hours, but the walk between them pushes the cafe past the window
+	window := matching.TimeInterval{Day: POI.DateMonday, Start: POI.NewClockTime(9, 0), End: POI.NewClockTime(13, 0)}
+
+	plan := scheduleFreeFormStops([]matching.Place{museum, cafe}, window, POI.TravelModeWalking)
+	if len(plan.Stops) != 1 || plan.Stops[0].PlaceID != "museum" {
+		t.Fatalf("expected only the museum to be scheduled, got %+v", plan.Stops)
+	}
+	if len(plan.Legs) != 0 {
+		t.Errorf("expected no legs for a single stop, got %d", len(plan.Legs))
+	}
+}
+
+func TestValidateFreeFormRequest(t *testing.T) {
+	req := &FreeFormPlanningRequest{TravelDate: "2024-05-06", StartHour: 9, EndHour: 21}
+	if err := validateFreeFormRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if req.CategoryMix[POI.PlaceCategoryVisit] == 0 || req.CategoryMix[POI.PlaceCategoryEatery] == 0 {
+		t.Errorf("expected a default category mix, got %v", req.CategoryMix)
+	}
+	if req.PriceLevel == nil || *req.PriceLevel != POI.PriceLevelDefault {
+		t.Errorf("expected the default price level, got %v", req.PriceLevel)
+	}
+	priceLevel := POI.PriceLevel(POI.PriceLevelZero)
+	req = &FreeFormPlanningRequest{TravelDate: "2024-05-06", StartHour: 9, EndHour: 21, PriceLevel: &priceLevel}
+	if err := validateFreeFormRequest(req); err != nil || *req.PriceLevel != POI.PriceLevelZero {
+		t.Errorf("expected the requested price level to be kept, got %v", *req.PriceLevel)
+	}
+
+	invalid := []*FreeFormPlanningRequest{
+		{TravelDate: "2024-05-06", StartHour: 21, EndHour: 9},
+		{TravelDate: "2024-05-06", StartHour: 9, EndHour: 25},
+		{TravelDate: "2024-05-06", StartHour: 9, EndHour: 21, CategoryMix: map[POI.PlaceCategory]int{"Nightlife": 1}},
+		{TravelDate: "2024-05-06", StartHour: 9, EndHour: 21, CategoryMix: map[POI.PlaceCategory]int{POI.PlaceCategoryVisit: MaxFreeFormStopsPerCategory + 1}},
+		{StartHour: 9, EndHour: 21},
+	}
+	for idx, r := range invalid {
+		if err := validateFreeFormRequest(r); err == nil {
+			t.Errorf("request %d: expected a validation error", idx)
+		}
+	}
+}
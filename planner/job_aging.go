@@ -0,0 +1,133 @@
+package planner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+// JobAgingInterval is how long a queued job waits to be dequeued as a job of the next higher priority, so that low
+// priority jobs are not starved by a steady stream of high priority jobs
+var JobAgingInterval = time.Minute
+
+// jobPriorities are the job priorities from the highest
+var jobPriorities = []iowrappers.JobPriority{iowrappers.JobPriorityHigh, iowrappers.JobPriorityNormal, iowrappers.JobPriorityLow}
+
+// queuedJob is the next job of a priority
+type queuedJob struct {
+	priority   iowrappers.JobPriority
+	enqueuedAt time.Time
+}
+
+// effectivePriority raises the priority of a job by a level per JobAgingInterval waited
+func (j queuedJob) effectivePriority(now time.Time) int {
+	if j.enqueuedAt.IsZero() {
+		return int(j.priority)
+	}
+	return int(j.priority) + int(max(now.Sub(j.enqueuedAt), 0)/JobAgingInterval)
+}
+
+// nextJobIndex returns the index of the job to dequeue among the next jobs of each priority, nil for a priority without
+// jobs: the job with the highest effective priority, the one waiting longest among equals. It returns -1 without jobs,
+// and whether the job was promoted ahead of a job of a higher priority.
+func nextJobIndex(jobs []*queuedJob, now time.Time) (next int, promoted bool) {
+	next = -1
+	for idx, job := range jobs {
+		if job == nil {
+			continue
+		}
+		if next < 0 {
+			next = idx
+			continue
+		}
+		best := jobs[next]
+		if priority, bestPriority := job.effectivePriority(now), best.effectivePriority(now); priority > bestPriority ||
+			priority == bestPriority && job.enqueuedAt.Before(best.enqueuedAt) {
+			next = idx
+		}
+	}
+	if next < 0 {
+		return next, false
+	}
+	for _, job := range jobs {
+		if job != nil && job.priority > jobs[next].priority {
+			return next, true
+		}
+	}
+	return next, false
+}
+
+// JobWaitStats are the depth and the wait times of the jobs of a priority. The wait times of dequeued jobs cover the
+// jobs dequeued by the workers of this server since it started.
+type JobWaitStats struct {
+	Depth              int     `json:"depth"`
+	OldestWaitSeconds  float64 `json:"oldest_wait_seconds"`
+	Dequeued           int64   `json:"dequeued"`
+	AverageWaitSeconds float64 `json:"average_wait_seconds"`
+	MaxWaitSeconds     float64 `json:"max_wait_seconds"`
+}
+
+// JobQueueStats are the wait stats of each priority of a job queue
+type JobQueueStats struct {
+	High   JobWaitStats `json:"high"`
+	Normal JobWaitStats `json:"normal"`
+	Low    JobWaitStats `json:"low"`
+	// Promoted counts the jobs dequeued ahead of jobs of a higher priority by aging
+	Promoted int64 `json:"promoted"`
+}
+
+// priorityStats returns the stats of a priority, unknown priorities are counted as normal ones
+func (s *JobQueueStats) priorityStats(priority iowrappers.JobPriority) *JobWaitStats {
+	switch priority {
+	case iowrappers.JobPriorityHigh:
+		return &s.High
+	case iowrappers.JobPriorityLow:
+		return &s.Low
+	default:
+		return &s.Normal
+	}
+}
+
+// jobWaitRecorder records the wait times of dequeued jobs
+type jobWaitRecorder struct {
+	mu    sync.Mutex
+	stats JobQueueStats
+	// total wait times of the dequeued jobs of each priority
+	totalWaits map[iowrappers.JobPriority]time.Duration
+}
+
+func (r *jobWaitRecorder) record(job queuedJob, promoted bool, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.totalWaits == nil {
+		r.totalWaits = make(map[iowrappers.JobPriority]time.Duration)
+	}
+	wait := now.Sub(job.enqueuedAt)
+	if job.enqueuedAt.IsZero() || wait < 0 {
+		wait = 0
+	}
+	stats := r.stats.priorityStats(job.priority)
+	stats.Dequeued++
+	r.totalWaits[job.priority] += wait
+	stats.AverageWaitSeconds = r.totalWaits[job.priority].Seconds() / float64(stats.Dequeued)
+	stats.MaxWaitSeconds = max(stats.MaxWaitSeconds, wait.Seconds())
+	if promoted {
+		r.stats.Promoted++
+	}
+}
+
+// snapshot returns the recorded stats with the depths and oldest waits of the queued jobs
+func (r *jobWaitRecorder) snapshot(depths map[iowrappers.JobPriority]int, oldest map[iowrappers.JobPriority]time.Time, now time.Time) JobQueueStats {
+	r.mu.Lock()
+	stats := r.stats
+	r.mu.Unlock()
+	for _, priority := range jobPriorities {
+		priorityStats := stats.priorityStats(priority)
+		priorityStats.Depth = depths[priority]
+		if enqueuedAt, ok := oldest[priority]; ok && !enqueuedAt.IsZero() {
+			priorityStats.OldestWaitSeconds = max(now.Sub(enqueuedAt).Seconds(), 0)
+		}
+	}
+	return stats
+}
@@ -0,0 +1,123 @@
+package planner
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+// JobStatusResp is the state of an asynchronous job, with the plans of a completed planning job
+type JobStatusResp struct {
+	ID        string               `json:"id"`
+	Status    iowrappers.JobStatus `json:"status"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+	Results   interface{}          `json:"results,omitempty"`
+	Error     string               `json:"error,omitempty"`
+}
+
+// submitPlanningJob queues the planning request of the customize API and responds with the job ID right away,
+// clients poll the job status for the plans. With nearby=true the plans of the cities near the destination are added
+// as in the planning API, which takes too long for a client to wait on.
+func (p *MyPlanner) submitPlanningJob(ctx *gin.Context) {
+	requestId := requestid.Get(ctx)
+	ctx.Set(requestIdKey, requestId)
+
+	request, err := customPlanningRequest(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.WithNearbyCities, err = strconv.ParseBool(ctx.DefaultQuery("nearby", "false")); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "nearby must be true or false"})
+		return
+	}
+
+	job := &iowrappers.Job{
+		ID:          uuid.NewString(),
+		Name:        AsyncPlanningJobType,
+		Description: "Compute Planning Solutions Asynchronously",
+		Parameters:  request,
+		Status:      iowrappers.JobStatusNew,
+		Priority:    iowrappers.JobPriorityHigh,
+	}
+	// the job is recorded before it is queued, so that it can be polled as soon as its ID is returned
+	if err = p.RedisClient.UpdateJob(ctx, job); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !p.Dispatcher.JobQueue.Enqueue(job) {
+		job.Status = iowrappers.JobStatusFailed
+		job.Error = "job queue is closed"
+		createJobRecord(ctx, job, p.RedisClient)
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": job.Error})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"job_id": job.ID, "status_url": "/v1/jobs/" + job.ID})
+}
+
+// getJob returns the status of a job, the plans of a completed planning job and the error of a failed job
+func (p *MyPlanner) getJob(ctx *gin.Context) {
+	job, err := p.RedisClient.GetJob(ctx, ctx.Param("id"))
+	if errors.Is(err, iowrappers.ErrJobNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := JobStatusResp{
+		ID:        job.ID,
+		Status:    job.Status,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	}
+	switch job.Status {
+	case iowrappers.JobStatusCompleted:
+		resp.Results = job.Result
+	case iowrappers.JobStatusFailed:
+		resp.Error = job.Error
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// cancelJob cancels a queued or running job. A queued job is dropped when a worker dequeues it, a running job stops
+// once its worker sees the cancellation.
+func (p *MyPlanner) cancelJob(ctx *gin.Context) {
+	job, err := p.RedisClient.GetJob(ctx, ctx.Param("id"))
+	if errors.Is(err, iowrappers.ErrJobNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch job.Status {
+	case iowrappers.JobStatusCompleted, iowrappers.JobStatusFailed, iowrappers.JobStatusCancelled:
+		ctx.JSON(http.StatusConflict, gin.H{"error": "job is already " + string(job.Status)})
+		return
+	}
+
+	if err = p.RedisClient.CancelJob(ctx, job.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// a job waiting in the queue is reported as cancelled right away, a running job once its worker stops it
+	if job.Status == iowrappers.JobStatusNew || job.Status == iowrappers.JobStatusRetrying {
+		job.Status = iowrappers.JobStatusCancelled
+		createJobRecord(ctx, job, p.RedisClient)
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{"job_id": job.ID, "status": job.Status})
+}
@@ -0,0 +1,238 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/test/redis_client_mocks"
+)
+
+func TestAsyncPlanningJobHandler_shouldRecordPlansOrFailure(t *testing.T) {
+	ctx := context.Background()
+	redisClient := redis_client_mocks.RedisClient
+	plan := func(ctx context.Context, req *PlanningRequest, user string) PlanningResponse {
+		if req.Location.City == "Atlantis" {
+			return PlanningResponse{Err: errors.New("cannot find a valid solution"), StatusCode: NoValidSolution}
+		}
+		return PlanningResponse{
+			TravelDestination: req.Location.City,
+			TravelPlans:       []TravelPlan{{ID: "plan-" + ctx.Value(iowrappers.ContextRequestIdKey).(string)}},
+			StatusCode:        ValidSolutionFound,
+		}
+	}
+	handler := NewAsyncPlanningJobHandler(plan, redisClient)
+
+	job := &iowrappers.Job{ID: "async-planning-job", Name: AsyncPlanningJobType, Status: iowrappers.JobStatusNew,
+		Parameters: &PlanningRequest{Location: POI.Location{City: "San Francisco", Country: "USA"}}}
+	if err := handler.Execute(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := redisClient.GetJob(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != iowrappers.JobStatusCompleted {
+		t.Fatalf("expected a completed job, got %s", saved.Status)
+	}
+	result, _ := json.Marshal(saved.Result)
+	var resp PlanningResponse
+	if err = json.Unmarshal(result, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.TravelPlans) != 1 || resp.TravelPlans[0].ID != "plan-"+job.ID {
+		t.Errorf("expected the plan computed with the job ID as request ID, got %+v", resp.TravelPlans)
+	}
+
+	// the worker records a failed job as retrying or failed, the handler leaves it running
+	failed := &iowrappers.Job{ID: "async-planning-job-failed", Name: AsyncPlanningJobType, Status: iowrappers.JobStatusNew,
+		Parameters: &PlanningRequest{Location: POI.Location{City: "Atlantis"}}}
+	if err = handler.Execute(ctx, failed); err == nil {
+		t.Error("expected an error for a request without plans")
+	}
+	if saved, err = redisClient.GetJob(ctx, failed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != iowrappers.JobStatusRunning {
+		t.Errorf("expected the failure to be left to the worker, got %+v", saved)
+	}
+}
+
+func TestAsyncPlanningJobHandler_shouldRecordPlansOfNearbyCities(t *testing.T) {
+	ctx := context.Background()
+	p := newNearbyCitiesPlanner(t)
+	handler := NewAsyncPlanningJobHandler(p.Planning, p.RedisClient)
+
+	job := &iowrappers.Job{ID: "async-planning-job-nearby", Name: AsyncPlanningJobType, Status: iowrappers.JobStatusNew,
+		Parameters: nearbyCitiesRequest()}
+	if err := handler.Execute(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := p.RedisClient.GetJob(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, _ := json.Marshal(saved.Result)
+	var resp PlanningResponse
+	if err = json.Unmarshal(result, &resp); err != nil {
+		t.Fatal(err)
+	}
+	planIDs := MapSlice(resp.TravelPlans, func(plan TravelPlan) string { return plan.ID })
+	if !slices.Contains(planIDs, "union-city-museum-plan") {
+		t.Errorf("expected the job result to include the plan of nearby Union City, got %v", planIDs)
+	}
+}
+
+func TestGenericWorker_shouldRecordAsyncPlanningJobFailedAfterLastAttempt(t *testing.T) {
+	policy := jobRetryPolicies[AsyncPlanningJobType]
+	jobRetryPolicies[AsyncPlanningJobType] = RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+	defer func() { jobRetryPolicies[AsyncPlanningJobType] = policy }()
+
+	ctx := context.Background()
+	redisClient := redis_client_mocks.RedisClient
+	attempts := make(chan struct{}, 2)
+	plan := func(ctx context.Context, req *PlanningRequest, user string) PlanningResponse {
+		attempts <- struct{}{}
+		return PlanningResponse{Err: errors.New("maps API unavailable"), StatusCode: InternalError}
+	}
+
+	queue := NewPriorityJobQueue(10)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	worker := NewGenericWorker(0, queue, redisClient, wg)
+	worker.RegisterHandler(NewAsyncPlanningJobHandler(plan, redisClient))
+	worker.Run(ctx)
+
+	job := &iowrappers.Job{ID: "async-planning-job-retried", Name: AsyncPlanningJobType, Status: iowrappers.JobStatusNew,
+		Parameters: &PlanningRequest{Location: POI.Location{City: "San Francisco", Country: "USA"}}}
+	queue.Enqueue(job)
+	for range 2 {
+		select {
+		case <-attempts:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the attempts")
+		}
+	}
+	queue.Close()
+	wg.Wait()
+
+	saved, err := redisClient.GetJob(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != iowrappers.JobStatusFailed || saved.Error != "maps API unavailable" || saved.Attempts != 2 {
+		t.Errorf("expected the job to fail after its last attempt, got %+v", saved)
+	}
+}
+
+func TestPlanningJobsApi(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	p := &MyPlanner{RedisClient: redis_client_mocks.RedisClient, Dispatcher: NewDispatcher(nil, redis_client_mocks.RedisClient)}
+	router := gin.New()
+	router.POST("/v1/jobs", p.submitPlanningJob)
+	router.GET("/v1/jobs/:id", p.getJob)
+	router.DELETE("/v1/jobs/:id", p.cancelJob)
+
+	body := `{"location": {"city": "San Francisco", "country": "USA"}, "slots": [{"time_slot": {"slot": {"start": 10, "end": 12}}, "category": "Visit"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs?date=2026-12-01", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected %d, got %d (%s)", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var submitted struct {
+		JobID     string `json:"job_id"`
+		StatusURL string `json:"status_url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &submitted); err != nil {
+		t.Fatal(err)
+	}
+
+	job := p.Dispatcher.JobQueue.Dequeue()
+	if job.ID != submitted.JobID || job.Name != AsyncPlanningJobType {
+		t.Fatalf("expected the queued planning job %s, got %+v", submitted.JobID, job)
+	}
+	if planningReq := job.Parameters.(*PlanningRequest); planningReq.TravelDate != "2026-12-01" || planningReq.Slots[0].Weekday != POI.DateTuesday || planningReq.WithNearbyCities {
+		t.Errorf("expected a request on Tuesday 2026-12-01, got %+v", planningReq)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, submitted.StatusURL, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d (%s)", http.StatusOK, w.Code, w.Body.String())
+	}
+	var status JobStatusResp
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != iowrappers.JobStatusNew || status.Results != nil {
+		t.Errorf("expected a new job without results, got %+v", status)
+	}
+
+	for _, expected := range []int{http.StatusAccepted, http.StatusConflict} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, submitted.StatusURL, nil))
+		if w.Code != expected {
+			t.Fatalf("expected %d, got %d (%s)", expected, w.Code, w.Body.String())
+		}
+	}
+	if cancelled, err := p.RedisClient.GetJob(context.Background(), submitted.JobID); err != nil || cancelled.Status != iowrappers.JobStatusCancelled {
+		t.Errorf("expected the queued job to be cancelled, got %+v (%v)", cancelled, err)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/jobs/unknown-job", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected %d for an unknown job, got %d", http.StatusNotFound, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/jobs?date=2026-12-01&nearby=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected %d, got %d (%s)", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	if job = p.Dispatcher.JobQueue.Dequeue(); !job.Parameters.(*PlanningRequest).WithNearbyCities {
+		t.Errorf("expected a request with the plans of nearby cities, got %+v", job.Parameters)
+	}
+
+	for _, query := range []string{"date=12-01", "date=2026-12-01&nearby=maybe"} {
+		req = httptest.NewRequest(http.MethodPost, "/v1/jobs?"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected %d for %s, got %d", http.StatusBadRequest, query, w.Code)
+		}
+	}
+}
+
+func TestPlanningResponse_shouldWriteErrorMessage(t *testing.T) {
+	data, err := json.Marshal(PlanningResponse{Err: errors.New("cannot find a valid solution"), StatusCode: NoValidSolution})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"error":"cannot find a valid solution"`) {
+		t.Errorf("expected the error message in %s", data)
+	}
+
+	var resp PlanningResponse
+	if err = json.Unmarshal(data, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Err == nil || resp.Err.Error() != "cannot find a valid solution" || resp.StatusCode != NoValidSolution {
+		t.Errorf("expected the response to round trip, got %+v", resp)
+	}
+}
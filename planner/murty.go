@@ -0,0 +1,159 @@
+package planner
+
+import (
+	"container/heap"
+	"errors"
+	"slices"
+
+	hungarianAlgorithm "github.com/oddg/hungarian-algorithm"
+)
+
+// unassignableWeight marks a place that is not a candidate of a slot in the weight matrix
+const unassignableWeight = -1
+
+// assignment assigns a distinct place, by its column in the weight matrix, to each slot
+type assignment struct {
+	columns []int
+	weight  int
+}
+
+// murtyNode is a subset of the assignments: those that keep the forced column of each slot and that do not assign
+// any of the forbidden columns to their slots. best is the assignment with the highest weight in the subset.
+type murtyNode struct {
+	forced    []int    // column forced for each slot, -1 for a free slot
+	forbidden [][]bool // forbidden[slot][column]
+	best      assignment
+}
+
+// Key makes MinPriorityQueue pop the node with the heaviest assignment first
+func (n murtyNode) Key() float64 {
+	return -float64(n.best.weight)
+}
+
+// assignmentIterator enumerates the assignments of places to slots in descending total weight with Murty's
+// algorithm. The assignments not returned yet are partitioned into subsets, each with its heaviest assignment solved
+// by the Hungarian algorithm, so no two assignments returned give every slot the same place.
+type assignmentIterator struct {
+	weights  [][]int // one row per slot, one column per place
+	frontier *MinPriorityQueue[murtyNode]
+}
+
+func newAssignmentIterator(weights [][]int) (*assignmentIterator, error) {
+	if len(weights) == 0 {
+		return nil, errors.New("no slots to assign places to")
+	}
+	if len(weights) > len(weights[0]) {
+		return nil, errors.New("fewer places than slots")
+	}
+	it := &assignmentIterator{weights: weights, frontier: &MinPriorityQueue[murtyNode]{}}
+
+	root := murtyNode{forced: make([]int, len(weights)), forbidden: make([][]bool, len(weights))}
+	for slot := range weights {
+		root.forced[slot] = -1
+		root.forbidden[slot] = make([]bool, len(weights[0]))
+	}
+	if err := it.push(root); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// Next returns the heaviest assignment not returned yet, or false when every assignment has been returned
+func (it *assignmentIterator) Next() (assignment, bool, error) {
+	if it.frontier.Len() == 0 {
+		return assignment{}, false, nil
+	}
+	node := heap.Pop(it.frontier).(murtyNode)
+
+	// partition the rest of the node into subsets that agree with its best assignment on the first slots and
+	// differ from it on the next one, slots forced by the node agree with it already
+	forced := slices.Clone(node.forced)
+	forbidden := node.forbidden
+	for slot, column := range node.best.columns {
+		if node.forced[slot] >= 0 {
+			continue
+		}
+		child := murtyNode{forced: slices.Clone(forced), forbidden: make([][]bool, len(forbidden))}
+		copy(child.forbidden, forbidden)
+		child.forbidden[slot] = slices.Clone(forbidden[slot])
+		child.forbidden[slot][column] = true
+		if err := it.push(child); err != nil {
+			return assignment{}, false, err
+		}
+		forced[slot] = column
+	}
+	return node.best, true, nil
+}
+
+// push solves the node and adds it to the frontier unless no assignment satisfies its constraints
+func (it *assignmentIterator) push(node murtyNode) error {
+	best, feasible, err := it.solve(node)
+	if err != nil || !feasible {
+		return err
+	}
+	node.best = best
+	heap.Push(it.frontier, node)
+	return nil
+}
+
+// solve finds the heaviest assignment of a node with the Hungarian algorithm, which minimizes the cost of a square
+// matrix. The weights are subtracted from the maximum weight, and rows that do not stand for a slot are padded in
+// at no cost so that the places left out of an assignment take them. Entries that break the constraints of the
+// node cost more than any assignment without them, so the assignment is infeasible if it contains one.
+func (it *assignmentIterator) solve(node murtyNode) (assignment, bool, error) {
+	numSlots, numPlaces := len(it.weights), len(it.weights[0])
+	var maxWeight int
+	for _, row := range it.weights {
+		for _, w := range row {
+			maxWeight = max(maxWeight, w)
+		}
+	}
+	infinity := maxWeight*numSlots + 1
+
+	forcedColumns := make(map[int]bool)
+	for _, column := range node.forced {
+		if column >= 0 {
+			forcedColumns[column] = true
+		}
+	}
+
+	costs := make([][]int, numPlaces)
+	for row := range costs {
+		costs[row] = make([]int, numPlaces)
+		for column := range costs[row] {
+			if row >= numSlots {
+				if forcedColumns[column] {
+					costs[row][column] = infinity
+				}
+				continue
+			}
+			w := it.weights[row][column]
+			switch {
+			case w == unassignableWeight, node.forbidden[row][column]:
+				costs[row][column] = infinity
+			case node.forced[row] >= 0 && node.forced[row] != column:
+				costs[row][column] = infinity
+			case node.forced[row] < 0 && forcedColumns[column]:
+				costs[row][column] = infinity
+			default:
+				costs[row][column] = maxWeight - w
+			}
+		}
+	}
+
+	columns, err := hungarianAlgorithm.Solve(costs)
+	if err != nil {
+		return assignment{}, false, err
+	}
+	for row, column := range columns {
+		if costs[row][column] == infinity {
+			return assignment{}, false, nil
+		}
+	}
+
+	res := assignment{columns: columns[:numSlots]}
+	for slot, column := range res.columns {
+		res.weight += it.weights[slot][column]
+	}
+	return res, true, nil
+}
@@ -0,0 +1,82 @@
+package planner
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// allAssignmentWeights returns the weights of every assignment of distinct columns to the rows, heaviest first
+func allAssignmentWeights(weights [][]int) []int {
+	var res []int
+	used := make([]bool, len(weights[0]))
+	var assign func(row, weight int)
+	assign = func(row, weight int) {
+		if row == len(weights) {
+			res = append(res, weight)
+			return
+		}
+		for column, w := range weights[row] {
+			if w == unassignableWeight || used[column] {
+				continue
+			}
+			used[column] = true
+			assign(row+1, weight+w)
+			used[column] = false
+		}
+	}
+	assign(0, 0)
+	slices.Sort(res)
+	slices.Reverse(res)
+	return res
+}
+
+func TestAssignmentIterator_shouldEnumerateAssignmentsByDescendingWeight(t *testing.T) {
+	r := rand.New(rand.NewSource(11))
+	for trial := 0; trial < 30; trial++ {
+		numSlots, numPlaces := 1+r.Intn(3), 3+r.Intn(3)
+		weights := make([][]int, numSlots)
+		for slot := range weights {
+			weights[slot] = make([]int, numPlaces)
+			for column := range weights[slot] {
+				weights[slot][column] = unassignableWeight
+				if r.Intn(4) > 0 {
+					weights[slot][column] = r.Intn(500)
+				}
+			}
+		}
+		expected := allAssignmentWeights(weights)
+
+		it, err := newAssignmentIterator(weights)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []int
+		seen := make(map[string]bool)
+		for {
+			next, found, err := it.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !found {
+				break
+			}
+			key := fmt.Sprint(next.columns)
+			if seen[key] {
+				t.Fatalf("trial %d: assignment %v returned twice", trial, next.columns)
+			}
+			seen[key] = true
+			actual = append(actual, next.weight)
+		}
+		if !slices.Equal(expected, actual) {
+			t.Errorf("trial %d: expected weights %v, got %v", trial, expected, actual)
+		}
+	}
+}
+
+func TestNewAssignmentIterator_shouldRejectMoreSlotsThanPlaces(t *testing.T) {
+	if _, err := newAssignmentIterator([][]int{{1}, {2}}); err == nil {
+		t.Error("expected an error for two slots and a single place")
+	}
+}
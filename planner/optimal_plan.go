@@ -0,0 +1,182 @@
+package planner
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+const (
+	// MaxOptimalPlans bounds the plans returned per request
+	MaxOptimalPlans = 20
+	// MaxOptimalPlanCandidates bounds the assignments evaluated per request. With a proximity weight the travel
+	// penalty can rank an assignment below others with lower place scores, so more assignments than plans are needed.
+	MaxOptimalPlanCandidates = 200
+)
+
+// OptimalPlanRequest asks for the best assignments of distinct places to the slots of a planning request.
+// ProximityWeight scales the travel penalty of the solver scorer. At zero the plans are ranked by their place scores
+// alone, a positive weight prefers plans with places close to each other.
+type OptimalPlanRequest struct {
+	PlanningRequest
+	ProximityWeight float64 `json:"proximity_weight"`
+}
+
+// SolveHungarianOptimal returns the NumPlans best plans among the assignments of places to slots enumerated by
+// Murty's algorithm in descending total place score. The plans are saved as plan records, so they can be fetched,
+// customized and swapped like the plans of the regular planning API.
+func (s *Solver) SolveHungarianOptimal(ctx context.Context, req *OptimalPlanRequest) *PlanningResp {
+	planningReq := &req.PlanningRequest
+	travelMode, err := POI.ParseTravelMode(string(planningReq.TravelMode))
+	if err != nil {
+		return &PlanningResp{Err: err, ErrorCode: BadRequest}
+	}
+	planningReq.TravelMode = travelMode
+	if len(planningReq.Slots) == 0 {
+		return &PlanningResp{Err: errors.New("no slots to plan"), ErrorCode: InvalidRequestLocation}
+	}
+	for _, slot := range planningReq.Slots {
+		if err = validateSlotRequest(slot); err != nil {
+			return &PlanningResp{Err: err, ErrorCode: InvalidRequestLocation}
+		}
+	}
+	if req.ProximityWeight < 0 {
+		return &PlanningResp{Err: errors.New("proximity weight cannot be negative"), ErrorCode: InvalidRequestLocation}
+	}
+	if resp := s.resolveLocation(ctx, &planningReq.Location, planningReq.PreciseLocation); resp != nil {
+		return resp
+	}
+	if err = s.resolveAnchors(ctx, planningReq); err != nil {
+		return &PlanningResp{Err: err, ErrorCode: InvalidRequestLocation}
+	}
+	if planningReq.NumPlans <= 0 {
+		planningReq.NumPlans = NumPlansDefault
+	}
+	planningReq.NumPlans = min(planningReq.NumPlans, MaxOptimalPlans)
+	planningReq.blockedPlaceIDs = s.blockedPlaces(ctx)
+	planningReq.closures = s.closuresAt(ctx, planningReq.TravelDate, planningReq.Location)
+
+	placeClusters, err := s.generatePlacesForSlots(ctx, planningReq)
+	if err != nil {
+		return &PlanningResp{Err: err, ErrorCode: placesErrorCode(err)}
+	}
+	scorer := matching.ProximityScorer{Scorer: s.Scorer(), ProximityWeight: req.ProximityWeight}
+	solutions, err := s.findOptimalPlans(ctx, placeClusters, planningReq, scorer)
+	if err != nil {
+		return &PlanningResp{Err: err, ErrorCode: InternalError}
+	}
+	if len(solutions) == 0 {
+		return &PlanningResp{Err: errors.New("cannot find solutions"), ErrorCode: NoValidSolution}
+	}
+
+	s.fillPlaceDetails(ctx, solutions)
+	redisClient := s.Searcher.GetRedisClient()
+	for _, solution := range solutions {
+		record := toPlanningSolutionRecord(planningReq, solution, planningReq.Location)
+		if err = redisClient.SavePlanningSolutionRecord(ctx, &record); err != nil {
+			iowrappers.Logger.Error(err)
+		}
+	}
+	return &PlanningResp{Solutions: solutions}
+}
+
+// findOptimalPlans scores the assignments of places to slots in descending total place score and keeps the best
+// req.NumPlans plans. An assignment scores at most its average weight plus a hundredth, as weights are truncated
+// place scores in hundredths and penalties are not negative, so the search stops once that bound falls below the
+// score of the worst plan kept.
+func (s *Solver) findOptimalPlans(ctx context.Context, placeClusters [][]matching.Place, req *PlanningRequest, scorer matching.Scorer) ([]PlanningSolution, error) {
+	placeIds, weights, err := s.weightMatrix(placeClusters)
+	if err != nil {
+		return nil, err
+	}
+	assignments, err := newAssignmentIterator(weights)
+	if err != nil {
+		return nil, err
+	}
+
+	// maps the column of a place to its index in the cluster of each slot
+	clusterIndexes := make([]map[string]int, len(placeClusters))
+	for slot, places := range placeClusters {
+		clusterIndexes[slot] = make(map[string]int, len(places))
+		for idx, place := range places {
+			clusterIndexes[slot][place.Id()] = idx
+		}
+	}
+
+	numSlots := len(placeClusters)
+	kept := &MinPriorityQueue[PlanningSolution]{}
+	for candidates := 0; candidates < MaxOptimalPlanCandidates && ctx.Err() == nil; candidates++ {
+		next, found, err := assignments.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			break
+		}
+		bound := float64(next.weight+numSlots) / float64(100*numSlots)
+		if kept.Len() == req.NumPlans && bound < kept.items[0].Score {
+			break
+		}
+
+		placeIndexes := make([]int, numSlots)
+		for slot, column := range next.columns {
+			placeIndexes[slot] = clusterIndexes[slot][placeIds[column]]
+		}
+		candidate, err := createPlanningSolutionCandidate(placeIndexes, placeClusters, req, scorer)
+		if err != nil {
+			log.Debug(err)
+			continue
+		}
+		if kept.Len() < req.NumPlans {
+			heap.Push(kept, candidate)
+		} else if kept.items[0].Score < candidate.Score {
+			heap.Pop(kept)
+			heap.Push(kept, candidate)
+		}
+	}
+
+	res := make([]PlanningSolution, 0, kept.Len())
+	for kept.Len() > 0 {
+		res = append(res, heap.Pop(kept).(PlanningSolution))
+	}
+	slices.Reverse(res)
+	return res, nil
+}
+
+// fillPlaceDetails updates the names, addresses and URLs of the places of the plans from their stored records,
+// which are fetched in one round trip
+func (s *Solver) fillPlaceDetails(ctx context.Context, solutions []PlanningSolution) {
+	var placeIDs []string
+	for _, solution := range solutions {
+		placeIDs = append(placeIDs, solution.PlaceIDS...)
+	}
+	slices.Sort(placeIDs)
+	places, err := s.Searcher.GetRedisClient().CachedPlaces(ctx, slices.Compact(placeIDs))
+	if err != nil {
+		iowrappers.Logger.Error(err)
+		return
+	}
+
+	for _, solution := range solutions {
+		for idx, id := range solution.PlaceIDS {
+			place, ok := places[id]
+			if !ok {
+				continue
+			}
+			solution.PlaceNames[idx] = place.Name
+			if len(place.FormattedAddress) > 0 {
+				solution.PlaceAddresses[idx] = place.FormattedAddress
+			}
+			if len(strings.TrimSpace(place.URL)) > 0 {
+				solution.PlaceURLs[idx] = place.URL
+			}
+		}
+	}
+}
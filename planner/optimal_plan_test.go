@@ -0,0 +1,104 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"net/url"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/test/redis_client_mocks"
+)
+
+func TestFindOptimalPlans_shouldMatchExhaustiveSearch(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	for _, numSlots := range []int{1, 2, 3} {
+		for _, proximityWeight := range []float64{0, 1} {
+			clusters, req := randomPlaceClusters(r, numSlots, 6)
+			req.TravelMode = POI.TravelModeWalking
+			req.NumPlans = 5
+			scorer := matching.ProximityScorer{Scorer: matching.DefaultScorer{}, ProximityWeight: proximityWeight}
+			// a dedupe limit above the number of plans keeps every combination of places a candidate
+			s := &Solver{placeDedupeCountLimit: 1000, scorer: scorer}
+
+			iterator := &MultiDimIterator{}
+			if err := iterator.Init(toPlaceCategories(req.Slots), clusters); err != nil {
+				t.Fatal(err)
+			}
+			exhaustive := s.FindBestPlanningSolutions(context.Background(), clusters, req.NumPlans, iterator, req)
+			optimal, err := s.findOptimalPlans(context.Background(), clusters, req, scorer)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(exhaustive.Solutions) != len(optimal) {
+				t.Fatalf("%d slots, weight %v: expected %d plans, got %d", numSlots, proximityWeight, len(exhaustive.Solutions), len(optimal))
+			}
+			for idx := range optimal {
+				if math.Abs(exhaustive.Solutions[idx].Score-optimal[idx].Score) > 1e-9 {
+					t.Errorf("%d slots, weight %v, plan %d: expected score %v, got %v", numSlots, proximityWeight, idx, exhaustive.Solutions[idx].Score, optimal[idx].Score)
+				}
+			}
+		}
+	}
+}
+
+func TestFindOptimalPlans_shouldPreferCloserPlacesWithProximityWeight(t *testing.T) {
+	place := func(id string, ratings int, lat float64) matching.Place {
+		return matching.Place{Place: &POI.Place{ID: id, Rating: 4.5, UserRatingsTotal: ratings, Location: POI.Location{Latitude: lat, Longitude: -122.4}}, Price: 1}
+	}
+	clusters := [][]matching.Place{
+		{place("museum", 1000, 37.70)},
+		{place("far-lunch", 1200, 37.85), place("near-lunch", 1000, 37.701)},
+	}
+	req := &PlanningRequest{
+		SearchRadius: DefaultPlaceSearchRadius,
+		TravelMode:   POI.TravelModeWalking,
+		NumPlans:     1,
+		Slots: []SlotRequest{
+			{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(12, 0)}}, Category: POI.PlaceCategoryVisit},
+			{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(15, 0), End: POI.NewClockTime(17, 0)}}, Category: POI.PlaceCategoryEatery},
+		},
+	}
+	s := &Solver{}
+
+	for weight, expected := range map[float64]string{0: "far-lunch", 10: "near-lunch"} {
+		scorer := matching.ProximityScorer{Scorer: s.Scorer(), ProximityWeight: weight}
+		plans, err := s.findOptimalPlans(context.Background(), clusters, req, scorer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(plans) != 1 || plans[0].PlaceIDS[1] != expected {
+			t.Errorf("proximity weight %v: expected lunch at %s, got %+v", weight, expected, plans)
+		}
+	}
+}
+
+func TestFillPlaceDetails_shouldUseStoredPlaceRecords(t *testing.T) {
+	redisURL, _ := url.Parse("redis://" + redis_client_mocks.RedisMockSvr.Addr())
+	s := &Solver{Searcher: iowrappers.CreatePoiSearcher("fake-api-key", redisURL)}
+
+	stored := POI.Place{ID: "optimal-stored", Name: "Stored Name", FormattedAddress: "1 Main St", URL: "https://example.com/stored"}
+	placeJson, _ := json.Marshal(stored)
+	if err := redis_client_mocks.RedisMockSvr.Set(iowrappers.PlaceDetailsRedisKeyPrefix+stored.ID, string(placeJson)); err != nil {
+		t.Fatal(err)
+	}
+	solutions := []PlanningSolution{{
+		PlaceIDS:       []string{"optimal-stored", "optimal-missing"},
+		PlaceNames:     []string{"Old Name", "Missing"},
+		PlaceAddresses: []string{"", "2 Main St"},
+		PlaceURLs:      []string{iowrappers.GoogleSearchHomePageURL, iowrappers.GoogleSearchHomePageURL},
+	}}
+
+	s.fillPlaceDetails(context.Background(), solutions)
+	if solutions[0].PlaceNames[0] != stored.Name || solutions[0].PlaceAddresses[0] != stored.FormattedAddress || solutions[0].PlaceURLs[0] != stored.URL {
+		t.Errorf("expected the stored details of the first place, got %+v", solutions[0])
+	}
+	if solutions[0].PlaceNames[1] != "Missing" || solutions[0].PlaceAddresses[1] != "2 Main St" {
+		t.Errorf("expected the place without a record to keep its details, got %+v", solutions[0])
+	}
+}
@@ -39,6 +39,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/weihesdlegend/Vacation-planner/POI"
 	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
 	"github.com/weihesdlegend/Vacation-planner/user"
 	"github.com/weihesdlegend/Vacation-planner/utils"
 
@@ -64,8 +65,11 @@ const (
 var geocodes map[string]string
 
 var placeTypeToIcon = map[POI.PlaceCategory]POI.PlaceIcon{
-	POI.PlaceCategoryEatery: POI.PlaceIconEatery,
-	POI.PlaceCategoryVisit:  POI.PlaceIconVisit,
+	POI.PlaceCategoryEatery:   POI.PlaceIconEatery,
+	POI.PlaceCategoryVisit:    POI.PlaceIconVisit,
+	POI.PlaceCategoryShopping: POI.PlaceIconShopping,
+	POI.PlaceCategoryLodging:  POI.PlaceIconLodging,
+	POI.PlaceCategoryWellness: POI.PlaceIconWellness,
 }
 
 type MyPlanner struct {
@@ -84,24 +88,30 @@ type MyPlanner struct {
 	MapsClientApiKey   string
 	BlobBucket         string
 	Dispatcher         *Dispatcher
+	CacheWarmer        *CacheWarmer // nil unless cache warming is enabled
 }
 
 type TimeSectionPlace struct {
 	ID        string            `json:"id"`
 	PlaceName string            `json:"place_name"`
 	Category  POI.PlaceCategory `json:"category"`
-	StartTime POI.Hour          `json:"start_time"`
-	EndTime   POI.Hour          `json:"end_time"`
+	StartTime POI.ClockTime     `json:"start_time"`
+	EndTime   POI.ClockTime     `json:"end_time"`
 	Address   string            `json:"address"`
 	URL       string            `json:"url"`
 	PlaceIcon string            `json:"place_icon_css_class"`
 }
 
 type TravelPlan struct {
-	ID           string             `json:"id"`
-	Places       []TimeSectionPlace `json:"places"`
-	Saved        bool               `json:"saved"`
-	PlanningSpec string             `json:"planning_spec"`
+	ID             string              `json:"id"`
+	Places         []TimeSectionPlace  `json:"places"`
+	Legs           []POI.TravelLeg     `json:"legs"`                // travel between consecutive places
+	StartLeg       *POI.TravelLeg      `json:"start_leg,omitempty"` // travel from the start location, e.g. the hotel
+	EndLeg         *POI.TravelLeg      `json:"end_leg,omitempty"`   // travel back to the end location
+	ScoreBreakdown *POI.ScoreBreakdown `json:"score_breakdown,omitempty"`
+	Exclusions     []POI.Exclusion     `json:"exclusions,omitempty"` // places left out by closures on the travel date
+	Saved          bool                `json:"saved"`
+	PlanningSpec   string              `json:"planning_spec"`
 }
 
 type PlanningResponse struct {
@@ -112,6 +122,46 @@ type PlanningResponse struct {
 	StatusCode        int          `json:"status_code"`
 }
 
+// planningResponseJSON writes the error of a planning response as its message, errors have no exported fields and
+// would be written as {}
+type planningResponseJSON struct {
+	TravelDestination string       `json:"travel_destination"`
+	TravelPlans       []TravelPlan `json:"travel_plans"`
+	TripDetailsURL    []string     `json:"trip_details_url"`
+	Err               string       `json:"error,omitempty"`
+	StatusCode        int          `json:"status_code"`
+}
+
+func (resp PlanningResponse) MarshalJSON() ([]byte, error) {
+	out := planningResponseJSON{
+		TravelDestination: resp.TravelDestination,
+		TravelPlans:       resp.TravelPlans,
+		TripDetailsURL:    resp.TripDetailsURL,
+		StatusCode:        resp.StatusCode,
+	}
+	if resp.Err != nil {
+		out.Err = resp.Err.Error()
+	}
+	return json.Marshal(out)
+}
+
+func (resp *PlanningResponse) UnmarshalJSON(data []byte) error {
+	var in planningResponseJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*resp = PlanningResponse{
+		TravelDestination: in.TravelDestination,
+		TravelPlans:       in.TravelPlans,
+		TripDetailsURL:    in.TripDetailsURL,
+		StatusCode:        in.StatusCode,
+	}
+	if in.Err != "" {
+		resp.Err = errors.New(in.Err)
+	}
+	return nil
+}
+
 type PlanningPostRequest struct {
 	Country   string      `json:"country"`
 	City      string      `json:"city"`
@@ -131,6 +181,7 @@ type TripDetailResp struct {
 	TravelDestination string
 	TravelDate        string
 	Score             float64
+	ScoreBreakdown    *POI.ScoreBreakdown
 	ApiKey            string
 }
 
@@ -190,6 +241,30 @@ func (p *MyPlanner) Init(mapsClientApiKey string, redisURL *url.URL, redisStream
 		logger.Fatal("failed to initialize the planner")
 	}
 
+	if v, exists := p.Configs["server:plan_solver:search_strategy"]; exists {
+		strategy, err := ParseSearchStrategy(v.(string))
+		if err != nil {
+			logger.Fatal(err)
+		}
+		p.Solver.SetSearchStrategy(strategy)
+	}
+
+	if v, exists := p.Configs["server:plan_solver:scorers"]; exists {
+		scorer, err := matching.NewScorer(v.(map[string]float64))
+		if err != nil {
+			logger.Fatal(err)
+		}
+		p.Solver.SetScorer(scorer)
+	}
+
+	if v, exists := p.Configs["server:plan_solver:diversity_lambda"]; exists {
+		lambda, err := ParseDiversityLambda(v.(float64))
+		if err != nil {
+			logger.Fatal(err)
+		}
+		p.Solver.SetDiversityLambda(lambda)
+	}
+
 	var placeDetailsFields []string
 	if v, exists := p.Configs["server:google_maps:detailed_search_fields"]; exists {
 		placeDetailsFields = v.([]string)
@@ -220,7 +295,45 @@ func (p *MyPlanner) Init(mapsClientApiKey string, redisURL *url.URL, redisStream
 			logger.Fatalf("p failed to create a Mailer: %s", err.Error())
 		}
 	}
+	if v, exists := p.Configs["server:closures:data_file"]; exists && v.(string) != "" {
+		numClosures, loadErr := p.RedisClient.LoadClosures(context.Background(), v.(string))
+		if loadErr != nil {
+			logger.Errorf("failed to load closures from %s: %v", v, loadErr)
+		}
+		logger.Debugf("loaded %d closures from %s", numClosures, v)
+	}
+
 	p.Dispatcher = NewDispatcher(&p.Solver, p.RedisClient)
+	if v, exists := p.Configs["server:job_queue:backend"]; exists && v.(string) == JobQueueBackendRedisStreams {
+		reclaimIdle := time.Duration(p.Configs["server:job_queue:reclaim_idle_seconds"].(int)) * time.Second
+		hostname, _ := os.Hostname()
+		consumer := fmt.Sprintf("%s-%d", hostname, os.Getpid())
+		queue, queueErr := NewRedisStreamJobQueue(p.RedisClient, JobStreamConsumerGroup, consumer, reclaimIdle)
+		if queueErr != nil {
+			logger.Fatalf("failed to create the job queue: %v", queueErr)
+		}
+		p.Dispatcher.JobQueue = queue
+	}
+	p.Dispatcher.RegisterHandler(NewAsyncPlanningJobHandler(p.Planning, p.RedisClient))
+
+	// missing cache warming settings are zero and take the defaults
+	intervalMinutes, _ := p.Configs["server:cache_warming:interval_minutes"].(int)
+	demandWindowHours, _ := p.Configs["server:cache_warming:demand_window_hours"].(int)
+	maxCities, _ := p.Configs["server:cache_warming:max_cities"].(int)
+	daysAhead, _ := p.Configs["server:cache_warming:days_ahead"].(int)
+	refreshBeforeMinutes, _ := p.Configs["server:cache_warming:refresh_before_minutes"].(int)
+	cacheWarmingConfig := CacheWarmingConfig{
+		Interval:      time.Duration(intervalMinutes) * time.Minute,
+		DemandWindow:  time.Duration(demandWindowHours) * time.Hour,
+		MaxCities:     maxCities,
+		DaysAhead:     daysAhead,
+		RefreshBefore: time.Duration(refreshBeforeMinutes) * time.Minute,
+	}.withDefaults()
+	// servers with cache warming disabled still run the warming jobs queued by the other servers
+	p.Dispatcher.RegisterHandler(NewCacheWarmingJobHandler(&p.Solver, p.RedisClient, cacheWarmingConfig.RefreshBefore))
+	if v, exists := p.Configs["server:cache_warming:enabled"]; exists && v.(bool) {
+		p.CacheWarmer = NewCacheWarmer(p.RedisClient, p.Dispatcher.JobQueue, cacheWarmingConfig)
+	}
 	logger.Info("The planner initialization process completes")
 }
 
@@ -521,7 +634,7 @@ func (p *MyPlanner) Planning(ctx context.Context, planningRequest *PlanningReque
 	if nearbyCitiesPlanningResponse.Err != nil {
 		return resp
 	}
-	return p.processPlanningResp(ctx, planningRequest, primaryLocationPlanningResponse, user)
+	return p.processPlanningResp(ctx, planningRequest, nearbyCitiesPlanningResponse, user)
 }
 
 func (p *MyPlanner) processPlanningResp(ctx context.Context, request *PlanningRequest, resp *PlanningResp, user string) PlanningResponse {
@@ -571,6 +684,10 @@ func (p *MyPlanner) processPlanningResp(ctx context.Context, request *PlanningRe
 			})
 		}
 		travelPlan.ID = solution.ID
+		travelPlan.Legs = solution.Legs
+		travelPlan.StartLeg, travelPlan.EndLeg = solution.StartLeg, solution.EndLeg
+		travelPlan.ScoreBreakdown = solution.ScoreBreakdown
+		travelPlan.Exclusions = solution.Exclusions
 		response.TravelPlans[idx] = travelPlan
 		response.TripDetailsURL[idx] = "/v1/plans/" + travelPlan.ID + "?date=" + request.TravelDate
 	}
@@ -597,18 +714,116 @@ func (p *MyPlanner) homePageHandler(ctx *gin.Context) {
 	ctx.Redirect(http.StatusMovedPermanently, "/v1/")
 }
 
+// getOptimalPlan returns the best assignments of distinct places to the requested slots as regular plans
 func (p *MyPlanner) getOptimalPlan(ctx *gin.Context) {
-	req := &PlanningRequest{}
+	req := &OptimalPlanRequest{}
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c := context.WithValue(ctx, iowrappers.ContextRequestIdKey, requestid.Get(ctx))
+	resp := p.Solver.SolveHungarianOptimal(c, req)
+	if resp.Err != nil {
+		ctx.JSON(resp.ErrorCode, gin.H{"error": resp.Err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"plans": resp.Solutions})
+}
+
+// planTrip plans a multi-day trip with one plan per day and no place repeated across days.
+// Requires authentication since a cold trip request runs one full planning pass per day.
+func (p *MyPlanner) planTrip(ctx *gin.Context) {
+	userView, authErr := p.UserAuthentication(ctx, user.LevelRegular)
+	if authErr != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": authErr.GetErrorMessage()})
+		return
+	}
 
+	req := &TripPlanningRequest{}
 	if err := ctx.ShouldBindJSON(req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if plan, err := p.Solver.SolveHungarianOptimal(ctx, req); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err})
-	} else {
-		ctx.JSON(http.StatusOK, gin.H{"plan": plan})
+	c := context.WithValue(ctx, iowrappers.ContextRequestIdKey, requestid.Get(ctx))
+	c = context.WithValue(c, iowrappers.ContextRequestUserId, userView.ID)
+	resp := p.Solver.SolveTrip(c, req)
+	if resp.Err != nil {
+		ctx.JSON(resp.ErrorCode, gin.H{"error": resp.Err.Error()})
+		return
 	}
+	ctx.JSON(http.StatusOK, gin.H{"trip": resp.Trip})
+}
+
+func (p *MyPlanner) planFreeForm(ctx *gin.Context) {
+	userView, authErr := p.UserAuthentication(ctx, user.LevelRegular)
+	if authErr != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": authErr.GetErrorMessage()})
+		return
+	}
+
+	req := &FreeFormPlanningRequest{}
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c := context.WithValue(ctx, iowrappers.ContextRequestIdKey, requestid.Get(ctx))
+	c = context.WithValue(c, iowrappers.ContextRequestUserId, userView.ID)
+	resp := p.Solver.SolveFreeForm(c, req)
+	if resp.Err != nil {
+		ctx.JSON(resp.ErrorCode, gin.H{"error": resp.Err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"plan": resp.Plan})
+}
+
+// planSlotAlternatives ranks replacements for one slot of a cached plan
+func (p *MyPlanner) planSlotAlternatives(ctx *gin.Context) {
+	c, req, ok := p.bindSlotSwapRequest(ctx)
+	if !ok {
+		return
+	}
+	resp := p.Solver.SlotAlternatives(c, req)
+	if resp.Err != nil {
+		ctx.JSON(resp.ErrorCode, gin.H{"error": resp.Err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"alternatives": resp.Solutions})
+}
+
+// savePlanSlotSwap saves a cached plan with one slot replaced as a new plan
+func (p *MyPlanner) savePlanSlotSwap(ctx *gin.Context) {
+	c, req, ok := p.bindSlotSwapRequest(ctx)
+	if !ok {
+		return
+	}
+	resp := p.Solver.SaveSlotSwap(c, req)
+	if resp.Err != nil {
+		ctx.JSON(resp.ErrorCode, gin.H{"error": resp.Err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"plan": resp.Solutions[0]})
+}
+
+func (p *MyPlanner) bindSlotSwapRequest(ctx *gin.Context) (context.Context, *SlotSwapRequest, bool) {
+	userView, authErr := p.UserAuthentication(ctx, user.LevelRegular)
+	if authErr != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": authErr.GetErrorMessage()})
+		return nil, nil, false
+	}
+
+	req := &SlotSwapRequest{}
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+	req.PlanID = ctx.Param("id")
+
+	c := context.WithValue(ctx, iowrappers.ContextRequestIdKey, requestid.Get(ctx))
+	c = context.WithValue(c, iowrappers.ContextRequestUserId, userView.ID)
+	return c, req, true
 }
 
 func (p *MyPlanner) SetPlanSavedStatusForUser(ctx *gin.Context, numResults int, resp PlanningResponse, uv user.View) error {
@@ -643,7 +858,7 @@ func (p *MyPlanner) queuePlanningJobsForAllPriceLevels(req *PlanningRequest) err
 
 		job := &iowrappers.Job{
 			ID:          uuid.New().String(),
-			Name:        "Planning",
+			Name:        PlanningJobType,
 			Description: "Compute Planning Solutions",
 			Parameters:  &newReq,
 			Status:      iowrappers.JobStatusNew,
@@ -717,16 +932,29 @@ func (p *MyPlanner) getPlanningApi(ctx *gin.Context) {
 	priceLevel := ctx.DefaultQuery("price", "2")
 	logger.Debugf("Requested price range is %s", priceLevel)
 
+	travelMode, err := POI.ParseTravelMode(ctx.DefaultQuery("travel_mode", ""))
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
 	searchWithNearbyCities := ctx.DefaultQuery("nearby", "false")
 	var enableNearbyCities bool
 	if enableNearbyCities, err = strconv.ParseBool(searchWithNearbyCities); err != nil {
 		logger.Errorf("failed to parse search with nearby cities flag")
 	}
 
-	planningReq := standardRequest(date, toWeekday(date), numResultsInt, toPriceLevel(priceLevel))
+	planningReq, err := templateRequest(PlanTemplate(ctx.DefaultQuery("template", string(PlanTemplateStandard))), date, toWeekday(date), numResultsInt, toPriceLevel(priceLevel))
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
 	planningReq.WithNearbyCities = enableNearbyCities
+	planningReq.TravelMode = travelMode
 	planningReq.SearchRadius = DefaultPlaceSearchRadius
 	planningReq.PreciseLocation = preciseLocation
+	// the day starts and ends at the lodging place if the traveler gives one
+	planningReq.LodgingPlaceID = ctx.DefaultQuery("lodging", "")
 	logger.Debugf("use precise location: %t", preciseLocation)
 	if preciseLocation {
 		planningReq.Location = POI.Location{}
@@ -913,7 +1141,11 @@ func (p *MyPlanner) getPlanDetails(ctx *gin.Context) {
 	const fixedPlaceKeyPrefix = "place_details:place_ID:"
 	var placeKey string
 	destination := "Dream Place"
-	var today = time.Now()
+	if record.Destination.Timezone == "" && len(record.PlaceLocations) > 0 {
+		// plans saved before destinations kept their timezones
+		record.Destination.Timezone = iowrappers.TimezoneAt(record.PlaceLocations[0][0], record.PlaceLocations[0][1], record.Destination.Country)
+	}
+	today := record.Destination.LocalTime(time.Now())
 	if record.Destination != (POI.Location{}) {
 		c := cases.Title(language.English)
 		destination = c.String(record.Destination.City) + ", " + c.String(record.Destination.Country)
@@ -928,6 +1160,7 @@ func (p *MyPlanner) getPlanDetails(ctx *gin.Context) {
 		TravelDestination: destination,
 		TravelDate:        travelDate,
 		Score:             record.Score,
+		ScoreBreakdown:    record.ScoreBreakdown,
 		ApiKey:            p.MapsClientApiKey,
 	}
 
@@ -1011,12 +1244,29 @@ func (p *MyPlanner) userProfile(ctx *gin.Context) {
 // travel plan customization handler
 func (p *MyPlanner) customize(ctx *gin.Context) {
 	logger := iowrappers.Logger
-	// date is in the format of yyyy-mm-dd
-	date := ctx.DefaultQuery("date", "")
-	if err := validateDate(date); err != nil {
+	request, err := customPlanningRequest(ctx)
+	if err != nil {
 		ctx.String(http.StatusBadRequest, err.Error())
 		return
 	}
+
+	c := context.WithValue(ctx, iowrappers.ContextRequestIdKey, requestid.Get(ctx))
+	planningResp := p.Planning(c, request, "guest")
+	logger.Debugf("response status code is: %d", planningResp.StatusCode)
+	if planningResp.StatusCode == RequestTimeOut {
+		ctx.JSON(http.StatusRequestTimeout, nil)
+	}
+	ctx.JSON(http.StatusOK, planningResp)
+}
+
+// customPlanningRequest reads a planning request with the slots in the JSON body, on the date in the format of
+// yyyy-mm-dd and with the price level and the number of plans given by the query parameters
+func customPlanningRequest(ctx *gin.Context) (*PlanningRequest, error) {
+	logger := iowrappers.Logger
+	date := ctx.DefaultQuery("date", "")
+	if err := validateDate(date); err != nil {
+		return nil, err
+	}
 	logger.Debugf("received date in request: %s", date)
 
 	priceLevel := ctx.DefaultQuery("price", "2")
@@ -1024,8 +1274,7 @@ func (p *MyPlanner) customize(ctx *gin.Context) {
 
 	pageSize, err := strconv.Atoi(ctx.DefaultQuery("size", "5"))
 	if err != nil {
-		ctx.String(http.StatusBadRequest, err.Error())
-		return
+		return nil, err
 	}
 
 	request := &PlanningRequest{
@@ -1034,24 +1283,20 @@ func (p *MyPlanner) customize(ctx *gin.Context) {
 		PriceLevel:   toPriceLevel(priceLevel),
 	}
 
-	if err := ctx.ShouldBindJSON(&request); err != nil {
+	if err = ctx.ShouldBindJSON(&request); err != nil {
 		logger.Error(err)
-		ctx.Status(http.StatusBadRequest)
-		return
+		return nil, err
 	}
+	request.TravelDate = date
 
 	weekday := toWeekday(date)
-	for _, slot := range request.Slots {
-		slot.Weekday = weekday
-	}
-
-	c := context.WithValue(ctx, iowrappers.ContextRequestIdKey, requestid.Get(ctx))
-	planningResp := p.Planning(c, request, "guest")
-	logger.Debugf("response status code is: %d", planningResp.StatusCode)
-	if planningResp.StatusCode == RequestTimeOut {
-		ctx.JSON(http.StatusRequestTimeout, nil)
+	for idx := range request.Slots {
+		if err = validateSlotRequest(request.Slots[idx]); err != nil {
+			return nil, err
+		}
+		request.Slots[idx].Weekday = weekday
 	}
-	ctx.JSON(http.StatusOK, planningResp)
+	return request, nil
 }
 
 func (p *MyPlanner) handleLogin(ctx *gin.Context) {
@@ -1231,7 +1476,7 @@ type nearbyPlacesRequest struct {
 	Limit int `json:"limit"`
 	// optional RFC3339 timestamp representing local time at the searched location
 	// (e.g. "2026-07-21T13:00:00-07:00"); places whose hours mark them closed on that
-	// weekday are excluded. Defaults to server time when empty.
+	// weekday at the location are excluded. Defaults to the current time at the location when empty.
 	LocalTime string `json:"localTime"`
 }
 
@@ -1275,14 +1520,13 @@ func (p *MyPlanner) getNearbyPlaces(ctx *gin.Context) {
 	if limit > 40 {
 		limit = 40
 	}
-	day := POI.WeekdayFromTime(time.Now().Weekday())
+	localTime := time.Now()
 	if req.LocalTime != "" {
-		localTime, parseErr := time.Parse(time.RFC3339, req.LocalTime)
-		if parseErr != nil {
+		var parseErr error
+		if localTime, parseErr = time.Parse(time.RFC3339, req.LocalTime); parseErr != nil {
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": "localTime must be an RFC3339 timestamp"})
 			return
 		}
-		day = POI.WeekdayFromTime(localTime.Weekday())
 	}
 
 	requestId := requestid.Get(ctx)
@@ -1298,6 +1542,9 @@ func (p *MyPlanner) getNearbyPlaces(ctx *gin.Context) {
 	location.City = geoQuery.City
 	location.AdminAreaLevelOne = geoQuery.AdminAreaLevelOne
 	location.Country = geoQuery.Country
+	iowrappers.SetTimezone(&location)
+	// the day at the searched location, which differs from the day of the server or the user near midnight
+	day := POI.WeekdayFromTime(location.LocalTime(localTime).Weekday())
 
 	results := make([]nearbyPlacesBrandResult, len(req.Brands))
 	wg := &sync.WaitGroup{}
@@ -1345,7 +1592,7 @@ type nearbyPlacesByCategoryRequest struct {
 	Limit int `json:"limit"`
 	// optional RFC3339 timestamp representing local time at the searched location
 	// (e.g. "2026-07-21T13:00:00-07:00"); places whose hours mark them closed on that
-	// weekday are excluded. Defaults to server time when empty.
+	// weekday at the location are excluded. Defaults to the current time at the location when empty.
 	LocalTime string `json:"localTime"`
 }
 
@@ -1402,14 +1649,13 @@ func (p *MyPlanner) getNearbyPlacesByCategory(ctx *gin.Context) {
 	if limit > 40 {
 		limit = 40
 	}
-	day := POI.WeekdayFromTime(time.Now().Weekday())
+	localTime := time.Now()
 	if req.LocalTime != "" {
-		localTime, parseErr := time.Parse(time.RFC3339, req.LocalTime)
-		if parseErr != nil {
+		var parseErr error
+		if localTime, parseErr = time.Parse(time.RFC3339, req.LocalTime); parseErr != nil {
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": "localTime must be an RFC3339 timestamp"})
 			return
 		}
-		day = POI.WeekdayFromTime(localTime.Weekday())
 	}
 
 	requestId := requestid.Get(ctx)
@@ -1425,6 +1671,9 @@ func (p *MyPlanner) getNearbyPlacesByCategory(ctx *gin.Context) {
 	location.City = geoQuery.City
 	location.AdminAreaLevelOne = geoQuery.AdminAreaLevelOne
 	location.Country = geoQuery.Country
+	iowrappers.SetTimezone(&location)
+	// the day at the searched location, which differs from the day of the server or the user near midnight
+	day := POI.WeekdayFromTime(location.LocalTime(localTime).Weekday())
 
 	results := make([]nearbyPlacesByCategoryResult, len(categories))
 	wg := &sync.WaitGroup{}
@@ -1836,8 +2085,13 @@ func (p *MyPlanner) SetupRouter(serverPort string) *http.Server {
 		v1.GET("/log-in", p.login)
 		v1.GET("/sign-up", p.signup)
 		v1.GET("/plans/:id", p.getPlanDetails)
+		v1.POST("/plans/:id/alternatives", p.planSlotAlternatives)
+		v1.POST("/plans/:id/swap", p.savePlanSlotSwap)
 		v1.GET("/cities", p.getCitiesHandler)
 		v1.POST("/customize", p.customize)
+		v1.POST("/jobs", p.submitPlanningJob)
+		v1.GET("/jobs/:id", p.getJob)
+		v1.DELETE("/jobs/:id", p.cancelJob)
 		v1.GET("/template", p.planTemplate)
 		v1.GET("/login-google", p.handleLogin)
 		v1.GET("/callback-google", p.oauthCallback)
@@ -1852,6 +2106,8 @@ func (p *MyPlanner) SetupRouter(serverPort string) *http.Server {
 		v1.POST("/place-search", p.searchPlacesByText)
 		v1.POST("/place-search/confirm", p.confirmSearchedPlace)
 		v1.POST("/optimal-plan", p.getOptimalPlan)
+		v1.POST("/trips", p.planTrip)
+		v1.POST("/free-form-plans", p.planFreeForm)
 		v1.POST("/create-token", p.createNewPAT)
 		v1.DELETE("/revoke-token", p.RevokePAT)
 		v1.GET("/list-tokens", p.ListPATs)
@@ -1879,6 +2135,9 @@ func (p *MyPlanner) SetupRouter(serverPort string) *http.Server {
 			users.DELETE("/:username/plan/:id", p.userPlanDeleteHandler)
 			users.GET("/plan/:id", p.getUserSavedPlanDetails)
 			users.POST("/:username/feedback", p.userFeedbackHandler)
+			users.GET("/:username/blocked-places", p.userBlockedPlacesGetHandler)
+			users.POST("/:username/blocked-places", p.userBlockedPlacesPostHandler)
+			users.DELETE("/:username/blocked-places/:id", p.userBlockedPlaceDeleteHandler)
 		}
 
 		places := v1.Group("/places")
@@ -1889,6 +2148,13 @@ func (p *MyPlanner) SetupRouter(serverPort string) *http.Server {
 		admins := v1.Group("/admins")
 		{
 			admins.POST("/announce", p.announce)
+			admins.GET("/closures", p.closuresGetHandler)
+			admins.POST("/closures", p.closuresPostHandler)
+			admins.DELETE("/closures", p.closureDeleteHandler)
+			admins.GET("/dead-letters", p.deadLettersGetHandler)
+			admins.GET("/dead-letters/:id", p.deadLetterGetHandler)
+			admins.POST("/dead-letters/:id/requeue", p.deadLetterRequeueHandler)
+			admins.GET("/jobs/stats", p.jobQueueStatsHandler)
 		}
 	}
 
@@ -1,12 +1,17 @@
 package planner
 
 import (
+	"context"
+	"html/template"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/weihesdlegend/Vacation-planner/POI"
 	"github.com/weihesdlegend/Vacation-planner/iowrappers"
 	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/test/redis_client_mocks"
 )
 
 func TestCopyRequests(t *testing.T) {
@@ -29,10 +34,11 @@ func TestCopyRequests(t *testing.T) {
 						{
 							Weekday: POI.DateWednesday,
 							TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{
-								Start: 10,
-								End:   13,
+								Start: POI.NewClockTime(10, 0),
+								End:   POI.NewClockTime(13, 0),
 							}},
-							Category: POI.PlaceCategoryVisit,
+							Category:      POI.PlaceCategoryVisit,
+							LocationTypes: []POI.LocationType{POI.LocationTypeMuseum},
 						},
 					},
 					TravelDate:       "1-21-2050",
@@ -47,10 +53,11 @@ func TestCopyRequests(t *testing.T) {
 						{
 							Weekday: POI.DateWednesday,
 							TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{
-								Start: 10,
-								End:   13,
+								Start: POI.NewClockTime(10, 0),
+								End:   POI.NewClockTime(13, 0),
 							}},
-							Category: POI.PlaceCategoryVisit,
+							Category:      POI.PlaceCategoryVisit,
+							LocationTypes: []POI.LocationType{POI.LocationTypeMuseum},
 						},
 					},
 					TravelDate:       "1-21-2050",
@@ -62,10 +69,11 @@ func TestCopyRequests(t *testing.T) {
 						{
 							Weekday: POI.DateWednesday,
 							TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{
-								Start: 10,
-								End:   13,
+								Start: POI.NewClockTime(10, 0),
+								End:   POI.NewClockTime(13, 0),
 							}},
-							Category: POI.PlaceCategoryVisit,
+							Category:      POI.PlaceCategoryVisit,
+							LocationTypes: []POI.LocationType{POI.LocationTypeMuseum},
 						},
 					},
 					TravelDate:       "1-21-2050",
@@ -123,3 +131,98 @@ func Test_planDetails(t *testing.T) {
 		})
 	}
 }
+
+func TestResultHTMLTemplate_shouldRenderClockTimes(t *testing.T) {
+	tmpl := template.Must(template.ParseFiles("../assets/templates/search_results_layout_template.html"))
+	resp := PlanningResponse{
+		TravelDestination: "San Francisco",
+		TravelPlans: []TravelPlan{{
+			ID: "plan-1",
+			Places: []TimeSectionPlace{{
+				PlaceName: "Exploratorium",
+				StartTime: POI.NewClockTime(10, 0),
+				EndTime:   POI.NewClockTime(12, 30),
+			}},
+		}},
+		TripDetailsURL: []string{"/v1/plans/plan-1"},
+	}
+
+	var html strings.Builder
+	if err := tmpl.Execute(&html, resp); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), ">10 - 12:30<") {
+		t.Errorf("expected the slot 10 - 12:30 in the rendered plan, got %s", html.String())
+	}
+}
+
+// newNearbyCitiesPlanner returns a planner with a cached plan in Fremont and a better one in nearby Union City
+func newNearbyCitiesPlanner(t *testing.T) *MyPlanner {
+	ctx := context.Background()
+	redisURL, _ := url.Parse("redis://" + redis_client_mocks.RedisMockSvr.Addr())
+	p := &MyPlanner{
+		RedisClient:    redis_client_mocks.RedisClient,
+		PlanningEvents: make(chan iowrappers.PlanningEvent, 10),
+		Dispatcher:     NewDispatcher(nil, redis_client_mocks.RedisClient),
+	}
+	p.Solver.Init(iowrappers.CreatePoiSearcher("fake-api-key", redisURL), 1, 3)
+
+	cities := []iowrappers.City{
+		{ID: "5350734", GeonameID: 5350734, Name: "Fremont", Latitude: 37.54827, Longitude: -121.9886, Population: 101900, AdminArea1: "CA", Country: "United States"},
+		{ID: "5267812", GeonameID: 5267812, Name: "Union City", Latitude: 37.5934, Longitude: -122.0439, Population: 80700, AdminArea1: "CA", Country: "United States"},
+	}
+	if err := p.RedisClient.AddCities(ctx, cities); err != nil {
+		t.Fatal(err)
+	}
+	for idx, city := range cities {
+		query := iowrappers.GeocodeQuery{City: city.Name, AdminAreaLevelOne: city.AdminArea1, Country: city.Country}
+		p.RedisClient.SetGeocode(ctx, query, city.Latitude, city.Longitude, query)
+
+		req := nearbyCitiesRequest()
+		req.Location = toLocation(city)
+		placeID := strings.ReplaceAll(strings.ToLower(city.Name), " ", "-") + "-museum"
+		solution := PlanningSolution{
+			ID:              placeID + "-plan",
+			PlaceIDS:        []string{placeID},
+			PlaceNames:      []string{city.Name + " Museum"},
+			PlaceLocations:  [][2]float64{{city.Latitude, city.Longitude}},
+			PlaceAddresses:  []string{city.Name + ", CA"},
+			PlaceURLs:       []string{""},
+			PlaceCategories: []POI.PlaceCategory{POI.PlaceCategoryVisit},
+			Score:           float64(idx + 1),
+		}
+		if err := saveSolutions(ctx, p.RedisClient, req, []PlanningSolution{solution}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p
+}
+
+// nearbyCitiesRequest asks for more plans in Fremont than the plans cached there
+func nearbyCitiesRequest() *PlanningRequest {
+	return &PlanningRequest{
+		Location: POI.Location{City: "Fremont", AdminAreaLevelOne: "CA", Country: "United States"},
+		Slots: []SlotRequest{{
+			Weekday:  POI.DateTuesday,
+			TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(12, 0)}},
+			Category: POI.PlaceCategoryVisit,
+		}},
+		TravelDate:       "2026-12-01",
+		NumPlans:         2,
+		SearchRadius:     DefaultPlaceSearchRadius,
+		WithNearbyCities: true,
+	}
+}
+
+func TestPlanning_shouldReturnPlansOfNearbyCities(t *testing.T) {
+	p := newNearbyCitiesPlanner(t)
+
+	resp := p.Planning(context.Background(), nearbyCitiesRequest(), "guest")
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	planIDs := MapSlice(resp.TravelPlans, func(plan TravelPlan) string { return plan.ID })
+	if !reflect.DeepEqual(planIDs, []string{"union-city-museum-plan", "fremont-museum-plan"}) {
+		t.Errorf("expected the plans of Fremont and Union City, got %v", planIDs)
+	}
+}
@@ -2,18 +2,44 @@ package planner
 
 import (
 	"sync"
+	"time"
 
 	"github.com/weihesdlegend/Vacation-planner/iowrappers"
 )
 
+// JobQueue queues jobs for the workers of the Dispatcher
+type JobQueue interface {
+	// Enqueue adds a job to the queue, it returns false once the queue is closed
+	Enqueue(job *iowrappers.Job) bool
+	// Dequeue blocks until a job is available, prioritizing high > normal > low with priorities raised by aging
+	// Returns nil when the queue is closed and empty
+	Dequeue() *iowrappers.Job
+	// Ack tells the queue that a dequeued job was handled and will not be handed to another worker
+	Ack(job *iowrappers.Job)
+	Close()
+	// Len returns the number of jobs waiting for workers
+	Len() int
+	// Stats returns the number of jobs waiting in each priority
+	Stats() (high, normal, low int)
+	// WaitStats returns the depth and the wait times of each priority
+	WaitStats() JobQueueStats
+}
+
 // PriorityJobQueue manages jobs across three priority levels
-// Workers always pull from high-priority queue first, then normal, then low
+// Workers pull from high-priority queue first, then normal, then low, unless a job waited long enough to be promoted
 type PriorityJobQueue struct {
 	high   chan *iowrappers.Job
 	normal chan *iowrappers.Job
 	low    chan *iowrappers.Job
 	closed bool
 	mu     sync.RWMutex
+
+	// heads are the next jobs of each priority taken off their channels, so that their wait times can be compared
+	headsMu sync.Mutex
+	heads   map[iowrappers.JobPriority]*iowrappers.Job
+	// ready wakes a worker blocked on the channels when jobs are left in heads
+	ready chan struct{}
+	waits jobWaitRecorder
 }
 
 // NewPriorityJobQueue creates a new priority-based job queue
@@ -24,6 +50,21 @@ func NewPriorityJobQueue(bufferSize int) *PriorityJobQueue {
 		normal: make(chan *iowrappers.Job, bufferSize),
 		low:    make(chan *iowrappers.Job, bufferSize),
 		closed: false,
+		heads:  make(map[iowrappers.JobPriority]*iowrappers.Job),
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// channel returns the channel of a priority, unknown priorities are queued as normal ones
+func (pq *PriorityJobQueue) channel(priority iowrappers.JobPriority) (iowrappers.JobPriority, chan *iowrappers.Job) {
+	switch priority {
+	case iowrappers.JobPriorityHigh:
+		return priority, pq.high
+	case iowrappers.JobPriorityLow:
+		return priority, pq.low
+	default:
+		// Default to normal priority if not specified
+		return iowrappers.JobPriorityNormal, pq.normal
 	}
 }
 
@@ -36,90 +77,97 @@ func (pq *PriorityJobQueue) Enqueue(job *iowrappers.Job) bool {
 		return false
 	}
 
-	switch job.Priority {
-	case iowrappers.JobPriorityHigh:
-		pq.high <- job
-	case iowrappers.JobPriorityNormal:
-		pq.normal <- job
-	case iowrappers.JobPriorityLow:
-		pq.low <- job
-	default:
-		// Default to normal priority if not specified
-		pq.normal <- job
-	}
-
+	job.EnqueuedAt = time.Now()
+	_, ch := pq.channel(job.Priority)
+	ch <- job
 	return true
 }
 
-// Dequeue retrieves the next job, prioritizing high > normal > low
+// Dequeue retrieves the next job, prioritizing high > normal > low with priorities raised by aging
 // Returns nil when all queues are closed and empty
 func (pq *PriorityJobQueue) Dequeue() *iowrappers.Job {
 	for {
-		// Try high priority first (non-blocking)
-		select {
-		case job, ok := <-pq.high:
-			if ok {
-				return job
-			}
-			// High queue closed, continue to check others
-		default:
-			// No high priority jobs available
+		if job := pq.dequeueHead(); job != nil {
+			return job
+		}
+		if pq.isClosed() {
+			// dequeueHead drained the closed channels
+			return nil
 		}
 
-		// Try normal priority (non-blocking)
+		// If we get here, all queues are empty
+		// Block on all queues simultaneously
+		var job *iowrappers.Job
+		var ok bool
 		select {
-		case job, ok := <-pq.normal:
-			if ok {
-				return job
-			}
-			// Normal queue closed, continue to check low
-		default:
-			// No normal priority jobs available
+		case job, ok = <-pq.high:
+		case job, ok = <-pq.normal:
+		case job, ok = <-pq.low:
+		case <-pq.ready:
 		}
+		if ok {
+			priority, _ := pq.channel(job.Priority)
+			pq.waits.record(queuedJob{priority: priority, enqueuedAt: job.EnqueuedAt}, false, time.Now())
+			return job
+		}
+	}
+}
 
-		// Try low priority (non-blocking)
-		select {
-		case job, ok := <-pq.low:
-			if ok {
-				return job
-			}
-			// Low queue closed, continue to final check
-		default:
-			// No low priority jobs available
+// dequeueHead takes the next job of each priority off its channel and returns the job to dequeue among them
+func (pq *PriorityJobQueue) dequeueHead() *iowrappers.Job {
+	pq.headsMu.Lock()
+	defer pq.headsMu.Unlock()
+
+	pq.fillHeads()
+	candidates := make([]*queuedJob, len(jobPriorities))
+	for idx, priority := range jobPriorities {
+		if job := pq.heads[priority]; job != nil {
+			candidates[idx] = &queuedJob{priority: priority, enqueuedAt: job.EnqueuedAt}
 		}
+	}
+	now := time.Now()
+	next, promoted := nextJobIndex(candidates, now)
+	if next < 0 {
+		return nil
+	}
+	job := pq.heads[jobPriorities[next]]
+	delete(pq.heads, jobPriorities[next])
+	pq.waits.record(*candidates[next], promoted, now)
+	pq.signalHeads()
+	return job
+}
 
-		// If we get here, all queues are empty
-		// Block on all queues simultaneously, respecting priority
+// signalHeads wakes a blocked worker if jobs are left in heads, headsMu must be held
+func (pq *PriorityJobQueue) signalHeads() {
+	if len(pq.heads) == 0 {
+		return
+	}
+	select {
+	case pq.ready <- struct{}{}:
+	default:
+	}
+}
+
+// fillHeads takes the next job off the channel of each priority without a head, headsMu must be held
+func (pq *PriorityJobQueue) fillHeads() {
+	for _, priority := range jobPriorities {
+		if pq.heads[priority] != nil {
+			continue
+		}
+		_, ch := pq.channel(priority)
 		select {
-		case job, ok := <-pq.high:
-			if !ok {
-				// High queue closed, check if all closed
-				if pq.allClosed() {
-					return nil
-				}
-				continue
-			}
-			return job
-		case job, ok := <-pq.normal:
-			if !ok {
-				if pq.allClosed() {
-					return nil
-				}
-				continue
-			}
-			return job
-		case job, ok := <-pq.low:
-			if !ok {
-				if pq.allClosed() {
-					return nil
-				}
-				continue
+		case job, ok := <-ch:
+			if ok {
+				pq.heads[priority] = job
 			}
-			return job
+		default:
 		}
 	}
 }
 
+// Ack is a no-op, jobs are removed from the in-memory queue when they are dequeued
+func (pq *PriorityJobQueue) Ack(*iowrappers.Job) {}
+
 // Close closes all priority queues
 func (pq *PriorityJobQueue) Close() {
 	pq.mu.Lock()
@@ -133,43 +181,50 @@ func (pq *PriorityJobQueue) Close() {
 	}
 }
 
-// allClosed checks if all channels are closed
-func (pq *PriorityJobQueue) allClosed() bool {
-	// Check if channels are closed by attempting non-blocking receive
-	select {
-	case _, ok := <-pq.high:
-		if ok {
-			return false
-		}
-	default:
-	}
-
-	select {
-	case _, ok := <-pq.normal:
-		if ok {
-			return false
-		}
-	default:
-	}
-
-	select {
-	case _, ok := <-pq.low:
-		if ok {
-			return false
-		}
-	default:
-	}
-
-	return true
+func (pq *PriorityJobQueue) isClosed() bool {
+	pq.mu.RLock()
+	defer pq.mu.RUnlock()
+	return pq.closed
 }
 
 // Len returns the approximate total number of jobs across all priorities
 // Note: This is a snapshot and may not be accurate in concurrent scenarios
 func (pq *PriorityJobQueue) Len() int {
-	return len(pq.high) + len(pq.normal) + len(pq.low)
+	high, normal, low := pq.Stats()
+	return high + normal + low
 }
 
 // Stats returns the number of jobs in each priority queue
 func (pq *PriorityJobQueue) Stats() (high, normal, low int) {
-	return len(pq.high), len(pq.normal), len(pq.low)
+	pq.headsMu.Lock()
+	defer pq.headsMu.Unlock()
+	return len(pq.high) + pq.headCount(iowrappers.JobPriorityHigh),
+		len(pq.normal) + pq.headCount(iowrappers.JobPriorityNormal),
+		len(pq.low) + pq.headCount(iowrappers.JobPriorityLow)
+}
+
+func (pq *PriorityJobQueue) headCount(priority iowrappers.JobPriority) int {
+	if pq.heads[priority] != nil {
+		return 1
+	}
+	return 0
+}
+
+// WaitStats returns the depth, the wait time of the oldest job and the wait times of the dequeued jobs of each priority
+func (pq *PriorityJobQueue) WaitStats() JobQueueStats {
+	pq.headsMu.Lock()
+	pq.fillHeads()
+	pq.signalHeads()
+	depths := make(map[iowrappers.JobPriority]int)
+	oldest := make(map[iowrappers.JobPriority]time.Time)
+	for _, priority := range jobPriorities {
+		_, ch := pq.channel(priority)
+		depths[priority] = len(ch) + pq.headCount(priority)
+		// the head of a priority is its oldest job
+		if job := pq.heads[priority]; job != nil {
+			oldest[priority] = job.EnqueuedAt
+		}
+	}
+	pq.headsMu.Unlock()
+	return pq.waits.snapshot(depths, oldest, time.Now())
 }
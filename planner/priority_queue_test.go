@@ -314,3 +314,81 @@ func TestPriorityJobQueue_DefaultPriority(t *testing.T) {
 		t.Errorf("Expected job ID %s, got %s", job.ID, dequeuedJob.ID)
 	}
 }
+
+func TestPriorityJobQueue_AgingPromotion(t *testing.T) {
+	defaultInterval := JobAgingInterval
+	JobAgingInterval = 20 * time.Millisecond
+	defer func() { JobAgingInterval = defaultInterval }()
+
+	pq := NewPriorityJobQueue(10)
+	defer pq.Close()
+
+	pq.Enqueue(&iowrappers.Job{ID: "low-1", Priority: iowrappers.JobPriorityLow})
+	// the low priority job waits for two levels of promotion
+	time.Sleep(2*JobAgingInterval + 10*time.Millisecond)
+	pq.Enqueue(&iowrappers.Job{ID: "high-1", Priority: iowrappers.JobPriorityHigh})
+
+	for _, expected := range []string{"low-1", "high-1"} {
+		if job := pq.Dequeue(); job == nil || job.ID != expected {
+			t.Fatalf("Expected job %s, got %+v", expected, job)
+		}
+	}
+	if stats := pq.WaitStats(); stats.Promoted != 1 {
+		t.Errorf("Expected 1 promoted job, got %d", stats.Promoted)
+	}
+}
+
+func TestPriorityJobQueue_WaitStats(t *testing.T) {
+	pq := NewPriorityJobQueue(10)
+	defer pq.Close()
+
+	pq.Enqueue(&iowrappers.Job{ID: "normal-1", Priority: iowrappers.JobPriorityNormal})
+	pq.Enqueue(&iowrappers.Job{ID: "normal-2", Priority: iowrappers.JobPriorityNormal})
+	pq.Enqueue(&iowrappers.Job{ID: "low-1", Priority: iowrappers.JobPriorityLow})
+	time.Sleep(20 * time.Millisecond)
+
+	stats := pq.WaitStats()
+	if stats.Normal.Depth != 2 || stats.Low.Depth != 1 || stats.High.Depth != 0 {
+		t.Errorf("Expected depths 0, 2 and 1, got %+v", stats)
+	}
+	if stats.Normal.OldestWaitSeconds < 0.02 || stats.High.OldestWaitSeconds != 0 {
+		t.Errorf("Expected the oldest normal job to wait, got %+v", stats)
+	}
+
+	// jobs taken off their channels by WaitStats are still dequeued by blocked workers
+	if job := pq.Dequeue(); job == nil || job.ID != "normal-1" {
+		t.Fatalf("Expected job normal-1, got %+v", job)
+	}
+	stats = pq.WaitStats()
+	if stats.Normal.Depth != 1 || stats.Normal.Dequeued != 1 || stats.Normal.MaxWaitSeconds < 0.02 {
+		t.Errorf("Expected a dequeued normal job, got %+v", stats.Normal)
+	}
+	if pq.Len() != 2 {
+		t.Errorf("Expected 2 jobs left, got %d", pq.Len())
+	}
+}
+
+func TestPriorityJobQueue_BlockedDequeueAfterWaitStats(t *testing.T) {
+	pq := NewPriorityJobQueue(10)
+	defer pq.Close()
+
+	dequeued := make(chan *iowrappers.Job)
+	go func() {
+		dequeued <- pq.Dequeue()
+	}()
+	time.Sleep(20 * time.Millisecond)
+	// WaitStats may take the job off its channel before the blocked worker receives it
+	pq.headsMu.Lock()
+	pq.heads[iowrappers.JobPriorityLow] = &iowrappers.Job{ID: "low-1", Priority: iowrappers.JobPriorityLow}
+	pq.signalHeads()
+	pq.headsMu.Unlock()
+
+	select {
+	case job := <-dequeued:
+		if job == nil || job.ID != "low-1" {
+			t.Fatalf("Expected job low-1, got %+v", job)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the job")
+	}
+}
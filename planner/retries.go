@@ -0,0 +1,65 @@
+package planner
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy sets how often and how late a failed job is run again
+type RetryPolicy struct {
+	// MaxAttempts counts the first run, a policy with one attempt never retries
+	MaxAttempts int
+	// the backoff before the n-th retry is InitialBackoff * Multiplier^(n-1), capped at MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter randomizes a backoff by up to this fraction, e.g. 0.2 waits 80% to 120% of the backoff, so that the jobs
+	// failing together are not retried together
+	Jitter float64
+}
+
+// DefaultRetryPolicy applies to the job types without a policy
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 1}
+
+// jobRetryPolicies are the retry policies of the job types
+var jobRetryPolicies = map[string]RetryPolicy{
+	// background jobs warming the cache can wait for a Maps API outage to end
+	PlanningJobType: {MaxAttempts: 4, InitialBackoff: 10 * time.Second, MaxBackoff: 2 * time.Minute, Multiplier: 3, Jitter: 0.2},
+	// users poll for the plans of async jobs, so the retries are quick
+	AsyncPlanningJobType: {MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2, Jitter: 0.2},
+}
+
+// retryPolicyOf returns the retry policy of a job type
+func retryPolicyOf(jobType string) RetryPolicy {
+	if policy, ok := jobRetryPolicies[jobType]; ok {
+		return policy
+	}
+	return DefaultRetryPolicy
+}
+
+// Backoff returns the wait before retrying a job that failed its attempt-th run
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(max(p.Multiplier, 1), float64(max(attempt-1, 0)))
+	if p.MaxBackoff > 0 {
+		backoff = min(backoff, float64(p.MaxBackoff))
+	}
+	backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	return time.Duration(backoff)
+}
+
+// nonRetryableJobError fails a job whatever its retry policy, e.g. a job with an invalid request
+type nonRetryableJobError struct {
+	error
+}
+
+func (e nonRetryableJobError) Unwrap() error {
+	return e.error
+}
+
+// isRetryable reports whether a job failing with err can be retried
+func isRetryable(err error) bool {
+	var nonRetryable nonRetryableJobError
+	return !errors.As(err, &nonRetryable)
+}
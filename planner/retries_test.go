@@ -0,0 +1,131 @@
+package planner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/test/redis_client_mocks"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2, Jitter: 0.2}
+	tests := []struct {
+		attempt int
+		backoff time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+	}
+	for _, test := range tests {
+		for range 20 {
+			backoff := policy.Backoff(test.attempt)
+			if backoff < test.backoff*8/10 || backoff > test.backoff*12/10 {
+				t.Errorf("attempt %d: expected a backoff within 20%% of %s, got %s", test.attempt, test.backoff, backoff)
+			}
+		}
+	}
+}
+
+// flakyJobHandler fails the first runs of each job
+type flakyJobHandler struct {
+	mu       sync.Mutex
+	failures map[string]int
+	runs     map[string]int
+	done     chan string
+}
+
+func (h *flakyJobHandler) Execute(_ context.Context, job *iowrappers.Job) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.runs[job.ID]++
+	if job.Attempts != h.runs[job.ID] {
+		return nonRetryableJobError{errors.New("unexpected attempt count")}
+	}
+	if h.runs[job.ID] <= h.failures[job.ID] {
+		if h.runs[job.ID] == 3 {
+			h.done <- job.ID
+		}
+		return errors.New("maps API unavailable")
+	}
+	h.done <- job.ID
+	return nil
+}
+
+func (h *flakyJobHandler) JobType() string {
+	return "Flaky"
+}
+
+func TestGenericWorker_shouldRetryFailedJobsAndDeadLetterExhaustedJobs(t *testing.T) {
+	jobRetryPolicies["Flaky"] = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2, Jitter: 0.2}
+	defer delete(jobRetryPolicies, "Flaky")
+
+	queue := NewPriorityJobQueue(10)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	worker := NewGenericWorker(0, queue, redis_client_mocks.RedisClient, wg)
+	handler := &flakyJobHandler{
+		failures: map[string]int{"flaky-recovering": 2, "flaky-exhausted": 3},
+		runs:     make(map[string]int),
+		done:     make(chan string, 2),
+	}
+	worker.RegisterHandler(handler)
+	worker.Run(context.Background())
+
+	queue.Enqueue(&iowrappers.Job{ID: "flaky-recovering", Name: "Flaky", Priority: iowrappers.JobPriorityNormal})
+	queue.Enqueue(&iowrappers.Job{ID: "flaky-exhausted", Name: "Flaky", Priority: iowrappers.JobPriorityNormal})
+	for range 2 {
+		select {
+		case <-handler.done:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the jobs")
+		}
+	}
+	queue.Close()
+	wg.Wait()
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if handler.runs["flaky-recovering"] != 3 || handler.runs["flaky-exhausted"] != 3 {
+		t.Errorf("expected 3 runs of each job, got %v", handler.runs)
+	}
+	ctx := context.Background()
+	deadLetter, err := redis_client_mocks.RedisClient.GetDeadLetterJob(ctx, "flaky-exhausted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deadLetter.Job.Attempts != 3 || deadLetter.Error != "maps API unavailable" {
+		t.Errorf("expected the exhausted job with its attempts and error, got %+v", deadLetter)
+	}
+	if _, err = redis_client_mocks.RedisClient.GetDeadLetterJob(ctx, "flaky-recovering"); !errors.Is(err, iowrappers.ErrJobNotFound) {
+		t.Errorf("expected the recovered job not to be dead-lettered, got %v", err)
+	}
+}
+
+func TestGenericWorker_shouldDeadLetterFailedJobsWithoutRetries(t *testing.T) {
+	queue := NewPriorityJobQueue(10)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	worker := NewGenericWorker(0, queue, redis_client_mocks.RedisClient, wg)
+	handler := &flakyJobHandler{failures: map[string]int{"flaky-single-attempt": 1}, runs: make(map[string]int), done: make(chan string, 1)}
+	worker.RegisterHandler(handler)
+	worker.Run(context.Background())
+
+	// without a retry policy of its own, the job has the single attempt of DefaultRetryPolicy
+	queue.Enqueue(&iowrappers.Job{ID: "flaky-single-attempt", Name: "Flaky", Priority: iowrappers.JobPriorityNormal})
+	queue.Close()
+	wg.Wait()
+
+	deadLetter, err := redis_client_mocks.RedisClient.GetDeadLetterJob(context.Background(), "flaky-single-attempt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deadLetter.Job.Attempts != 1 || deadLetter.Error != "maps API unavailable" {
+		t.Errorf("expected the failed job with its attempt and error, got %+v", deadLetter)
+	}
+}
@@ -11,9 +11,10 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
-	hungarianAlgorithm "github.com/oddg/hungarian-algorithm"
+	"github.com/redis/go-redis/v9"
 	log "github.com/sirupsen/logrus"
 	"github.com/weihesdlegend/Vacation-planner/POI"
 	"github.com/weihesdlegend/Vacation-planner/iowrappers"
@@ -28,9 +29,11 @@ const (
 	DefaultPlaceSearchRadius              = 20000 // default to 20km (~12.43 miles)
 	MaxSolutionsToSaveCount               = 100
 	MaxPlacesPerSlot                      = 30
+	MaxExclusionsPerSlot                  = 3
 	CategorizedPlaceIterInitFailureErrMsg = "categorized places iterator init failure"
 	ErrMsgMismatchIterAndPlace            = "mismatch in iterator status vector length"
 	ErrMsgRepeatedPlaceInSameTrip         = "repeated places in the same trip"
+	ErrMsgTravelTimeExceedsSlots          = "travel time between places does not fit the time slots"
 )
 
 type PlanningSolution struct {
@@ -43,6 +46,12 @@ type PlanningSolution struct {
 	PlaceCategories []POI.PlaceCategory `json:"place_categories"`
 	Score           float64             `json:"score"`
 	PlanSpec        string              `json:"plan_spec"`
+	PlaceTypes      []POI.LocationType  `json:"place_types,omitempty"`
+	Legs            []POI.TravelLeg     `json:"legs"`                // travel from each place to the next one
+	StartLeg        *POI.TravelLeg      `json:"start_leg,omitempty"` // travel from the start location to the first place
+	EndLeg          *POI.TravelLeg      `json:"end_leg,omitempty"`   // travel from the last place to the end location
+	ScoreBreakdown  *POI.ScoreBreakdown `json:"score_breakdown,omitempty"`
+	Exclusions      []POI.Exclusion     `json:"exclusions,omitempty"` // places left out of the plan by closures on the travel date
 }
 
 func (ps PlanningSolution) Key() float64 {
@@ -55,23 +64,20 @@ type Solver struct {
 	concreteMatchers       []matching.Matcher
 	placeDedupeCountLimit  int
 	nearbyCitiesCountLimit int
+	searchStrategy         SearchStrategy
+	scorer                 matching.Scorer
+	diversityLambda        float64
 }
 
 const (
 	ValidSolutionFound     = 200
+	BadRequest             = 400 // request parameters other than the location are invalid, e.g. an unknown travel mode
 	InvalidRequestLocation = 400
 	NoValidSolution        = 404
 	RequestTimeOut         = 408
 	InternalError          = 500
 )
 
-type PlacePlanningDetails struct {
-	Name     string            `json:"name"`
-	URL      string            `json:"url"`
-	Category string            `json:"category"`
-	TimeSlot matching.TimeSlot `json:"time_slot"`
-}
-
 // MultiPlanningReq can be used to represent a multi-day planning request for a single location or a group of requests for different locations
 type MultiPlanningReq struct {
 	requests []*PlanningRequest
@@ -87,7 +93,20 @@ type PlanningRequest struct {
 	PriceLevel       POI.PriceLevel `json:"price_level"`
 	PreciseLocation  bool
 	WithNearbyCities bool
-	spec             string
+	TravelMode       POI.TravelMode `json:"travel_mode"`
+	// StartLocation and EndLocation are where the traveler starts and finishes the day, e.g. a hotel.
+	// LodgingPlaceID sets the ones not given to the location of a Lodging place.
+	StartLocation   *POI.Location `json:"start_location,omitempty"`
+	EndLocation     *POI.Location `json:"end_location,omitempty"`
+	LodgingPlaceID  string        `json:"lodging_place_id,omitempty"`
+	spec            string
+	blockedPlaceIDs []string        // places blocked by the user making the request
+	closures        POI.Closures    // closures at the destination on the travel date
+	exclusions      []POI.Exclusion // places fitting the slots that closures left out
+	refreshWithin   time.Duration   // cached plans expiring within it are computed again, e.g. to keep the cache warm
+	// candidatePool returns the candidates of the diversity selection instead of the selected plans, for a selection
+	// among the plans of several requests
+	candidatePool bool
 }
 
 type PlanningResp struct {
@@ -101,6 +120,12 @@ type SlotRequest struct {
 	Weekday  POI.Weekday       `json:"weekday"`
 	TimeSlot matching.TimeSlot `json:"time_slot"`
 	Category POI.PlaceCategory `json:"category"`
+	// PinnedPlaceID fixes the place of the slot, e.g. a booked restaurant, and the solver plans the other slots around it
+	PinnedPlaceID string `json:"pinned_place_id,omitempty"`
+	// LocationTypes narrows the places of the slot to Google Maps place types of its category, e.g. bakery for an
+	// Eatery slot. Keyword narrows them to a brand, e.g. "Blue Bottle", searched by keyword instead of place type.
+	LocationTypes []POI.LocationType `json:"location_types,omitempty"`
+	Keyword       string             `json:"keyword,omitempty"`
 }
 
 func (s *Solver) Init(poiSearcher *iowrappers.PoiSearcher, placeDedupeCountLimit int, nearbyCitiesCountLimit int) {
@@ -111,7 +136,32 @@ func (s *Solver) Init(poiSearcher *iowrappers.PoiSearcher, placeDedupeCountLimit
 	s.concreteMatchers = append(s.concreteMatchers, &matching.MatcherForUserRatings{})
 	s.concreteMatchers = append(s.concreteMatchers, &matching.MatcherForTime{})
 	s.concreteMatchers = append(s.concreteMatchers, &matching.MatcherForPriceRange{})
+	s.concreteMatchers = append(s.concreteMatchers, &matching.MatcherForExcludedPlaces{})
+	s.concreteMatchers = append(s.concreteMatchers, &matching.MatcherForLocationTypes{})
 	s.placeMatcher = NewPlaceMatcher()
+	s.searchStrategy = SearchStrategyExhaustive
+	s.scorer = matching.DefaultScorer{}
+	s.diversityLambda = DiversityLambdaDisabled
+}
+
+func (s *Solver) SetSearchStrategy(strategy SearchStrategy) {
+	s.searchStrategy = strategy
+}
+
+func (s *Solver) SetScorer(scorer matching.Scorer) {
+	s.scorer = scorer
+}
+
+func (s *Solver) SetDiversityLambda(lambda float64) {
+	s.diversityLambda = lambda
+}
+
+// Scorer returns the scorer of plans, falling back to the default formula for a solver that is not initialized
+func (s *Solver) Scorer() matching.Scorer {
+	if s.scorer == nil {
+		return matching.DefaultScorer{}
+	}
+	return s.scorer
 }
 
 func (s *Solver) ValidateLocation(ctx context.Context, location *POI.Location) bool {
@@ -120,38 +170,16 @@ func (s *Solver) ValidateLocation(ctx context.Context, location *POI.Location) b
 		AdminAreaLevelOne: location.AdminAreaLevelOne,
 		Country:           location.Country,
 	}
-	_, _, err := s.Searcher.Geocode(ctx, &geoQuery)
+	lat, lng, err := s.Searcher.Geocode(ctx, &geoQuery)
 	if err != nil {
 		return false
 	}
 	location.City = geoQuery.City
 	location.Country = geoQuery.Country
+	location.Timezone = iowrappers.TimezoneAt(lat, lng, geoQuery.Country)
 	return true
 }
 
-func (s *Solver) SolveHungarianOptimal(ctx context.Context, req *PlanningRequest) ([]PlacePlanningDetails, error) {
-	clusters, err := s.generatePlacesForSlots(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-
-	placeIDs, err := s.FindOptimalPlan(clusters)
-	if err != nil {
-		return nil, err
-	}
-
-	places := make([]matching.Place, len(placeIDs))
-	results := make([]PlacePlanningDetails, len(placeIDs))
-	for idx, id := range placeIDs {
-		err = s.Searcher.GetRedisClient().FetchSingleRecord(ctx, iowrappers.PlaceDetailsRedisKeyPrefix+id, &places[idx].Place)
-		if err != nil {
-			return nil, err
-		}
-		results[idx] = toPlacePlanningDetails(places[idx].Place.Name, req.Slots[idx], places[idx].Place.URL)
-	}
-	return results, nil
-}
-
 func (s *Solver) SolveWithNearbyCities(ctx context.Context, req *MultiPlanningReq) *PlanningResp {
 	wg := sync.WaitGroup{}
 	wg.Add(len(req.requests))
@@ -160,6 +188,8 @@ func (s *Solver) SolveWithNearbyCities(ctx context.Context, req *MultiPlanningRe
 	for _, request := range req.requests {
 		go func(r *PlanningRequest) {
 			defer wg.Done()
+			// the plans are selected among the plans of every city, not among the plans selected for each city
+			r.candidatePool = true
 			responses <- s.Solve(ctx, r)
 		}(request)
 	}
@@ -171,6 +201,8 @@ func (s *Solver) SolveWithNearbyCities(ctx context.Context, req *MultiPlanningRe
 
 	// int8 is enough for place deduplication limit
 	includedPlaces := make(map[string]int8)
+	// keep more plans than requested for the diversity selection across cities to choose from
+	poolSize := s.diversityPoolSize(req.numPlans)
 
 	pq := MinPriorityQueue[PlanningSolution]{}
 	errs := make([]error, 0)
@@ -184,7 +216,7 @@ func (s *Solver) SolveWithNearbyCities(ctx context.Context, req *MultiPlanningRe
 				continue
 			}
 
-			if pq.Len() >= req.numPlans {
+			if pq.Len() >= poolSize {
 				if pq.items[0].Key() < solution.Key() {
 					top := pq.Pop().(PlanningSolution)
 					removePlaces(includedPlaces, top)
@@ -202,7 +234,7 @@ func (s *Solver) SolveWithNearbyCities(ctx context.Context, req *MultiPlanningRe
 		iowrappers.Logger.Debugf("->SolveWithNearbyCities: encountered error: %v", err)
 	}
 	if pq.Len() > 0 {
-		return &PlanningResp{Solutions: pq.items}
+		return &PlanningResp{Solutions: s.selectDiverseSolutions(pq.items, req.numPlans, req.requests[0].SearchRadius)}
 	}
 	return &PlanningResp{Err: fmt.Errorf("cannot find solutions"), ErrorCode: NoValidSolution}
 }
@@ -211,26 +243,41 @@ func (s *Solver) Solve(ctx context.Context, req *PlanningRequest) *PlanningResp
 	redisClient := s.Searcher.GetRedisClient()
 	logger := iowrappers.Logger
 	logger.Debugf("->Solve(ctx.Context, iowrappers.RedisClient, %v, *PlanningResp)", req)
-	if !req.PreciseLocation && !s.ValidateLocation(ctx, &req.Location) {
-		return &PlanningResp{Err: errors.New("invalid travel destination"), ErrorCode: InvalidRequestLocation}
+	if resp := cancelledResp(ctx); resp != nil {
+		return resp
 	}
-
-	if req.PreciseLocation {
-		geocode, err := s.Searcher.ReverseGeocode(ctx, req.Location.Latitude, req.Location.Longitude)
-		if err != nil {
+	travelMode, err := POI.ParseTravelMode(string(req.TravelMode))
+	if err != nil {
+		return &PlanningResp{Err: err, ErrorCode: BadRequest}
+	}
+	req.TravelMode = travelMode
+	for _, slot := range req.Slots {
+		if err = validateSlotRequest(slot); err != nil {
 			return &PlanningResp{Err: err, ErrorCode: InvalidRequestLocation}
 		}
-		req.Location.City = geocode.City
-		req.Location.AdminAreaLevelOne = geocode.AdminAreaLevelOne
-		req.Location.Country = geocode.Country
+	}
+	if resp := s.resolveLocation(ctx, &req.Location, req.PreciseLocation); resp != nil {
+		return resp
+	}
+	if err = s.resolveAnchors(ctx, req); err != nil {
+		return &PlanningResp{Err: err, ErrorCode: InvalidRequestLocation}
 	}
 
 	// set default planning results count
 	if req.NumPlans == 0 {
 		req.NumPlans = NumPlansDefault
 	}
+	// the radius normalises the travel penalty of the plans, e.g. a custom request can ask for a radius of 0
+	if req.SearchRadius == 0 {
+		req.SearchRadius = DefaultPlaceSearchRadius
+	}
+	req.blockedPlaceIDs = s.blockedPlaces(ctx)
+	req.closures = s.closuresAt(ctx, req.TravelDate, req.Location)
 
 	cacheRequest := toSolutionsSaveRequest(req, nil)
+	// fetch more cached plans than requested for the diversity selection to choose from
+	poolSize := s.diversityPoolSize(req.NumPlans)
+	cacheRequest.NumPlans = int64(poolSize)
 
 	cacheResponse, cacheErr := redisClient.PlanningSolutions(ctx, cacheRequest)
 	if cacheErr == nil {
@@ -238,21 +285,25 @@ func (s *Solver) Solve(ctx context.Context, req *PlanningRequest) *PlanningResp
 	}
 
 	var resp = &PlanningResp{}
-	if cacheErr != nil || len(cacheResponse.PlanningSolutionRecords) == 0 {
+	if cacheErr != nil || len(cacheResponse.PlanningSolutionRecords) == 0 || cacheResponse.TTL < req.refreshWithin {
 		resp = s.generateSolutions(ctx, req)
+		// the plans searched until a cancellation are not the best ones and are not saved
+		if cancelled := cancelledResp(ctx); cancelled != nil {
+			return cancelled
+		}
 		if resp.Err == nil {
 			if err := saveSolutions(ctx, redisClient, req, resp.Solutions); err != nil {
 				logger.Error(err)
 			}
 		}
-		resp.Solutions = resp.Solutions[:min(req.NumPlans, len(resp.Solutions))]
+		resp.Solutions = s.selectSolutions(req, resp.Solutions[:min(poolSize, len(resp.Solutions))])
 		return resp
 	}
 
 	logger.Debugf("[request_id: %s]Found %d planning solutions in Redis for req %+v.", ctx.Value(iowrappers.ContextRequestIdKey), len(cacheResponse.PlanningSolutionRecords), *req)
 	for idx, candidate := range cacheResponse.PlanningSolutionRecords {
 		// deal with cases where there are more saved solutions than requested
-		if idx >= req.NumPlans {
+		if idx >= poolSize {
 			break
 		}
 		planningSolution := PlanningSolution{
@@ -263,32 +314,84 @@ func (s *Solver) Solve(ctx context.Context, req *PlanningRequest) *PlanningResp
 			PlaceAddresses:  candidate.PlaceAddresses,
 			PlaceURLs:       candidate.PlaceURLs,
 			PlaceCategories: candidate.PlaceCategories,
+			PlaceTypes:      candidate.PlaceTypes,
 			Score:           candidate.Score,
 			PlanSpec:        req.spec,
+			Legs:            candidate.Legs,
+			StartLeg:        candidate.StartLeg,
+			EndLeg:          candidate.EndLeg,
+			ScoreBreakdown:  candidate.ScoreBreakdown,
+			Exclusions:      candidate.Exclusions,
 		}
 		resp.Solutions = append(resp.Solutions, planningSolution)
 	}
+	resp.Solutions = s.selectSolutions(req, resp.Solutions)
 	logger.Debugf("[request_id: %s]Using %d cached plans from Redis for req %+v.", ctx.Value(iowrappers.ContextRequestIdKey), len(resp.Solutions), *req)
 	return resp
 }
 
+// selectSolutions picks the plans of a request among the candidate plans, unless the request asks for the candidates
+func (s *Solver) selectSolutions(req *PlanningRequest, candidates []PlanningSolution) []PlanningSolution {
+	if req.candidatePool {
+		return candidates
+	}
+	return s.selectDiverseSolutions(candidates, req.NumPlans, req.SearchRadius)
+}
+
+// resolveLocation fills in city-level fields of the location, returning a non-nil response when the location is invalid
+func (s *Solver) resolveLocation(ctx context.Context, location *POI.Location, precise bool) *PlanningResp {
+	if !precise && !s.ValidateLocation(ctx, location) {
+		return &PlanningResp{Err: errors.New("invalid travel destination"), ErrorCode: InvalidRequestLocation}
+	}
+
+	if precise {
+		geocode, err := s.Searcher.ReverseGeocode(ctx, location.Latitude, location.Longitude)
+		if err != nil {
+			return &PlanningResp{Err: err, ErrorCode: InvalidRequestLocation}
+		}
+		location.City = geocode.City
+		location.AdminAreaLevelOne = geocode.AdminAreaLevelOne
+		location.Country = geocode.Country
+		iowrappers.SetTimezone(location)
+	}
+	return nil
+}
+
+type PlanTemplate string
+
+const (
+	PlanTemplateStandard PlanTemplate = "standard"
+	PlanTemplateNightOut PlanTemplate = "night_out"
+)
+
+// templateRequest generates a request for the slots of a plan template, the standard template by default
+func templateRequest(template PlanTemplate, travelDate string, weekday POI.Weekday, numResults int, priceLevel POI.PriceLevel) (PlanningRequest, error) {
+	switch template {
+	case PlanTemplateStandard, "":
+		return standardRequest(travelDate, weekday, numResults, priceLevel), nil
+	case PlanTemplateNightOut:
+		return nightOutRequest(travelDate, weekday, numResults, priceLevel), nil
+	}
+	return PlanningRequest{}, fmt.Errorf("unknown plan template %s", template)
+}
+
 // generates a request for normal template used by the regular search
 func standardRequest(travelDate string, weekday POI.Weekday, numResults int, priceLevel POI.PriceLevel) (req PlanningRequest) {
-	timeSlot1 := matching.TimeSlot{Slot: POI.TimeInterval{Start: 10, End: 12}}
+	timeSlot1 := matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(12, 0)}}
 	slotReq1 := SlotRequest{
 		TimeSlot: timeSlot1,
 		Category: POI.PlaceCategoryVisit,
 		Weekday:  weekday,
 	}
 
-	timeSlot2 := matching.TimeSlot{Slot: POI.TimeInterval{Start: 12, End: 13}}
+	timeSlot2 := matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(12, 0), End: POI.NewClockTime(13, 0)}}
 	slotReq2 := SlotRequest{
 		TimeSlot: timeSlot2,
 		Category: POI.PlaceCategoryEatery,
 		Weekday:  weekday,
 	}
 
-	timeSlot3 := matching.TimeSlot{Slot: POI.TimeInterval{Start: 14, End: 17}}
+	timeSlot3 := matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(14, 0), End: POI.NewClockTime(17, 0)}}
 
 	slotReq3 := SlotRequest{
 		TimeSlot: timeSlot3,
@@ -296,7 +399,7 @@ func standardRequest(travelDate string, weekday POI.Weekday, numResults int, pri
 		Weekday:  weekday,
 	}
 
-	timeSlot4 := matching.TimeSlot{Slot: POI.TimeInterval{Start: 18, End: 20}}
+	timeSlot4 := matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(18, 0), End: POI.NewClockTime(20, 0)}}
 	slotReq4 := SlotRequest{
 		TimeSlot: timeSlot4,
 		Category: POI.PlaceCategoryEatery,
@@ -310,7 +413,38 @@ func standardRequest(travelDate string, weekday POI.Weekday, numResults int, pri
 	return
 }
 
-func createPlanningSolutionCandidate(placeIndexes []int, placeClusters [][]matching.Place, radius uint, spec string) (PlanningSolution, error) {
+// nightOutRequest generates a request for dinner, drinks and a bar or a club open until 02:00 the next day.
+// Slots past midnight stay on the weekday the night starts, the hours of the next weekday are consulted for them.
+func nightOutRequest(travelDate string, weekday POI.Weekday, numResults int, priceLevel POI.PriceLevel) (req PlanningRequest) {
+	dinner := SlotRequest{
+		TimeSlot:      matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(19, 0), End: POI.NewClockTime(21, 0)}},
+		Category:      POI.PlaceCategoryEatery,
+		Weekday:       weekday,
+		LocationTypes: []POI.LocationType{POI.LocationTypeRestaurant},
+	}
+
+	drinks := SlotRequest{
+		TimeSlot:      matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(21, 0), End: POI.NewClockTime(23, 0)}},
+		Category:      POI.PlaceCategoryEatery,
+		Weekday:       weekday,
+		LocationTypes: []POI.LocationType{POI.LocationTypeBar},
+	}
+
+	lateNight := SlotRequest{
+		TimeSlot:      matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(23, 0), End: POI.LatestSlotEnd}},
+		Category:      POI.PlaceCategoryEatery,
+		Weekday:       weekday,
+		LocationTypes: []POI.LocationType{POI.LocationTypeBar, POI.LocationTypeNightClub},
+	}
+
+	req.Slots = append(req.Slots, []SlotRequest{dinner, drinks, lateNight}...)
+	req.TravelDate = travelDate
+	req.NumPlans = numResults
+	req.PriceLevel = priceLevel
+	return
+}
+
+func createPlanningSolutionCandidate(placeIndexes []int, placeClusters [][]matching.Place, req *PlanningRequest, scorer matching.Scorer) (PlanningSolution, error) {
 	var res PlanningSolution
 	if len(placeIndexes) != len(placeClusters) {
 		return res, errors.New(ErrMsgMismatchIterAndPlace)
@@ -339,19 +473,34 @@ func createPlanningSolutionCandidate(placeIndexes []int, placeClusters [][]match
 		res.PlaceLocations = append(res.PlaceLocations, [2]float64{place.Location().Latitude, place.Location().Longitude})
 		res.PlaceAddresses = append(res.PlaceAddresses, place.PlaceAddress())
 		res.PlaceCategories = append(res.PlaceCategories, place.PlaceCategory())
+		res.PlaceTypes = append(res.PlaceTypes, place.Type())
 		if len(strings.TrimSpace(place.Url())) == 0 {
 			place.SetURL(iowrappers.GoogleSearchHomePageURL)
 		}
 		res.PlaceURLs = append(res.PlaceURLs, place.Url())
 	}
 
-	res.Score = matching.Score(places, int(radius))
+	res.Legs = matching.TravelLegs(places, req.TravelMode)
+	if !matching.FitsTimeSlots(res.Legs, toTimeSlots(req.Slots)) {
+		return res, errors.New(ErrMsgTravelTimeExceedsSlots)
+	}
+
+	anchors := matching.NewAnchors(places, req.StartLocation, req.EndLocation, req.TravelMode)
+	res.StartLeg, res.EndLeg = anchors.StartLeg, anchors.EndLeg
+	breakdown := matching.ExplainAnchoredPlanScore(scorer, places, res.Legs, anchors, int(req.SearchRadius))
+	if pinned := pinnedSlots(req.Slots); pinned != nil {
+		breakdown.AnchorPenalty = matching.AnchorDistancePenalty(places, pinned, int(req.SearchRadius))
+		breakdown.Score -= breakdown.AnchorPenalty
+	}
+	res.Score = breakdown.Score
+	res.ScoreBreakdown = &breakdown
+	res.Exclusions = req.exclusions
 	res.ID = uuid.NewString()
-	res.PlanSpec = spec
+	res.PlanSpec = req.spec
 	return res, nil
 }
 
-func (s *Solver) FindBestPlanningSolutions(ctx context.Context, placeClusters [][]matching.Place, maxSolutionsToSaveCount int, iterator *MultiDimIterator, radius uint, spec string) (resp *PlanningResp) {
+func (s *Solver) FindBestPlanningSolutions(ctx context.Context, placeClusters [][]matching.Place, maxSolutionsToSaveCount int, iterator *MultiDimIterator, req *PlanningRequest) (resp *PlanningResp) {
 	if maxSolutionsToSaveCount <= 0 {
 		maxSolutionsToSaveCount = TopSolutionsCountDefault
 	}
@@ -371,7 +520,7 @@ func (s *Solver) FindBestPlanningSolutions(ctx context.Context, placeClusters []
 		default:
 			var candidate PlanningSolution
 			var err error
-			candidate, err = createPlanningSolutionCandidate(iterator.Status, placeClusters, radius, spec)
+			candidate, err = createPlanningSolutionCandidate(iterator.Status, placeClusters, req, s.Scorer())
 			iterator.Next()
 			if err != nil {
 				log.Debug(err)
@@ -414,50 +563,32 @@ func reversePlans(plans []PlanningSolution) {
 	slices.Reverse(plans)
 }
 
+// FindOptimalPlan returns the IDs of the places, one per slot, with the highest total place score
 func (s *Solver) FindOptimalPlan(placeClusters [][]matching.Place) ([]string, error) {
 	placeIds, weights, err := s.weightMatrix(placeClusters)
 	if err != nil {
 		return nil, err
 	}
-	solve, err := hungarianAlgorithm.Solve(fixWeights(weights))
+	assignments, err := newAssignmentIterator(weights)
 	if err != nil {
 		return nil, err
 	}
-	var result []string
-	for _, idx := range solve[:len(placeClusters)] {
-		result = append(result, placeIds[idx])
-	}
-	return result, nil
-}
-
-// We are solving a maximization problem instead of the default minimization problem Hungarian algorithm is designed for.
-// Therefore, needs to use the maximum value to minus all the values in the matrix.
-func fixWeights(weights [][]int) [][]int {
-	if len(weights) == 0 {
-		return nil
+	best, found, err := assignments.Next()
+	if err != nil {
+		return nil, err
 	}
-	fixedWeights := make([][]int, len(weights))
-	for idx := range fixedWeights {
-		fixedWeights[idx] = make([]int, len(weights[0]))
+	if !found {
+		return nil, errors.New("no assignment gives every slot a distinct place")
 	}
-	// assume the values are non-negative
-	var maxValue int
-	for _, ws := range weights {
-		for _, val := range ws {
-			if val > maxValue {
-				maxValue = val
-			}
-		}
+	result := make([]string, len(best.columns))
+	for slot, column := range best.columns {
+		result[slot] = placeIds[column]
 	}
-
-	for i, ws := range weights {
-		for j, val := range ws {
-			fixedWeights[i][j] = maxValue - val
-		}
-	}
-	return fixedWeights
+	return result, nil
 }
 
+// weightMatrix has a row per slot and a column per place in placeIds. The weight of a place in the cluster of a slot
+// is its place score in hundredths, any other place is unassignable to the slot.
 func (s *Solver) weightMatrix(placeClusters [][]matching.Place) ([]string, [][]int, error) {
 	uniquePlaces := make(map[string]bool)
 	for _, places := range placeClusters {
@@ -475,16 +606,17 @@ func (s *Solver) weightMatrix(placeClusters [][]matching.Place) ([]string, [][]i
 		placeIdsMap[id] = idx
 	}
 
-	weights := make([][]int, len(placeIds))
-
-	// initialize weights to zero
+	weights := make([][]int, len(placeClusters))
 	for idx := range weights {
 		weights[idx] = make([]int, len(placeIds))
+		for col := range weights[idx] {
+			weights[idx][col] = unassignableWeight
+		}
 	}
 
 	for idx, places := range placeClusters {
 		for _, place := range places {
-			weights[idx][placeIdsMap[place.Id()]] = int(100 * matching.Score([]matching.Place{place}, 1))
+			weights[idx][placeIdsMap[place.Id()]] = max(0, int(100*s.Scorer().PlaceScore(place)))
 		}
 	}
 
@@ -514,6 +646,15 @@ func (s *Solver) generatePlacesForSlots(ctx context.Context, req *PlanningReques
 	logger := iowrappers.Logger
 	var placeClusters [][]matching.Place
 	for _, slot := range req.Slots {
+		if slot.PinnedPlaceID != "" {
+			place, err := s.pinnedPlace(ctx, slot)
+			if err != nil {
+				return nil, err
+			}
+			placeClusters = append(placeClusters, []matching.Place{place})
+			continue
+		}
+
 		var filterParams = make(map[matching.FilterCriteria]interface{})
 		filterParams[matching.FilterByUserRating] = matching.UserRatingFilterParams{
 			MinUserRatings: 1,
@@ -522,6 +663,7 @@ func (s *Solver) generatePlacesForSlots(ctx context.Context, req *PlanningReques
 		filterParams[matching.FilterByTimePeriod] = matching.TimeFilterParams{
 			Day:          slot.Weekday,
 			TimeInterval: slot.TimeSlot.Slot,
+			Closures:     req.closures,
 		}
 
 		filterParams[matching.FilterByPriceRange] = matching.PriceRangeFilterParams{
@@ -529,28 +671,43 @@ func (s *Solver) generatePlacesForSlots(ctx context.Context, req *PlanningReques
 			PriceLevel: req.PriceLevel,
 		}
 
+		if len(req.blockedPlaceIDs) > 0 {
+			filterParams[matching.FilterByExcludedPlaces] = matching.ExcludedPlacesFilterParams{PlaceIDs: req.blockedPlaceIDs}
+		}
+
+		if len(slot.LocationTypes) > 0 {
+			filterParams[matching.FilterByLocationTypes] = matching.LocationTypesFilterParams{LocationTypes: slot.LocationTypes}
+		}
+
 		places, err := matching.NearbySearchForCategory(ctx, s.Searcher, &matching.Request{
 			Radius:             req.SearchRadius,
 			Location:           req.Location,
 			Category:           slot.Category,
 			UsePreciseLocation: req.PreciseLocation,
 			PriceLevel:         req.PriceLevel,
+			Keyword:            slot.Keyword,
 		})
 		if err != nil {
 			return nil, err
 		}
 		logger.Debugf("Before filtering, the number of places for category %s is %d", slot.Category, len(places))
 
+		candidates := places
 		places, err = s.filterPlaces(places, filterParams, slot.Category)
 		if err != nil {
 			return nil, err
 		}
+		if len(req.closures) > 0 {
+			if err = s.explainClosures(req, candidates, filterParams, slot.Category); err != nil {
+				return nil, err
+			}
+		}
 
 		if len(places) == 0 {
 			return nil, fmt.Errorf("failed to find any place for category %s at slot %s for location %+v", slot.Category, slot.TimeSlot.ToString(), req.Location)
 		}
 		// sort places by score descending so the solver checks places with higher score first
-		slices.SortFunc(places, func(a, b matching.Place) int { return cmp.Compare(matching.PlaceScore(b), matching.PlaceScore(a)) })
+		slices.SortFunc(places, func(a, b matching.Place) int { return cmp.Compare(s.Scorer().PlaceScore(b), s.Scorer().PlaceScore(a)) })
 		// truncate to top candidates to reduce search space
 		if len(places) > MaxPlacesPerSlot {
 			places = places[:MaxPlacesPerSlot]
@@ -560,6 +717,157 @@ func (s *Solver) generatePlacesForSlots(ctx context.Context, req *PlanningReques
 	return placeClusters, nil
 }
 
+// blockedPlaces returns the places blocked by the authenticated user making the request
+func (s *Solver) blockedPlaces(ctx context.Context) []string {
+	userID, ok := ctx.Value(iowrappers.ContextRequestUserId).(string)
+	if !ok || userID == "" {
+		return nil
+	}
+	placeIDs, err := s.Searcher.GetRedisClient().BlockedPlaces(ctx, userID)
+	if err != nil {
+		iowrappers.Logger.Errorf("failed to load blocked places of user %s: %v", userID, err)
+		return nil
+	}
+	return placeIDs
+}
+
+// closuresAt returns the closures of places and the holidays at a location on a travel date
+func (s *Solver) closuresAt(ctx context.Context, date string, location POI.Location) POI.Closures {
+	if date == "" {
+		return nil
+	}
+	closures, err := s.Searcher.GetRedisClient().ClosuresAt(ctx, date, location)
+	if err != nil {
+		iowrappers.Logger.Errorf("failed to load closures on %s: %v", date, err)
+		return nil
+	}
+	return closures
+}
+
+// explainClosures adds to the exclusions of the request the best places of a slot that its filters keep on the weekday
+// but that the closures on the travel date leave out, at most MaxExclusionsPerSlot of them
+func (s *Solver) explainClosures(req *PlanningRequest, candidates []matching.Place, params map[matching.FilterCriteria]interface{}, category POI.PlaceCategory) error {
+	weeklyParams := maps.Clone(params)
+	timeParams := weeklyParams[matching.FilterByTimePeriod].(matching.TimeFilterParams)
+	timeParams.Closures = nil
+	weeklyParams[matching.FilterByTimePeriod] = timeParams
+	openOnWeekday, err := s.filterPlaces(candidates, weeklyParams, category)
+	if err != nil {
+		return err
+	}
+
+	slices.SortFunc(openOnWeekday, func(a, b matching.Place) int { return cmp.Compare(s.Scorer().PlaceScore(b), s.Scorer().PlaceScore(a)) })
+	var added int
+	for _, place := range openOnWeekday {
+		if added == MaxExclusionsPerSlot {
+			break
+		}
+		closure, closed := req.closures.ClosureOf(place.Id(), place.Category)
+		if !closed || slices.ContainsFunc(req.exclusions, func(e POI.Exclusion) bool { return e.PlaceID == place.Id() }) {
+			continue
+		}
+		req.exclusions = append(req.exclusions, POI.Exclusion{PlaceID: place.Id(), PlaceName: place.Name(), Date: closure.Date, Reason: closure.Reason})
+		added++
+	}
+	return nil
+}
+
+// validateSlotCategory checks that a slot asks for one of the place categories in POI.AllPlaceCategories
+func validateSlotCategory(category POI.PlaceCategory) error {
+	if _, ok := POI.ParsePlaceCategory(string(category)); !ok {
+		return fmt.Errorf("unknown place category %q, expect one of %v", category, POI.AllPlaceCategories)
+	}
+	return nil
+}
+
+// validateSlotRequest checks the category of a slot and that its location types belong to the category, places of
+// other types are never searched for the slot
+func validateSlotRequest(slot SlotRequest) error {
+	if err := validateSlotCategory(slot.Category); err != nil {
+		return err
+	}
+	if interval := slot.TimeSlot.Slot; interval.Start >= interval.End || interval.End > POI.LatestSlotEnd {
+		return fmt.Errorf("time slot %s should end after it starts and no later than %s", slot.TimeSlot.ToString(), POI.LatestSlotEnd.ToString())
+	}
+	for _, locationType := range slot.LocationTypes {
+		if category, ok := POI.GetPlaceCategory(locationType); !ok || category != slot.Category {
+			return fmt.Errorf("place type %q is not a type of category %s", locationType, slot.Category)
+		}
+	}
+	if slot.Keyword != "" && POI.NormalizeBrandKey(slot.Keyword) == "" {
+		return fmt.Errorf("keyword %q has no letters or digits", slot.Keyword)
+	}
+	return nil
+}
+
+// pinnedSlots marks the slots with a place pinned by the user, it returns nil if no slot is pinned
+func pinnedSlots(slots []SlotRequest) []bool {
+	var pinned []bool
+	for idx, slot := range slots {
+		if slot.PinnedPlaceID == "" {
+			continue
+		}
+		if pinned == nil {
+			pinned = make([]bool, len(slots))
+		}
+		pinned[idx] = true
+	}
+	return pinned
+}
+
+// ErrPinnedPlaceNotFound means a slot pins a place ID without place details, e.g. an ID made up by the client
+var ErrPinnedPlaceNotFound = errors.New("pinned place does not exist")
+
+// pinnedPlace loads the place pinned by the user for a slot. Opening hours and price filters are not applied
+// since the user has already chosen the place.
+func (s *Solver) pinnedPlace(ctx context.Context, slot SlotRequest) (matching.Place, error) {
+	var place POI.Place
+	err := s.Searcher.GetRedisClient().FetchSingleRecord(ctx, iowrappers.PlaceDetailsRedisKeyPrefix+slot.PinnedPlaceID, &place)
+	if errors.Is(err, redis.Nil) {
+		return matching.Place{}, fmt.Errorf("%w: %s", ErrPinnedPlaceNotFound, slot.PinnedPlaceID)
+	}
+	if err != nil {
+		return matching.Place{}, err
+	}
+	return matching.CreatePlace(place, slot.Category), nil
+}
+
+// resolveAnchors sets the start and end locations not given by the request to the location of its lodging place
+func (s *Solver) resolveAnchors(ctx context.Context, req *PlanningRequest) error {
+	if req.LodgingPlaceID == "" || (req.StartLocation != nil && req.EndLocation != nil) {
+		return nil
+	}
+	var place POI.Place
+	err := s.Searcher.GetRedisClient().FetchSingleRecord(ctx, iowrappers.PlaceDetailsRedisKeyPrefix+req.LodgingPlaceID, &place)
+	if errors.Is(err, redis.Nil) {
+		return fmt.Errorf("lodging place %s does not exist", req.LodgingPlaceID)
+	}
+	if err != nil {
+		return err
+	}
+	if !isLodging(place) {
+		return fmt.Errorf("place %s is not a lodging place", req.LodgingPlaceID)
+	}
+
+	if req.StartLocation == nil {
+		req.StartLocation = &POI.Location{Latitude: place.Location.Latitude, Longitude: place.Location.Longitude}
+	}
+	if req.EndLocation == nil {
+		req.EndLocation = &POI.Location{Latitude: place.Location.Latitude, Longitude: place.Location.Longitude}
+	}
+	return nil
+}
+
+// isLodging checks whether the type a place was searched with or its primary type belongs to the Lodging category
+func isLodging(place POI.Place) bool {
+	for _, placeType := range []POI.LocationType{place.LocationType, POI.PrimaryLocationType(place.Types)} {
+		if category, ok := POI.GetPlaceCategory(placeType); ok && category == POI.PlaceCategoryLodging {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Solver) filterPlaces(places []matching.Place, params map[matching.FilterCriteria]interface{}, c POI.PlaceCategory) ([]matching.Place, error) {
 	logger := iowrappers.Logger
 	var res = places
@@ -579,16 +887,35 @@ func (s *Solver) filterPlaces(places []matching.Place, params map[matching.Filte
 }
 
 func (s *Solver) generateSolutions(ctx context.Context, req *PlanningRequest) (resp *PlanningResp) {
-	resp = &PlanningResp{}
 	placeClusters, err := s.generatePlacesForSlots(ctx, req)
 	if err != nil {
-		resp.ErrorCode = InternalError
-		resp.Err = err
-		return
+		return &PlanningResp{ErrorCode: placesErrorCode(err), Err: err}
+	}
+	if resp = cancelledResp(ctx); resp != nil {
+		return resp
 	}
+	return s.solvePlaceClusters(ctx, req, placeClusters)
+}
 
-	placeCategories := toPlaceCategories(req.Slots)
+// placesErrorCode returns the error code of a failed search of the places of the slots, a place pinned by the request
+// that does not exist is an error of the request
+func placesErrorCode(err error) int {
+	if errors.Is(err, ErrPinnedPlaceNotFound) {
+		return BadRequest
+	}
+	return InternalError
+}
+
+// cancelledResp returns the response to a request cancelled by its context, or nil while the request is not cancelled
+func cancelledResp(ctx context.Context) *PlanningResp {
+	if err := ctx.Err(); err != nil {
+		return &PlanningResp{Err: err, ErrorCode: RequestTimeOut}
+	}
+	return nil
+}
 
+// solvePlaceClusters searches the best plans for candidate places already generated for each slot of the request
+func (s *Solver) solvePlaceClusters(ctx context.Context, req *PlanningRequest, placeClusters [][]matching.Place) (resp *PlanningResp) {
 	// group each slot's places into spatial clusters
 	spatialGroups := groupPlacesBySpatialClusters(placeClusters, req.SearchRadius)
 
@@ -596,11 +923,10 @@ func (s *Solver) generateSolutions(ctx context.Context, req *PlanningRequest) (r
 	pq := &MinPriorityQueue[Vertex]{}
 	includedPlaces := make(map[string]int8)
 	for _, group := range spatialGroups {
-		mdIter := &MultiDimIterator{}
-		if err = mdIter.Init(placeCategories, group); err != nil {
+		groupResp := s.searchPlans(ctx, group, req)
+		if groupResp.Err != nil {
 			continue
 		}
-		groupResp := s.FindBestPlanningSolutions(ctx, group, MaxSolutionsToSaveCount, mdIter, req.SearchRadius, req.spec)
 		for _, sol := range groupResp.Solutions {
 			if s.isPlanDuplicate(includedPlaces, sol) {
 				continue
@@ -620,18 +946,25 @@ func (s *Solver) generateSolutions(ctx context.Context, req *PlanningRequest) (r
 
 	if pq.Len() == 0 {
 		// fall back to non-clustered approach
-		mdIter := &MultiDimIterator{}
-		if err = mdIter.Init(placeCategories, placeClusters); err != nil {
-			resp.ErrorCode = NoValidSolution
-			resp.Err = err
-			return
-		}
-		return s.FindBestPlanningSolutions(ctx, placeClusters, MaxSolutionsToSaveCount, mdIter, req.SearchRadius, req.spec)
+		return s.searchPlans(ctx, placeClusters, req)
 	}
 
 	return &PlanningResp{Solutions: solutions(pq)}
 }
 
+// searchPlans finds the best plans for candidate places of each slot with the configured search strategy
+func (s *Solver) searchPlans(ctx context.Context, placeClusters [][]matching.Place, req *PlanningRequest) *PlanningResp {
+	if s.searchStrategy == SearchStrategyBestFirst {
+		return s.FindBestPlanningSolutionsBestFirst(ctx, placeClusters, MaxSolutionsToSaveCount, req)
+	}
+
+	mdIter := &MultiDimIterator{}
+	if err := mdIter.Init(toPlaceCategories(req.Slots), placeClusters); err != nil {
+		return &PlanningResp{ErrorCode: NoValidSolution, Err: err}
+	}
+	return s.FindBestPlanningSolutions(ctx, placeClusters, MaxSolutionsToSaveCount, mdIter, req)
+}
+
 // groupPlacesBySpatialClusters divides places across slots into groups where places
 // within each group are geographically close to each other. This produces plans
 // with places that are near each other, reducing travel time.
@@ -1,7 +1,11 @@
 package planner
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"math"
+	"net/url"
 	"reflect"
 	"testing"
 
@@ -10,6 +14,7 @@ import (
 	"github.com/weihesdlegend/Vacation-planner/POI"
 	"github.com/weihesdlegend/Vacation-planner/iowrappers"
 	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/test/redis_client_mocks"
 	"github.com/weihesdlegend/Vacation-planner/utils"
 )
 
@@ -87,8 +92,8 @@ func TestSolver_filterPlaces1(t *testing.T) {
 					matching.FilterByTimePeriod: matching.TimeFilterParams{
 						Day: POI.DateFriday,
 						TimeInterval: POI.TimeInterval{
-							Start: 11,
-							End:   16,
+							Start: POI.NewClockTime(11, 0),
+							End:   POI.NewClockTime(16, 0),
 						},
 					},
 					matching.FilterByPriceRange: matching.PriceRangeFilterParams{
@@ -134,3 +139,331 @@ func extractPlaceIDs(places []matching.Place) ([]string, error) {
 	}
 	return placeIDs, nil
 }
+
+func TestCreatePlanningSolutionCandidate_shouldRejectTravelBeyondTimeSlots(t *testing.T) {
+	clusters := [][]matching.Place{
+		{makePlace("sf", 37.7880, -122.4075)},
+		{makePlace("sf-nearby", 37.7906, -122.4058), makePlace("sacramento", 38.5816, -121.4944)},
+	}
+	req := &PlanningRequest{
+		Slots: []SlotRequest{
+			{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(12, 0)}}, Category: POI.PlaceCategoryVisit},
+			{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(12, 0), End: POI.NewClockTime(13, 0)}}, Category: POI.PlaceCategoryEatery},
+		},
+		SearchRadius: DefaultPlaceSearchRadius,
+		TravelMode:   POI.TravelModeWalking,
+	}
+
+	solution, err := createPlanningSolutionCandidate([]int{0, 0}, clusters, req, matching.DefaultScorer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(solution.Legs) != 1 || solution.Legs[0].Mode != POI.TravelModeWalking || solution.Legs[0].Minutes == 0 {
+		t.Errorf("expected one walking leg with non-zero duration, got %+v", solution.Legs)
+	}
+
+	if _, err = createPlanningSolutionCandidate([]int{0, 1}, clusters, req, matching.DefaultScorer{}); err == nil || err.Error() != ErrMsgTravelTimeExceedsSlots {
+		t.Errorf("expected error %q, got %v", ErrMsgTravelTimeExceedsSlots, err)
+	}
+}
+
+func TestGeneratePlacesForSlots_shouldUsePinnedPlace(t *testing.T) {
+	redisURL, _ := url.Parse("redis://" + redis_client_mocks.RedisMockSvr.Addr())
+	s := &Solver{Searcher: iowrappers.CreatePoiSearcher("fake-api-key", redisURL)}
+
+	pinned := POI.Place{ID: "pinned-restaurant", Name: "Booked Restaurant", Rating: 4.2, Location: POI.Location{Latitude: 37.79, Longitude: -122.40}}
+	placeJson, _ := json.Marshal(pinned)
+	if err := redis_client_mocks.RedisMockSvr.Set(iowrappers.PlaceDetailsRedisKeyPrefix+pinned.ID, string(placeJson)); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &PlanningRequest{Slots: []SlotRequest{{Category: POI.PlaceCategoryEatery, PinnedPlaceID: pinned.ID}}}
+	clusters, err := s.generatePlacesForSlots(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 || len(clusters[0]) != 1 || clusters[0][0].Id() != pinned.ID {
+		t.Fatalf("expected only the pinned place for the slot, got %+v", clusters)
+	}
+	if clusters[0][0].PlaceCategory() != POI.PlaceCategoryEatery {
+		t.Errorf("expected the pinned place to take the slot category, got %s", clusters[0][0].PlaceCategory())
+	}
+
+	req.Slots[0].PinnedPlaceID = "unknown-place"
+	if _, err = s.generatePlacesForSlots(context.Background(), req); !errors.Is(err, ErrPinnedPlaceNotFound) {
+		t.Errorf("expected an error for a pinned place that does not exist, got %v", err)
+	}
+}
+
+func TestCreatePlanningSolutionCandidate_shouldPreferPlacesNearPinnedPlace(t *testing.T) {
+	clusters := [][]matching.Place{
+		{makePlace("pinned", 37.7880, -122.4075)},
+		{makePlace("near", 37.7906, -122.4058), makePlace("far", 37.8044, -122.2712)},
+	}
+	req := &PlanningRequest{
+		Slots: []SlotRequest{
+			{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(12, 0)}}, Category: POI.PlaceCategoryEatery, PinnedPlaceID: "pinned"},
+			{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(13, 0), End: POI.NewClockTime(17, 0)}}, Category: POI.PlaceCategoryVisit},
+		},
+		SearchRadius: DefaultPlaceSearchRadius,
+	}
+
+	near, err := createPlanningSolutionCandidate([]int{0, 0}, clusters, req, matching.DefaultScorer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	far, err := createPlanningSolutionCandidate([]int{0, 1}, clusters, req, matching.DefaultScorer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if near.Score <= far.Score {
+		t.Errorf("expected the plan near the pinned place to score higher, got %f and %f", near.Score, far.Score)
+	}
+
+	unpinned := *req
+	unpinned.Slots = []SlotRequest{req.Slots[0], req.Slots[1]}
+	unpinned.Slots[0].PinnedPlaceID = ""
+	farUnpinned, err := createPlanningSolutionCandidate([]int{0, 1}, clusters, &unpinned, matching.DefaultScorer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if far.Score >= farUnpinned.Score {
+		t.Errorf("expected the distance to the pinned place to lower the score, got %f and %f", far.Score, farUnpinned.Score)
+	}
+}
+
+func TestCreatePlanningSolutionCandidate_shouldExplainScore(t *testing.T) {
+	clusters := [][]matching.Place{
+		{makePlace("pinned", 37.7880, -122.4075)},
+		{makePlace("far", 37.8044, -122.2712)},
+	}
+	req := &PlanningRequest{
+		Slots: []SlotRequest{
+			{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(12, 0)}}, Category: POI.PlaceCategoryEatery, PinnedPlaceID: "pinned"},
+			{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(13, 0), End: POI.NewClockTime(17, 0)}}, Category: POI.PlaceCategoryVisit},
+		},
+		SearchRadius: DefaultPlaceSearchRadius,
+	}
+
+	solution, err := createPlanningSolutionCandidate([]int{0, 0}, clusters, req, matching.DefaultScorer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	breakdown := solution.ScoreBreakdown
+	if breakdown == nil || len(breakdown.Places) != 2 {
+		t.Fatalf("expected a breakdown of 2 places, got %+v", breakdown)
+	}
+	if breakdown.Score != solution.Score {
+		t.Errorf("expected the breakdown score %f to equal the plan score %f", breakdown.Score, solution.Score)
+	}
+	if breakdown.TravelPenalty <= 0 || breakdown.AnchorPenalty <= 0 || breakdown.DistancePenalty <= 0 {
+		t.Errorf("expected positive penalties, got %+v", breakdown)
+	}
+	expected := breakdown.AveragePlaceScore - breakdown.TravelPenalty - breakdown.AnchorPenalty
+	if math.Abs(expected-solution.Score) > 1e-9 {
+		t.Errorf("expected the score %f to add up from the breakdown, got %f", expected, solution.Score)
+	}
+}
+
+func TestSolver_filterPlaces_shouldDropBlockedPlaces(t *testing.T) {
+	s := &Solver{}
+	s.Init(nil, 2, 3)
+
+	places := []matching.Place{makePlace("kept", 37.78, -122.41), makePlace("blocked", 37.79, -122.40)}
+	for _, place := range places {
+		place.Place.Hours[POI.DateMonday] = "Monday: 9:00AM-6:00PM"
+	}
+	params := map[matching.FilterCriteria]interface{}{
+		matching.FilterByUserRating: matching.UserRatingFilterParams{MinUserRatings: 1},
+		matching.FilterByTimePeriod: matching.TimeFilterParams{Day: POI.DateMonday, TimeInterval: POI.TimeInterval{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(12, 0)}},
+		matching.FilterByPriceRange: matching.PriceRangeFilterParams{Category: POI.PlaceCategoryVisit},
+	}
+
+	results, err := s.filterPlaces(places, params, POI.PlaceCategoryVisit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected no place to be dropped without blocked places, got %d places", len(results))
+	}
+
+	params[matching.FilterByExcludedPlaces] = matching.ExcludedPlacesFilterParams{PlaceIDs: []string{"blocked"}}
+	results, err = s.filterPlaces(places, params, POI.PlaceCategoryVisit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Id() != "kept" {
+		t.Errorf("expected only the place that is not blocked, got %+v", results)
+	}
+}
+
+func TestSolver_resolveAnchors(t *testing.T) {
+	redisURL, _ := url.Parse("redis://" + redis_client_mocks.RedisMockSvr.Addr())
+	s := &Solver{Searcher: iowrappers.CreatePoiSearcher("fake-api-key", redisURL)}
+	places := []POI.Place{
+		{ID: "anchor-hotel", LocationType: POI.LocationTypeLodging, Location: POI.Location{Latitude: 37.78, Longitude: -122.41}},
+		{ID: "anchor-museum", LocationType: POI.LocationType("museum"), Location: POI.Location{Latitude: 37.79, Longitude: -122.40}},
+	}
+	for _, place := range places {
+		placeJson, _ := json.Marshal(place)
+		if err := redis_client_mocks.RedisMockSvr.Set(iowrappers.PlaceDetailsRedisKeyPrefix+place.ID, string(placeJson)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	station := &POI.Location{Latitude: 37.77, Longitude: -122.42}
+	req := &PlanningRequest{LodgingPlaceID: "anchor-hotel", EndLocation: station}
+	if err := s.resolveAnchors(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if req.StartLocation == nil || req.StartLocation.Latitude != 37.78 || req.EndLocation != station {
+		t.Errorf("expected the day to start at the hotel and end at the station, got %+v and %+v", req.StartLocation, req.EndLocation)
+	}
+
+	for _, placeID := range []string{"anchor-museum", "anchor-unknown"} {
+		if err := s.resolveAnchors(context.Background(), &PlanningRequest{LodgingPlaceID: placeID}); err == nil {
+			t.Errorf("expected an error for lodging place %s", placeID)
+		}
+	}
+}
+
+func TestSolver_Solve_shouldRejectUnknownSlotCategory(t *testing.T) {
+	redisURL, _ := url.Parse("redis://" + redis_client_mocks.RedisMockSvr.Addr())
+	s := &Solver{Searcher: iowrappers.CreatePoiSearcher("fake-api-key", redisURL)}
+	req := &PlanningRequest{Slots: []SlotRequest{
+		{Category: POI.PlaceCategoryShopping, TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(12, 0)}}},
+		{Category: POI.PlaceCategory("Nightlife"), TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(22, 0), End: POI.NewClockTime(23, 0)}}},
+	}}
+	if resp := s.Solve(context.Background(), req); resp.Err == nil || resp.ErrorCode != InvalidRequestLocation {
+		t.Errorf("expected an invalid request error, got %+v", resp)
+	}
+}
+
+func TestSolver_Solve_shouldRejectUnknownTravelMode(t *testing.T) {
+	redisURL, _ := url.Parse("redis://" + redis_client_mocks.RedisMockSvr.Addr())
+	s := &Solver{Searcher: iowrappers.CreatePoiSearcher("fake-api-key", redisURL)}
+	req := &PlanningRequest{TravelMode: "teleporting", Slots: []SlotRequest{
+		{Category: POI.PlaceCategoryVisit, TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(12, 0)}}},
+	}}
+	if resp := s.Solve(context.Background(), req); resp.Err == nil || resp.ErrorCode != BadRequest {
+		t.Errorf("expected a bad request error, got %+v", resp)
+	}
+}
+
+func TestSolver_Solve_shouldRejectUnknownPinnedPlace(t *testing.T) {
+	p := newNearbyCitiesPlanner(t)
+	req := nearbyCitiesRequest()
+	req.WithNearbyCities = false
+	req.Slots[0].PinnedPlaceID = "made-up-place"
+	resp := p.Solver.Solve(context.Background(), req)
+	if !errors.Is(resp.Err, ErrPinnedPlaceNotFound) || resp.ErrorCode != BadRequest {
+		t.Errorf("expected a bad request error, got %+v", resp)
+	}
+}
+
+func TestValidateSlotRequest(t *testing.T) {
+	morning := matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(9, 30), End: POI.NewClockTime(11, 0)}}
+	valid := []SlotRequest{
+		{Category: POI.PlaceCategoryEatery, TimeSlot: morning, LocationTypes: []POI.LocationType{POI.LocationTypeBakery}},
+		{Category: POI.PlaceCategoryVisit, TimeSlot: morning, LocationTypes: []POI.LocationType{POI.LocationTypeMuseum, POI.LocationTypeGallery}},
+		{Category: POI.PlaceCategoryEatery, TimeSlot: morning, Keyword: "Blue Bottle"},
+		{Category: POI.PlaceCategoryEatery, TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(23, 0), End: POI.LatestSlotEnd}}},
+	}
+	for _, slot := range valid {
+		if err := validateSlotRequest(slot); err != nil {
+			t.Errorf("expected slot %+v to be valid, got %v", slot, err)
+		}
+	}
+
+	invalid := []SlotRequest{
+		{Category: POI.PlaceCategoryVisit, TimeSlot: morning, LocationTypes: []POI.LocationType{POI.LocationTypeBakery}},
+		{Category: POI.PlaceCategoryEatery, TimeSlot: morning, LocationTypes: []POI.LocationType{"food_truck"}},
+		{Category: POI.PlaceCategoryEatery, TimeSlot: morning, Keyword: "'&'"},
+		{Category: POI.PlaceCategoryEatery},
+		{Category: POI.PlaceCategoryEatery, TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(14, 0), End: POI.NewClockTime(12, 0)}}},
+		{Category: POI.PlaceCategoryEatery, TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(23, 0), End: POI.NewClockTime(27, 0)}}},
+	}
+	for _, slot := range invalid {
+		if err := validateSlotRequest(slot); err == nil {
+			t.Errorf("expected an error for slot %+v", slot)
+		}
+	}
+}
+
+func TestTemplateRequest_shouldPlanNightOutPastMidnight(t *testing.T) {
+	req, err := templateRequest(PlanTemplateNightOut, "2026-10-16", POI.DateFriday, 3, POI.PriceLevelTwo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := req.Slots[len(req.Slots)-1]
+	if last.TimeSlot.Slot.End != POI.LatestSlotEnd || last.Weekday != POI.DateFriday {
+		t.Errorf("expected the night to end at 02:00 after Friday, got %s on %s", last.TimeSlot.ToString(), last.Weekday.String())
+	}
+	for _, slot := range req.Slots {
+		if err = validateSlotRequest(slot); err != nil {
+			t.Errorf("expected the slots of the template to be valid, got %v", err)
+		}
+	}
+
+	if req, err = templateRequest("", "2026-10-16", POI.DateFriday, 3, POI.PriceLevelTwo); err != nil || len(req.Slots) != len(standardRequest("", POI.DateFriday, 3, POI.PriceLevelTwo).Slots) {
+		t.Errorf("expected the standard template by default, got %+v and %v", req, err)
+	}
+	if _, err = templateRequest("brunch", "2026-10-16", POI.DateFriday, 3, POI.PriceLevelTwo); err == nil {
+		t.Error("expected an error for an unknown template")
+	}
+}
+
+func TestSolver_explainClosures_shouldExplainPlacesClosedOnTravelDate(t *testing.T) {
+	s := &Solver{}
+	s.Init(nil, 2, 3)
+
+	places := []matching.Place{makePlace("museum", 37.78, -122.41), makePlace("gallery", 37.79, -122.40), makePlace("renovating", 37.77, -122.42)}
+	for idx := range places {
+		places[idx].Category = POI.PlaceCategoryVisit
+		places[idx].Place.Hours[POI.DateMonday] = "Monday: 9:00AM-6:00PM"
+	}
+	// closed on Mondays anyway, so its closure does not explain why it is left out
+	places[2].Place.Hours[POI.DateMonday] = "Monday: Closed"
+
+	req := &PlanningRequest{TravelDate: "2026-12-28", closures: POI.Closures{
+		{Date: "2026-12-28", PlaceID: "museum", Reason: "Exhibition change"},
+		{Date: "2026-12-28", PlaceID: "renovating", Reason: "Renovation"},
+		{Date: "2026-12-28", Country: "United States", Categories: []POI.PlaceCategory{POI.PlaceCategoryShopping}, Reason: "Holiday sales"},
+	}}
+	params := map[matching.FilterCriteria]interface{}{
+		matching.FilterByUserRating: matching.UserRatingFilterParams{MinUserRatings: 1},
+		matching.FilterByTimePeriod: matching.TimeFilterParams{Day: POI.DateMonday, TimeInterval: POI.TimeInterval{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(12, 0)}, Closures: req.closures},
+		matching.FilterByPriceRange: matching.PriceRangeFilterParams{Category: POI.PlaceCategoryVisit},
+	}
+
+	results, err := s.filterPlaces(places, params, POI.PlaceCategoryVisit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Id() != "gallery" {
+		t.Fatalf("expected only the gallery to stay open, got %+v", results)
+	}
+
+	if err = s.explainClosures(req, places, params, POI.PlaceCategoryVisit); err != nil {
+		t.Fatal(err)
+	}
+	// explaining the same places for another slot does not repeat them
+	if err = s.explainClosures(req, places, params, POI.PlaceCategoryVisit); err != nil {
+		t.Fatal(err)
+	}
+	expected := []POI.Exclusion{{PlaceID: "museum", Date: "2026-12-28", Reason: "Exhibition change"}}
+	if !reflect.DeepEqual(req.exclusions, expected) {
+		t.Errorf("expected exclusions %+v, got %+v", expected, req.exclusions)
+	}
+
+	solution, err := createPlanningSolutionCandidate([]int{0}, [][]matching.Place{results}, &PlanningRequest{
+		Slots:      []SlotRequest{{Category: POI.PlaceCategoryVisit, TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(12, 0)}}}},
+		exclusions: req.exclusions,
+	}, s.Scorer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(solution.Exclusions) != 1 {
+		t.Errorf("expected the plan to explain the excluded museum, got %+v", solution.Exclusions)
+	}
+}
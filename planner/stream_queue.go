@@ -0,0 +1,389 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+const (
+	// JobQueueBackendRedisStreams is the job queue backend keeping jobs in Redis streams, the default backend keeps them
+	// in memory
+	JobQueueBackendRedisStreams = "redis_streams"
+	JobStreamConsumerGroup      = "planner-workers"
+	// JobStreamReclaimIdleDefault is how long a job stays with a worker before it is handed to another worker, it has
+	// to be longer than the longest job
+	JobStreamReclaimIdleDefault = 5 * time.Minute
+	// a blocking read waits up to jobStreamBlockTimeout, so that workers notice a closed queue
+	jobStreamBlockTimeout = time.Second
+	jobStreamRetryDelay   = time.Second
+)
+
+// streamJobParameters creates the parameters of the jobs of a type, which are decoded from the JSON of stream entries
+var streamJobParameters = map[string]func() interface{}{
+	PlanningJobType:      func() interface{} { return new(PlanningRequest) },
+	AsyncPlanningJobType: func() interface{} { return new(PlanningRequest) },
+	CacheWarmingJobType:  func() interface{} { return new(PlanningRequest) },
+}
+
+// RedisStreamJobQueue is a JobQueue keeping the jobs of each priority in a Redis stream, so that queued jobs survive
+// restarts and deployments. The workers of every server read the streams as consumers of one consumer group. A job
+// stays pending for the consumer that read it until it is acknowledged, the jobs of a consumer that crashed are
+// claimed by other consumers once they are pending for reclaimIdle.
+type RedisStreamJobQueue struct {
+	c           *iowrappers.RedisClient
+	ctx         context.Context
+	group       string
+	consumer    string
+	streams     []string // in the order of jobPriorities
+	reclaimIdle time.Duration
+
+	mu          sync.Mutex
+	closed      bool
+	delivered   []*iowrappers.JobStreamEntry // entries read ahead, so that their wait times can be compared
+	inflight    map[*iowrappers.Job]*iowrappers.JobStreamEntry
+	reclaimedAt time.Time
+	waits       jobWaitRecorder
+}
+
+// NewRedisStreamJobQueue creates a queue reading the job streams as a consumer of the consumer group, the consumer
+// name must be unique among the servers, e.g. the host name
+func NewRedisStreamJobQueue(c *iowrappers.RedisClient, group, consumer string, reclaimIdle time.Duration) (*RedisStreamJobQueue, error) {
+	q := &RedisStreamJobQueue{
+		c:           c,
+		ctx:         context.Background(),
+		group:       group,
+		consumer:    consumer,
+		reclaimIdle: reclaimIdle,
+		inflight:    make(map[*iowrappers.Job]*iowrappers.JobStreamEntry),
+	}
+	if q.reclaimIdle <= 0 {
+		q.reclaimIdle = JobStreamReclaimIdleDefault
+	}
+	for _, priority := range jobPriorities {
+		q.streams = append(q.streams, iowrappers.JobStreamKey(priority))
+	}
+	for _, stream := range q.streams {
+		if err := c.CreateJobStreamGroup(q.ctx, stream, group); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// Enqueue adds a job to the stream of its priority, unknown priorities are queued as normal ones
+func (q *RedisStreamJobQueue) Enqueue(job *iowrappers.Job) bool {
+	if q.isClosed() {
+		return false
+	}
+	job.EnqueuedAt = time.Now()
+	data, err := json.Marshal(job)
+	if err != nil {
+		iowrappers.Logger.Errorf("failed to serialize job %s: %v", job.ID, err)
+		return false
+	}
+	if _, err = q.c.AddJobStreamEntry(q.ctx, iowrappers.JobStreamKey(job.Priority), string(data)); err != nil {
+		iowrappers.Logger.Errorf("failed to queue job %s: %v", job.ID, err)
+		return false
+	}
+	return true
+}
+
+// Dequeue retrieves the next job, prioritizing the jobs of crashed consumers and then high > normal > low with priorities
+// raised by aging
+// Returns nil when the queue is closed and no job is waiting, the jobs pending for other consumers stay in Redis
+func (q *RedisStreamJobQueue) Dequeue() *iowrappers.Job {
+	logger := iowrappers.Logger
+	for {
+		entry, err := q.nextEntry()
+		if err != nil {
+			logger.Errorf("failed to read job streams: %v", err)
+			if q.isClosed() {
+				return nil
+			}
+			time.Sleep(jobStreamRetryDelay)
+			continue
+		}
+		if entry == nil {
+			if q.isClosed() {
+				return nil
+			}
+			continue
+		}
+
+		job, err := decodeJob(entry.Data)
+		if err != nil {
+			logger.Errorf("dropping invalid job %s of stream %s: %v", entry.ID, entry.Stream, err)
+			q.ack(entry)
+			continue
+		}
+		q.mu.Lock()
+		q.inflight[job] = entry
+		q.mu.Unlock()
+		return job
+	}
+}
+
+// Ack removes a dequeued job from its stream
+func (q *RedisStreamJobQueue) Ack(job *iowrappers.Job) {
+	q.mu.Lock()
+	entry, ok := q.inflight[job]
+	delete(q.inflight, job)
+	q.mu.Unlock()
+	if ok {
+		q.ack(entry)
+	}
+}
+
+func (q *RedisStreamJobQueue) ack(entry *iowrappers.JobStreamEntry) {
+	if err := q.c.AckJobStreamEntry(q.ctx, entry.Stream, q.group, entry.ID); err != nil {
+		iowrappers.Logger.Errorf("failed to acknowledge job %s of stream %s: %v", entry.ID, entry.Stream, err)
+	}
+}
+
+// Close stops the queue from accepting jobs, the jobs left in the streams are dequeued after a restart
+func (q *RedisStreamJobQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+}
+
+func (q *RedisStreamJobQueue) isClosed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+// Len returns the total number of jobs waiting in the streams
+func (q *RedisStreamJobQueue) Len() int {
+	high, normal, low := q.Stats()
+	return high + normal + low
+}
+
+// Stats returns the number of jobs waiting in the stream of each priority
+func (q *RedisStreamJobQueue) Stats() (high, normal, low int) {
+	depths := q.depths()
+	return depths[iowrappers.JobPriorityHigh], depths[iowrappers.JobPriorityNormal], depths[iowrappers.JobPriorityLow]
+}
+
+// depths returns the number of jobs of each priority waiting in the streams or read ahead by this server
+func (q *RedisStreamJobQueue) depths() map[iowrappers.JobPriority]int {
+	depths := make(map[iowrappers.JobPriority]int)
+	for idx, stream := range q.streams {
+		backlog, err := q.c.JobStreamBacklog(q.ctx, stream, q.group)
+		if err != nil {
+			iowrappers.Logger.Error(err)
+		}
+		depths[jobPriorities[idx]] = int(backlog)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, entry := range q.delivered {
+		depths[q.priorityOf(entry.Stream)]++
+	}
+	return depths
+}
+
+// WaitStats returns the depth, the wait time of the oldest job and the wait times of the jobs dequeued by this server
+// of each priority
+func (q *RedisStreamJobQueue) WaitStats() JobQueueStats {
+	depths := q.depths()
+	oldest := make(map[iowrappers.JobPriority]time.Time)
+	for idx, stream := range q.streams {
+		entry, err := q.c.OldestJobStreamEntry(q.ctx, stream, q.group)
+		if err != nil {
+			iowrappers.Logger.Error(err)
+		}
+		if entry != nil {
+			oldest[jobPriorities[idx]] = entry.EnqueuedAt()
+		}
+	}
+	q.mu.Lock()
+	for _, entry := range q.delivered {
+		priority := q.priorityOf(entry.Stream)
+		if enqueuedAt, ok := oldest[priority]; !ok || entry.EnqueuedAt().Before(enqueuedAt) {
+			oldest[priority] = entry.EnqueuedAt()
+		}
+	}
+	q.mu.Unlock()
+	return q.waits.snapshot(depths, oldest, time.Now())
+}
+
+// priorityOf returns the priority of the jobs of a stream
+func (q *RedisStreamJobQueue) priorityOf(stream string) iowrappers.JobPriority {
+	for idx, priorityStream := range q.streams {
+		if priorityStream == stream {
+			return jobPriorities[idx]
+		}
+	}
+	return iowrappers.JobPriorityNormal
+}
+
+// nextEntry returns a stale entry of a crashed consumer, or the entry to dequeue among the next entries of each
+// priority, which are read ahead and kept in delivered. Without any, it waits up to jobStreamBlockTimeout for an entry
+// unless the queue is closed.
+func (q *RedisStreamJobQueue) nextEntry() (*iowrappers.JobStreamEntry, error) {
+	if entry, err := q.claimStaleEntry(); entry != nil || err != nil {
+		return entry, err
+	}
+
+	for _, stream := range q.streams {
+		if q.hasDelivered(stream) {
+			continue
+		}
+		entries, err := q.c.ReadJobStreams(q.ctx, q.group, q.consumer, []string{stream}, -1)
+		if err != nil {
+			return nil, err
+		}
+		q.addDelivered(entries)
+	}
+	if entry, err := q.takeNextDelivered(); entry != nil || err != nil {
+		return entry, err
+	}
+	if q.isClosed() {
+		return nil, nil
+	}
+
+	// a read of several streams delivers an entry of each stream with one
+	entries, err := q.c.ReadJobStreams(q.ctx, q.group, q.consumer, q.streams, jobStreamBlockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	q.addDelivered(entries)
+	return q.takeNextDelivered()
+}
+
+// takeNextDelivered pops the entry to dequeue among the entries read ahead and renews it. An entry read ahead waits
+// pending for this consumer, once it waits for reclaimIdle another consumer may claim it and run its job, so an entry
+// that is no longer pending for this consumer is dropped.
+func (q *RedisStreamJobQueue) takeNextDelivered() (*iowrappers.JobStreamEntry, error) {
+	for {
+		entry := q.popNextDelivered()
+		if entry == nil {
+			return nil, nil
+		}
+		renewed, err := q.c.RenewJobStreamEntry(q.ctx, entry, q.group, q.consumer)
+		if err != nil {
+			q.addDelivered([]*iowrappers.JobStreamEntry{entry})
+			return nil, err
+		}
+		if renewed {
+			return entry, nil
+		}
+		iowrappers.Logger.Infof("dropping job %s of stream %s claimed by another consumer", entry.ID, entry.Stream)
+	}
+}
+
+// claimStaleEntry claims an entry pending for reclaimIdle, looking for one at most every half of reclaimIdle while
+// none is found. Claiming an entry of a job still running on this server only renews the entry.
+func (q *RedisStreamJobQueue) claimStaleEntry() (*iowrappers.JobStreamEntry, error) {
+	q.mu.Lock()
+	if time.Since(q.reclaimedAt) < q.reclaimIdle/2 {
+		q.mu.Unlock()
+		return nil, nil
+	}
+	q.reclaimedAt = time.Now()
+	q.mu.Unlock()
+
+	for _, stream := range q.streams {
+		entry, err := q.c.ClaimJobStreamEntry(q.ctx, stream, q.group, q.consumer, q.reclaimIdle)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil || q.isInflight(entry) {
+			continue
+		}
+		q.mu.Lock()
+		// keep claiming while stale entries are found
+		q.reclaimedAt = time.Time{}
+		q.mu.Unlock()
+		q.popDeliveredEntry(entry)
+		iowrappers.Logger.Infof("claimed job %s of stream %s pending for %s", entry.ID, stream, q.reclaimIdle)
+		return entry, nil
+	}
+	return nil, nil
+}
+
+func (q *RedisStreamJobQueue) isInflight(entry *iowrappers.JobStreamEntry) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, inflight := range q.inflight {
+		if inflight.Stream == entry.Stream && inflight.ID == entry.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *RedisStreamJobQueue) hasDelivered(stream string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, entry := range q.delivered {
+		if entry.Stream == stream {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *RedisStreamJobQueue) addDelivered(entries []*iowrappers.JobStreamEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.delivered = append(q.delivered, entries...)
+}
+
+// popNextDelivered removes the entry to dequeue from the entries read ahead
+func (q *RedisStreamJobQueue) popNextDelivered() *iowrappers.JobStreamEntry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	candidates := make([]*queuedJob, len(q.delivered))
+	for idx, entry := range q.delivered {
+		candidates[idx] = &queuedJob{priority: q.priorityOf(entry.Stream), enqueuedAt: entry.EnqueuedAt()}
+	}
+	now := time.Now()
+	next, promoted := nextJobIndex(candidates, now)
+	if next < 0 {
+		return nil
+	}
+	entry := q.delivered[next]
+	q.delivered = append(q.delivered[:next], q.delivered[next+1:]...)
+	q.waits.record(*candidates[next], promoted, now)
+	return entry
+}
+
+func (q *RedisStreamJobQueue) popDeliveredEntry(claimed *iowrappers.JobStreamEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for idx, entry := range q.delivered {
+		if entry.Stream == claimed.Stream && entry.ID == claimed.ID {
+			q.delivered = append(q.delivered[:idx], q.delivered[idx+1:]...)
+			return
+		}
+	}
+}
+
+// decodeJob decodes a serialized job, e.g. the job of a stream entry, with the parameters of its job type
+func decodeJob(data string) (*iowrappers.Job, error) {
+	var entry struct {
+		iowrappers.Job
+		Parameters json.RawMessage `json:"parameters"`
+	}
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		return nil, err
+	}
+	job := entry.Job
+	newParameters, ok := streamJobParameters[job.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown job type %q", job.Name)
+	}
+	parameters := newParameters()
+	if err := json.Unmarshal(entry.Parameters, parameters); err != nil {
+		return nil, err
+	}
+	job.Parameters = parameters
+	return &job, nil
+}
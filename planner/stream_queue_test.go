@@ -0,0 +1,214 @@
+package planner
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+func newTestStreamJobQueue(t *testing.T, svr *miniredis.Miniredis, consumer string, reclaimIdle time.Duration) *RedisStreamJobQueue {
+	redisURL, _ := url.Parse("redis://" + svr.Addr())
+	q, err := NewRedisStreamJobQueue(iowrappers.CreateRedisClient(redisURL), JobStreamConsumerGroup, consumer, reclaimIdle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func newTestPlanningJob(id string, priority iowrappers.JobPriority) *iowrappers.Job {
+	return &iowrappers.Job{
+		ID:         id,
+		Name:       PlanningJobType,
+		Parameters: &PlanningRequest{Location: POI.Location{City: "Paris", Country: "France"}, TravelDate: "2026-12-01"},
+		Status:     iowrappers.JobStatusNew,
+		Priority:   priority,
+	}
+}
+
+func TestRedisStreamJobQueue_shouldDequeueByPriority(t *testing.T) {
+	q := newTestStreamJobQueue(t, miniredis.RunT(t), "consumer-1", time.Minute)
+	defer q.Close()
+
+	for _, job := range []*iowrappers.Job{
+		newTestPlanningJob("low", iowrappers.JobPriorityLow),
+		newTestPlanningJob("normal", iowrappers.JobPriorityNormal),
+		newTestPlanningJob("high", iowrappers.JobPriorityHigh),
+	} {
+		if !q.Enqueue(job) {
+			t.Fatalf("failed to enqueue job %s", job.ID)
+		}
+	}
+	if high, normal, low := q.Stats(); high != 1 || normal != 1 || low != 1 {
+		t.Errorf("expected a job of each priority, got %d, %d and %d", high, normal, low)
+	}
+
+	for _, expected := range []string{"high", "normal", "low"} {
+		job := q.Dequeue()
+		if job == nil || job.ID != expected {
+			t.Fatalf("expected job %s, got %+v", expected, job)
+		}
+		req, ok := job.Parameters.(*PlanningRequest)
+		if !ok || req.Location.City != "Paris" || req.TravelDate != "2026-12-01" {
+			t.Errorf("expected the planning request of job %s, got %+v", job.ID, job.Parameters)
+		}
+		q.Ack(job)
+	}
+	if q.Len() != 0 {
+		t.Errorf("expected an empty queue, got %d jobs", q.Len())
+	}
+}
+
+func TestRedisStreamJobQueue_shouldKeepJobsAcrossRestarts(t *testing.T) {
+	svr := miniredis.RunT(t)
+	q := newTestStreamJobQueue(t, svr, "consumer-1", time.Minute)
+	if !q.Enqueue(newTestPlanningJob("queued-before-restart", iowrappers.JobPriorityLow)) {
+		t.Fatal("failed to enqueue job")
+	}
+	q.Close()
+	if q.Enqueue(newTestPlanningJob("queued-after-close", iowrappers.JobPriorityLow)) {
+		t.Error("expected a closed queue to reject jobs")
+	}
+
+	restarted := newTestStreamJobQueue(t, svr, "consumer-1", time.Minute)
+	defer restarted.Close()
+	job := restarted.Dequeue()
+	if job == nil || job.ID != "queued-before-restart" {
+		t.Fatalf("expected the job queued before the restart, got %+v", job)
+	}
+	restarted.Ack(job)
+}
+
+func TestRedisStreamJobQueue_shouldReclaimJobsOfCrashedConsumers(t *testing.T) {
+	svr := miniredis.RunT(t)
+	reclaimIdle := 20 * time.Millisecond
+	crashed := newTestStreamJobQueue(t, svr, "consumer-crashed", reclaimIdle)
+	if !crashed.Enqueue(newTestPlanningJob("unacknowledged", iowrappers.JobPriorityNormal)) {
+		t.Fatal("failed to enqueue job")
+	}
+	if job := crashed.Dequeue(); job == nil || job.ID != "unacknowledged" {
+		t.Fatalf("expected the queued job, got %+v", job)
+	}
+	if crashed.Len() != 0 {
+		t.Errorf("expected the pending job not to wait for workers, got %d jobs", crashed.Len())
+	}
+
+	time.Sleep(2 * reclaimIdle)
+	q := newTestStreamJobQueue(t, svr, "consumer-2", reclaimIdle)
+	job := q.Dequeue()
+	if job == nil || job.ID != "unacknowledged" {
+		t.Fatalf("expected the job of the crashed consumer, got %+v", job)
+	}
+	q.Ack(job)
+
+	q.Close()
+	if job = q.Dequeue(); job != nil {
+		t.Errorf("expected no job after the acknowledgement, got %+v", job)
+	}
+}
+
+func TestRedisStreamJobQueue_shouldWaitForJobs(t *testing.T) {
+	q := newTestStreamJobQueue(t, miniredis.RunT(t), "consumer-1", time.Minute)
+	defer q.Close()
+
+	dequeued := make(chan *iowrappers.Job)
+	go func() {
+		dequeued <- q.Dequeue()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if !q.Enqueue(newTestPlanningJob("late", iowrappers.JobPriorityLow)) {
+		t.Fatal("failed to enqueue job")
+	}
+
+	select {
+	case job := <-dequeued:
+		if job == nil || job.ID != "late" {
+			t.Fatalf("expected the job queued while waiting, got %+v", job)
+		}
+		q.Ack(job)
+	case <-time.After(3 * jobStreamBlockTimeout):
+		t.Fatal("timed out waiting for the job")
+	}
+}
+
+func TestRedisStreamJobQueue_shouldDropJobsOfUnknownTypes(t *testing.T) {
+	q := newTestStreamJobQueue(t, miniredis.RunT(t), "consumer-1", time.Minute)
+	unknown := &iowrappers.Job{ID: "unknown", Name: "Unknown", Priority: iowrappers.JobPriorityHigh}
+	if !q.Enqueue(unknown) || !q.Enqueue(newTestPlanningJob("known", iowrappers.JobPriorityLow)) {
+		t.Fatal("failed to enqueue jobs")
+	}
+	q.Close()
+
+	if job := q.Dequeue(); job == nil || job.ID != "known" {
+		t.Fatalf("expected the planning job, got %+v", job)
+	}
+	if job := q.Dequeue(); job != nil {
+		t.Errorf("expected no job left, got %+v", job)
+	}
+}
+
+func TestRedisStreamJobQueue_shouldPromoteWaitingJobs(t *testing.T) {
+	defaultInterval := JobAgingInterval
+	JobAgingInterval = 20 * time.Millisecond
+	defer func() { JobAgingInterval = defaultInterval }()
+
+	q := newTestStreamJobQueue(t, miniredis.RunT(t), "consumer-1", time.Minute)
+	defer q.Close()
+	if !q.Enqueue(newTestPlanningJob("low", iowrappers.JobPriorityLow)) {
+		t.Fatal("failed to enqueue job")
+	}
+	time.Sleep(2*JobAgingInterval + 10*time.Millisecond)
+	if !q.Enqueue(newTestPlanningJob("high", iowrappers.JobPriorityHigh)) {
+		t.Fatal("failed to enqueue job")
+	}
+
+	stats := q.WaitStats()
+	if stats.Low.Depth != 1 || stats.High.Depth != 1 || stats.Low.OldestWaitSeconds < 0.04 {
+		t.Errorf("expected a low priority job waiting longer than a high priority one, got %+v", stats)
+	}
+
+	job := q.Dequeue()
+	if job == nil || job.ID != "low" {
+		t.Fatalf("expected the promoted low priority job, got %+v", job)
+	}
+	q.Ack(job)
+	stats = q.WaitStats()
+	if stats.Promoted != 1 || stats.Low.Dequeued != 1 || stats.Low.Depth != 0 || stats.High.Depth != 1 {
+		t.Errorf("expected a promoted low priority job and a high priority job left, got %+v", stats)
+	}
+}
+
+func TestRedisStreamJobQueue_shouldDropJobsReadAheadAndClaimedByOtherConsumers(t *testing.T) {
+	svr := miniredis.RunT(t)
+	reclaimIdle := 20 * time.Millisecond
+	busy := newTestStreamJobQueue(t, svr, "consumer-busy", reclaimIdle)
+	for _, job := range []*iowrappers.Job{newTestPlanningJob("high", iowrappers.JobPriorityHigh), newTestPlanningJob("low", iowrappers.JobPriorityLow)} {
+		if !busy.Enqueue(job) {
+			t.Fatalf("failed to enqueue job %s", job.ID)
+		}
+	}
+	// the low priority job is read ahead with the high priority one
+	job := busy.Dequeue()
+	if job == nil || job.ID != "high" {
+		t.Fatalf("expected the high priority job, got %+v", job)
+	}
+	busy.Ack(job)
+
+	// the low priority job waits longer than reclaimIdle for a worker of the busy consumer
+	time.Sleep(2 * reclaimIdle)
+	q := newTestStreamJobQueue(t, svr, "consumer-2", reclaimIdle)
+	defer q.Close()
+	claimed := q.Dequeue()
+	if claimed == nil || claimed.ID != "low" {
+		t.Fatalf("expected the job read ahead by the busy consumer, got %+v", claimed)
+	}
+
+	busy.Close()
+	if job = busy.Dequeue(); job != nil {
+		t.Errorf("expected the job claimed by another consumer not to run twice, got %+v", job)
+	}
+	q.Ack(claimed)
+}
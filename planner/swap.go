@@ -0,0 +1,197 @@
+package planner
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+const (
+	SlotAlternativesDefault = 5
+	MaxSlotAlternatives     = 20
+)
+
+// SlotSwapRequest replaces the place of one slot of a cached plan while the places of the other slots stay fixed.
+// Category, PriceLevel and SearchRadius optionally narrow the alternatives, PlaceID picks the replacement to save.
+// Without a PriceLevel, the alternatives keep the price level of the plan.
+type SlotSwapRequest struct {
+	PlanID          string            `json:"-"`
+	SlotIndex       int               `json:"slot_index"`
+	Category        POI.PlaceCategory `json:"category"`
+	PriceLevel      *POI.PriceLevel   `json:"price_level,omitempty"`
+	SearchRadius    uint              `json:"radius"`
+	TravelMode      POI.TravelMode    `json:"travel_mode"`
+	NumAlternatives int               `json:"num_alternatives"`
+	PlaceID         string            `json:"place_id"`
+}
+
+// slotSwap is a cached plan prepared for replacing the place of one of its slots
+type slotSwap struct {
+	record      iowrappers.PlanningSolutionRecord
+	planningReq *PlanningRequest
+	// placeClusters holds the fixed place of every slot, the cluster of the swapped slot is filled in per alternative
+	placeClusters [][]matching.Place
+}
+
+// SlotAlternatives returns the best places for one slot of a cached plan, each as a complete plan with the other
+// places unchanged. Candidates go through the same matchers as regular planning and plans are ranked by the solver
+// scorer, including the distance to the fixed places of the plan.
+func (s *Solver) SlotAlternatives(ctx context.Context, req *SlotSwapRequest) *PlanningResp {
+	swap, resp := s.prepareSlotSwap(ctx, req)
+	if resp != nil {
+		return resp
+	}
+	if req.NumAlternatives <= 0 {
+		req.NumAlternatives = SlotAlternativesDefault
+	}
+	req.NumAlternatives = min(req.NumAlternatives, MaxSlotAlternatives)
+
+	slotReq := *swap.planningReq
+	slotReq.Slots = []SlotRequest{swap.planningReq.Slots[req.SlotIndex]}
+	slotReq.Slots[0].PinnedPlaceID = ""
+	// places of the plan are excluded as alternatives together with the places blocked by the user
+	slotReq.blockedPlaceIDs = append(slices.Clone(s.blockedPlaces(ctx)), swap.record.PlaceIDs...)
+	candidates, err := s.generatePlacesForSlots(ctx, &slotReq)
+	if err != nil {
+		return &PlanningResp{Err: err, ErrorCode: NoValidSolution}
+	}
+
+	solutions := make([]PlanningSolution, 0, len(candidates[0]))
+	for _, candidate := range candidates[0] {
+		if solution, err := swap.solution(candidate, s.Scorer()); err == nil {
+			solutions = append(solutions, solution)
+		}
+	}
+	if len(solutions) == 0 {
+		return &PlanningResp{Err: fmt.Errorf("no alternative fits slot %d of plan %s", req.SlotIndex, req.PlanID), ErrorCode: NoValidSolution}
+	}
+	slices.SortStableFunc(solutions, func(a, b PlanningSolution) int { return cmp.Compare(b.Score, a.Score) })
+	return &PlanningResp{Solutions: solutions[:min(req.NumAlternatives, len(solutions))]}
+}
+
+// SaveSlotSwap saves the cached plan with the place of one slot replaced by req.PlaceID as a new plan record.
+// The original plan is left unchanged.
+func (s *Solver) SaveSlotSwap(ctx context.Context, req *SlotSwapRequest) *PlanningResp {
+	if req.PlaceID == "" {
+		return &PlanningResp{Err: errors.New("place ID of the replacement cannot be empty"), ErrorCode: InvalidRequestLocation}
+	}
+	swap, resp := s.prepareSlotSwap(ctx, req)
+	if resp != nil {
+		return resp
+	}
+
+	slot := swap.planningReq.Slots[req.SlotIndex]
+	slot.PinnedPlaceID = req.PlaceID
+	place, err := s.pinnedPlace(ctx, slot)
+	if err != nil {
+		return &PlanningResp{Err: err, ErrorCode: InvalidRequestLocation}
+	}
+	if slices.Contains(swap.record.PlaceIDs, place.Id()) {
+		return &PlanningResp{Err: fmt.Errorf("place %s is already in plan %s", place.Id(), req.PlanID), ErrorCode: InvalidRequestLocation}
+	}
+	if closure, closed := swap.planningReq.closures.ClosureOf(place.Id(), place.Category); closed {
+		return &PlanningResp{Err: fmt.Errorf("place %s is closed on %s: %s", place.Id(), closure.Date, closure.Reason), ErrorCode: NoValidSolution}
+	}
+
+	solution, err := swap.solution(place, s.Scorer())
+	if err != nil {
+		return &PlanningResp{Err: err, ErrorCode: NoValidSolution}
+	}
+	record := toPlanningSolutionRecord(swap.planningReq, solution, swap.record.Destination)
+	if err = s.Searcher.GetRedisClient().SavePlanningSolutionRecord(ctx, &record); err != nil {
+		return &PlanningResp{Err: err, ErrorCode: InternalError}
+	}
+	return &PlanningResp{Solutions: []PlanningSolution{solution}}
+}
+
+// prepareSlotSwap loads the cached plan and the fixed places of the slots other than the swapped one
+func (s *Solver) prepareSlotSwap(ctx context.Context, req *SlotSwapRequest) (*slotSwap, *PlanningResp) {
+	swap := &slotSwap{}
+	redisClient := s.Searcher.GetRedisClient()
+	err := redisClient.FetchSingleRecord(ctx, strings.Join([]string{iowrappers.TravelPlanRedisCacheKeyPrefix, req.PlanID}, ":"), &swap.record)
+	if errors.Is(err, redis.Nil) {
+		return nil, &PlanningResp{Err: fmt.Errorf("plan %s does not exist", req.PlanID), ErrorCode: NoValidSolution}
+	}
+	if err != nil {
+		return nil, &PlanningResp{Err: err, ErrorCode: InternalError}
+	}
+	if req.SlotIndex < 0 || req.SlotIndex >= len(swap.record.PlaceIDs) {
+		return nil, &PlanningResp{Err: fmt.Errorf("slot index must be between 0 and %d", len(swap.record.PlaceIDs)-1), ErrorCode: InvalidRequestLocation}
+	}
+
+	if req.TravelMode, err = POI.ParseTravelMode(string(req.TravelMode)); err != nil {
+		return nil, &PlanningResp{Err: err, ErrorCode: BadRequest}
+	}
+	slots, err := toSlotRequests(swap.record)
+	if err != nil {
+		return nil, &PlanningResp{Err: err, ErrorCode: InternalError}
+	}
+	if req.Category != "" {
+		if err = validateSlotCategory(req.Category); err != nil {
+			return nil, &PlanningResp{Err: err, ErrorCode: InvalidRequestLocation}
+		}
+		slots[req.SlotIndex].Category = req.Category
+	}
+
+	swap.planningReq = &PlanningRequest{
+		Location:     swap.record.Destination,
+		Slots:        slots,
+		SearchRadius: req.SearchRadius,
+		PriceLevel:   req.priceLevel(swap.record),
+		TravelMode:   req.TravelMode,
+		TravelDate:   swap.record.TravelDate,
+		spec:         swap.record.PlanSpec,
+	}
+	// alternatives closed on the travel date of the plan are left out, plans cached before their date was recorded
+	// are checked against the weekly opening hours only
+	swap.planningReq.closures = s.closuresAt(ctx, swap.record.TravelDate, swap.record.Destination)
+	if swap.planningReq.SearchRadius == 0 {
+		swap.planningReq.SearchRadius = DefaultPlaceSearchRadius
+	}
+
+	swap.placeClusters = make([][]matching.Place, len(slots))
+	for idx := range slots {
+		if idx == req.SlotIndex {
+			continue
+		}
+		// the fixed places anchor the swapped slot in the same way as places pinned by the user
+		slots[idx].PinnedPlaceID = swap.record.PlaceIDs[idx]
+		place, err := s.pinnedPlace(ctx, slots[idx])
+		if err != nil {
+			return nil, &PlanningResp{Err: err, ErrorCode: InternalError}
+		}
+		swap.placeClusters[idx] = []matching.Place{place}
+	}
+	return swap, nil
+}
+
+// priceLevel returns the price level of the alternatives: the requested one, else the one of the plan, else the
+// default price level for plans cached before their price level was recorded
+func (req *SlotSwapRequest) priceLevel(record iowrappers.PlanningSolutionRecord) POI.PriceLevel {
+	if req.PriceLevel != nil {
+		return *req.PriceLevel
+	}
+	if record.PriceLevel != nil {
+		return *record.PriceLevel
+	}
+	return POI.PriceLevelDefault
+}
+
+// solution creates the plan with the given place in the swapped slot
+func (swap *slotSwap) solution(place matching.Place, scorer matching.Scorer) (PlanningSolution, error) {
+	placeClusters := slices.Clone(swap.placeClusters)
+	for idx := range placeClusters {
+		if placeClusters[idx] == nil {
+			placeClusters[idx] = []matching.Place{place}
+		}
+	}
+	return createPlanningSolutionCandidate(make([]int, len(placeClusters)), placeClusters, swap.planningReq, scorer)
+}
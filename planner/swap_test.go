@@ -0,0 +1,163 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/test/redis_client_mocks"
+)
+
+func TestToSlotRequests_shouldRestoreSlotsOfCachedPlan(t *testing.T) {
+	record := iowrappers.PlanningSolutionRecord{
+		ID:              "plan",
+		PlaceIDs:        []string{"a", "b"},
+		PlaceCategories: []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery},
+		Weekdays:        []string{"Saturday", "Saturday"},
+		TimeSlots:       []string{"from 10 to 12", "from 12:30 to 13:30"},
+	}
+
+	slots, err := toSlotRequests(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slots[1].Weekday != POI.DateSaturday || slots[1].Category != POI.PlaceCategoryEatery {
+		t.Errorf("unexpected slot %+v", slots[1])
+	}
+	if slots[0].TimeSlot.Slot.Start != POI.NewClockTime(10, 0) || slots[0].TimeSlot.Slot.End != POI.NewClockTime(12, 0) {
+		t.Errorf("expected the slot from 10 to 12 saved in whole hours, got %s", slots[0].TimeSlot.ToString())
+	}
+	if slots[1].TimeSlot.Slot.Start != POI.NewClockTime(12, 30) || slots[1].TimeSlot.Slot.End != POI.NewClockTime(13, 30) {
+		t.Errorf("expected the slot from 12:30 to 13:30, got %s", slots[1].TimeSlot.ToString())
+	}
+
+	record.TimeSlots = record.TimeSlots[:1]
+	if _, err = toSlotRequests(record); err == nil {
+		t.Error("expected an error for a plan with missing time slots")
+	}
+}
+
+func TestSaveSlotSwap_shouldSaveNewPlan(t *testing.T) {
+	redisURL, _ := url.Parse("redis://" + redis_client_mocks.RedisMockSvr.Addr())
+	s := &Solver{Searcher: iowrappers.CreatePoiSearcher("fake-api-key", redisURL)}
+	ctx := context.Background()
+
+	places := []POI.Place{
+		{ID: "swap-museum", Name: "Museum", Rating: 4.5, UserRatingsTotal: 500, Location: POI.Location{Latitude: 37.7880, Longitude: -122.4075}},
+		{ID: "swap-lunch", Name: "Lunch", Rating: 4.0, UserRatingsTotal: 100, Location: POI.Location{Latitude: 37.7890, Longitude: -122.4070}},
+		{ID: "swap-better-lunch", Name: "Better Lunch", Rating: 4.8, UserRatingsTotal: 900, Location: POI.Location{Latitude: 37.7885, Longitude: -122.4072}},
+	}
+	for _, place := range places {
+		placeJson, _ := json.Marshal(place)
+		if err := redis_client_mocks.RedisMockSvr.Set(iowrappers.PlaceDetailsRedisKeyPrefix+place.ID, string(placeJson)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	original := iowrappers.PlanningSolutionRecord{
+		ID:              "swap-original",
+		PlaceIDs:        []string{"swap-museum", "swap-lunch"},
+		PlaceNames:      []string{"Museum", "Lunch"},
+		PlaceCategories: []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery},
+		Weekdays:        []string{"Monday", "Monday"},
+		TimeSlots:       []string{"from 10 to 12", "from 12 to 13"},
+		Destination:     POI.Location{City: "San Francisco", Country: "United States"},
+	}
+	recordJson, _ := json.Marshal(original)
+	if err := redis_client_mocks.RedisMockSvr.Set("travel_plan:"+original.ID, string(recordJson)); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := s.SaveSlotSwap(ctx, &SlotSwapRequest{PlanID: original.ID, SlotIndex: 1, PlaceID: "swap-better-lunch"})
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	solution := resp.Solutions[0]
+	if solution.ID == original.ID || strings.Join(solution.PlaceIDS, ",") != "swap-museum,swap-better-lunch" {
+		t.Fatalf("expected a new plan with the lunch replaced, got %+v", solution)
+	}
+
+	var saved iowrappers.PlanningSolutionRecord
+	if err := s.Searcher.GetRedisClient().FetchSingleRecord(ctx, "travel_plan:"+solution.ID, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(saved.TimeSlots, ",") != strings.Join(original.TimeSlots, ",") || saved.Destination != original.Destination {
+		t.Errorf("expected the new plan to keep the slots and destination, got %+v", saved)
+	}
+	// plans cached without their price level are swapped at the default price level
+	if saved.PriceLevel == nil || *saved.PriceLevel != POI.PriceLevelDefault {
+		t.Errorf("expected the new plan to have the default price level, got %v", saved.PriceLevel)
+	}
+
+	invalid := []*SlotSwapRequest{
+		{PlanID: original.ID, SlotIndex: 2, PlaceID: "swap-better-lunch"},
+		{PlanID: original.ID, SlotIndex: 1, PlaceID: "swap-museum"},
+		{PlanID: original.ID, SlotIndex: 1},
+		{PlanID: "unknown-plan", SlotIndex: 1, PlaceID: "swap-better-lunch"},
+	}
+	for idx, req := range invalid {
+		if resp = s.SaveSlotSwap(ctx, req); resp.Err == nil {
+			t.Errorf("request %d: expected an error", idx)
+		}
+	}
+}
+
+func TestSlotSwapRequest_shouldKeepPriceLevelOfPlan(t *testing.T) {
+	planPriceLevel, requestedPriceLevel := POI.PriceLevel(POI.PriceLevelThree), POI.PriceLevel(POI.PriceLevelOne)
+	record := iowrappers.PlanningSolutionRecord{PriceLevel: &planPriceLevel}
+
+	if priceLevel := (&SlotSwapRequest{}).priceLevel(record); priceLevel != POI.PriceLevelThree {
+		t.Errorf("expected the price level of the plan, got %d", priceLevel)
+	}
+	if priceLevel := (&SlotSwapRequest{PriceLevel: &requestedPriceLevel}).priceLevel(record); priceLevel != POI.PriceLevelOne {
+		t.Errorf("expected the requested price level, got %d", priceLevel)
+	}
+	if priceLevel := (&SlotSwapRequest{}).priceLevel(iowrappers.PlanningSolutionRecord{}); priceLevel != POI.PriceLevelDefault {
+		t.Errorf("expected the default price level, got %d", priceLevel)
+	}
+}
+
+func TestSaveSlotSwap_shouldRejectPlaceClosedOnTravelDate(t *testing.T) {
+	redisURL, _ := url.Parse("redis://" + redis_client_mocks.RedisMockSvr.Addr())
+	s := &Solver{Searcher: iowrappers.CreatePoiSearcher("fake-api-key", redisURL)}
+	ctx := context.Background()
+
+	places := []POI.Place{
+		{ID: "closed-swap-museum", Name: "Museum", Rating: 4.5, UserRatingsTotal: 500, Location: POI.Location{Latitude: 37.7880, Longitude: -122.4075}},
+		{ID: "closed-swap-lunch", Name: "Lunch", Rating: 4.0, UserRatingsTotal: 100, Location: POI.Location{Latitude: 37.7890, Longitude: -122.4070}},
+		{ID: "closed-swap-better-lunch", Name: "Better Lunch", Rating: 4.8, UserRatingsTotal: 900, Location: POI.Location{Latitude: 37.7885, Longitude: -122.4072}},
+	}
+	for _, place := range places {
+		placeJson, _ := json.Marshal(place)
+		if err := redis_client_mocks.RedisMockSvr.Set(iowrappers.PlaceDetailsRedisKeyPrefix+place.ID, string(placeJson)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const travelDate = "2030-06-03"
+	closure := POI.Closure{Date: travelDate, PlaceID: "closed-swap-better-lunch", Reason: "Private event"}
+	if err := s.Searcher.GetRedisClient().SaveClosure(ctx, closure); err != nil {
+		t.Fatal(err)
+	}
+	original := iowrappers.PlanningSolutionRecord{
+		ID:              "closed-swap-original",
+		PlaceIDs:        []string{"closed-swap-museum", "closed-swap-lunch"},
+		PlaceNames:      []string{"Museum", "Lunch"},
+		PlaceCategories: []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery},
+		Weekdays:        []string{"Monday", "Monday"},
+		TimeSlots:       []string{"from 10 to 12", "from 12 to 13"},
+		Destination:     POI.Location{Latitude: 37.7749, Longitude: -122.4194, City: "San Francisco", Country: "United States"},
+		TravelDate:      travelDate,
+	}
+	recordJson, _ := json.Marshal(original)
+	if err := redis_client_mocks.RedisMockSvr.Set("travel_plan:"+original.ID, string(recordJson)); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := s.SaveSlotSwap(ctx, &SlotSwapRequest{PlanID: original.ID, SlotIndex: 1, PlaceID: "closed-swap-better-lunch"})
+	if resp.Err == nil || !strings.Contains(resp.Err.Error(), "Private event") {
+		t.Fatalf("expected the replacement closed on %s to be rejected, got %v", travelDate, resp.Err)
+	}
+}
@@ -1,6 +1,7 @@
 package planner
 
 import (
+	"fmt"
 	"regexp"
 	"slices"
 	"strconv"
@@ -61,6 +62,14 @@ func toPlaceCategories(slotRequests []SlotRequest) []POI.PlaceCategory {
 	return categories
 }
 
+// closureDate returns the travel date of a request with closures at its destination
+func closureDate(req *PlanningRequest) string {
+	if len(req.closures) == 0 {
+		return ""
+	}
+	return req.TravelDate
+}
+
 func toSolutionsSaveRequest(req *PlanningRequest, solutions []iowrappers.PlanningSolutionRecord) *iowrappers.PlanningSolutionsSaveRequest {
 	stayTimes := toTimeSlots(req.Slots)
 	intervals := make([]POI.TimeInterval, len(stayTimes))
@@ -79,6 +88,14 @@ func toSolutionsSaveRequest(req *PlanningRequest, solutions []iowrappers.Plannin
 		PlaceCategories:         toPlaceCategories(req.Slots),
 		Intervals:               intervals,
 		Weekdays:                weekdays,
+		TravelMode:              req.TravelMode,
+		PinnedPlaceIDs:          MapSlice(req.Slots, func(slot SlotRequest) string { return slot.PinnedPlaceID }),
+		LocationTypes:           MapSlice(req.Slots, func(slot SlotRequest) []POI.LocationType { return slot.LocationTypes }),
+		Keywords:                MapSlice(req.Slots, func(slot SlotRequest) string { return slot.Keyword }),
+		ExcludedPlaceIDs:        req.blockedPlaceIDs,
+		StartLocation:           req.StartLocation,
+		EndLocation:             req.EndLocation,
+		ClosureDate:             closureDate(req),
 		PlanningSolutionRecords: solutions,
 		NumPlans:                int64(req.NumPlans),
 	}
@@ -100,15 +117,6 @@ func toPriceLevel(priceLevel string) POI.PriceLevel {
 	return POI.PriceLevel(price)
 }
 
-func toPlacePlanningDetails(name string, slot SlotRequest, url string) PlacePlanningDetails {
-	return PlacePlanningDetails{
-		Name:     name,
-		Category: string(slot.Category),
-		TimeSlot: slot.TimeSlot,
-		URL:      url,
-	}
-}
-
 func slotToWeekday(slot SlotRequest) string {
 	return slot.Weekday.Name()
 }
@@ -129,13 +137,67 @@ func toPlanningSolutionRecord(request *PlanningRequest, solution PlanningSolutio
 		PlaceAddresses:  solution.PlaceAddresses,
 		PlaceURLs:       solution.PlaceURLs,
 		PlaceCategories: solution.PlaceCategories,
+		PlaceTypes:      solution.PlaceTypes,
 		Weekdays:        weekdays,
 		TimeSlots:       timeSlots,
 		Destination:     location,
 		PlanSpec:        solution.PlanSpec,
+		PriceLevel:      &request.PriceLevel,
+		TravelDate:      request.TravelDate,
+		Legs:            solution.Legs,
+		StartLeg:        solution.StartLeg,
+		EndLeg:          solution.EndLeg,
+		ScoreBreakdown:  solution.ScoreBreakdown,
+		Exclusions:      solution.Exclusions,
 	}
 }
 
+func toTripSolutionRecord(req *TripPlanningRequest, trip *TripPlan, dayRequests []*PlanningRequest) *iowrappers.TripSolutionRecord {
+	record := &iowrappers.TripSolutionRecord{
+		ID:          trip.ID,
+		StartDate:   req.StartDate,
+		Dates:       make([]string, len(trip.Days)),
+		Days:        make([]iowrappers.PlanningSolutionRecord, len(trip.Days)),
+		Score:       trip.Score,
+		Destination: req.Location,
+	}
+	for idx, day := range trip.Days {
+		record.Dates[idx] = day.Date
+		record.Days[idx] = toPlanningSolutionRecord(dayRequests[idx], day.Solution, req.Location)
+	}
+	return record
+}
+
+// toTripPlan restores a cached trip on the dates of the requested trip, the cached trip may have been planned for other
+// dates with the same weekdays
+func toTripPlan(record *iowrappers.TripSolutionRecord, dates []string) *TripPlan {
+	trip := &TripPlan{ID: record.ID, Score: record.Score, Days: make([]TripDay, len(record.Days))}
+	for idx, day := range record.Days {
+		trip.Days[idx] = TripDay{
+			Date:    dates[idx],
+			Weekday: toWeekday(dates[idx]),
+			Solution: PlanningSolution{
+				ID:              day.ID,
+				PlaceNames:      day.PlaceNames,
+				PlaceIDS:        day.PlaceIDs,
+				PlaceLocations:  day.PlaceLocations,
+				PlaceAddresses:  day.PlaceAddresses,
+				PlaceURLs:       day.PlaceURLs,
+				PlaceCategories: day.PlaceCategories,
+				PlaceTypes:      day.PlaceTypes,
+				Score:           day.Score,
+				PlanSpec:        day.PlanSpec,
+				Legs:            day.Legs,
+				StartLeg:        day.StartLeg,
+				EndLeg:          day.EndLeg,
+				ScoreBreakdown:  day.ScoreBreakdown,
+				Exclusions:      day.Exclusions,
+			},
+		}
+	}
+	return trip
+}
+
 func toLocation(city iowrappers.City) POI.Location {
 	return POI.Location{
 		Latitude:          city.Latitude,
@@ -143,6 +205,7 @@ func toLocation(city iowrappers.City) POI.Location {
 		City:              city.Name,
 		AdminAreaLevelOne: city.AdminArea1,
 		Country:           city.Country,
+		Timezone:          city.Timezone,
 	}
 }
 
@@ -165,3 +228,65 @@ func toPATView(metadata *iowrappers.TokenMetadata) PATView {
 		ExpiresAt: expiresAt,
 	}
 }
+
+// toFreeFormSolutionRecord stores a free-form plan as a regular plan record so that the plan details page can show it.
+func toFreeFormSolutionRecord(req *FreeFormPlanningRequest, plan *FreeFormPlan) iowrappers.PlanningSolutionRecord {
+	record := iowrappers.PlanningSolutionRecord{
+		ID:             plan.ID,
+		Score:          plan.Score,
+		Destination:    req.Location,
+		PriceLevel:     req.PriceLevel,
+		TravelDate:     req.TravelDate,
+		Legs:           plan.Legs,
+		ScoreBreakdown: plan.ScoreBreakdown,
+		Exclusions:     plan.Exclusions,
+	}
+	for _, stop := range plan.Stops {
+		start, _ := POI.ParseClockTime(stop.StartTime)
+		end, _ := POI.ParseClockTime(stop.EndTime)
+		timeSlot := matching.TimeSlot{Slot: POI.TimeInterval{Start: start, End: end}}
+		record.PlaceIDs = append(record.PlaceIDs, stop.PlaceID)
+		record.PlaceNames = append(record.PlaceNames, stop.PlaceName)
+		record.PlaceLocations = append(record.PlaceLocations, stop.Location)
+		record.PlaceAddresses = append(record.PlaceAddresses, stop.Address)
+		record.PlaceURLs = append(record.PlaceURLs, stop.URL)
+		record.PlaceCategories = append(record.PlaceCategories, stop.Category)
+		record.Weekdays = append(record.Weekdays, plan.Weekday.Name())
+		record.TimeSlots = append(record.TimeSlots, timeSlot.ToString())
+	}
+	return record
+}
+
+// toSlotRequests restores the slots of a cached plan from its weekday names and time slots
+func toSlotRequests(record iowrappers.PlanningSolutionRecord) ([]SlotRequest, error) {
+	if len(record.Weekdays) != len(record.PlaceIDs) || len(record.TimeSlots) != len(record.PlaceIDs) || len(record.PlaceCategories) != len(record.PlaceIDs) {
+		return nil, fmt.Errorf("plan %s has inconsistent slots", record.ID)
+	}
+
+	slots := make([]SlotRequest, len(record.PlaceIDs))
+	for idx := range slots {
+		weekday, err := toWeekdayFromName(record.Weekdays[idx])
+		if err != nil {
+			return nil, err
+		}
+		timeSlot, err := matching.ParseTimeSlot(record.TimeSlots[idx])
+		if err != nil {
+			return nil, fmt.Errorf("invalid time slot %q in plan %s", record.TimeSlots[idx], record.ID)
+		}
+		slots[idx] = SlotRequest{
+			Weekday:  weekday,
+			TimeSlot: timeSlot,
+			Category: record.PlaceCategories[idx],
+		}
+	}
+	return slots, nil
+}
+
+func toWeekdayFromName(name string) (POI.Weekday, error) {
+	for weekday := POI.DateMonday; weekday <= POI.DateSunday; weekday++ {
+		if strings.EqualFold(weekday.Name(), name) {
+			return weekday, nil
+		}
+	}
+	return POI.DateMonday, fmt.Errorf("invalid weekday %s", name)
+}
@@ -0,0 +1,191 @@
+package planner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+const (
+	MaxTripDays    = 14
+	tripDateLayout = "2006-01-02"
+)
+
+// TripPlanningRequest describes a trip of NumDays consecutive days starting at StartDate.
+// Slots is the template used for every day; the weekday of each slot is derived from the date of the day.
+type TripPlanningRequest struct {
+	Location        POI.Location   `json:"location"`
+	StartDate       string         `json:"start_date"` // yyyy-mm-dd
+	NumDays         int            `json:"num_days"`
+	Slots           []SlotRequest  `json:"slots"`
+	SearchRadius    uint           `json:"radius"`
+	PriceLevel      POI.PriceLevel `json:"price_level"`
+	PreciseLocation bool           `json:"precise_location"`
+	TravelMode      POI.TravelMode `json:"travel_mode"`
+	// StartLocation, EndLocation and LodgingPlaceID anchor every day of the trip as in PlanningRequest
+	StartLocation  *POI.Location `json:"start_location,omitempty"`
+	EndLocation    *POI.Location `json:"end_location,omitempty"`
+	LodgingPlaceID string        `json:"lodging_place_id,omitempty"`
+}
+
+type TripDay struct {
+	Date     string           `json:"date"`
+	Weekday  POI.Weekday      `json:"weekday"`
+	Solution PlanningSolution `json:"solution"`
+}
+
+type TripPlan struct {
+	ID    string    `json:"id"`
+	Days  []TripDay `json:"days"`
+	Score float64   `json:"score"`
+}
+
+type TripPlanningResp struct {
+	Trip      *TripPlan
+	Err       error
+	ErrorCode int
+}
+
+// SolveTrip plans every day of a trip in date order. Places picked for earlier days are removed from the
+// candidates of later days, so a place never appears twice in the same trip unless the user pins it on several days.
+func (s *Solver) SolveTrip(ctx context.Context, req *TripPlanningRequest) *TripPlanningResp {
+	logger := iowrappers.Logger
+	if req.NumDays <= 0 || req.NumDays > MaxTripDays {
+		return &TripPlanningResp{Err: fmt.Errorf("number of trip days must be between 1 and %d", MaxTripDays), ErrorCode: BadRequest}
+	}
+
+	dates, err := tripDates(req.StartDate, req.NumDays)
+	if err != nil {
+		return &TripPlanningResp{Err: err, ErrorCode: BadRequest}
+	}
+	if req.TravelMode, err = POI.ParseTravelMode(string(req.TravelMode)); err != nil {
+		return &TripPlanningResp{Err: err, ErrorCode: BadRequest}
+	}
+
+	if resp := s.resolveLocation(ctx, &req.Location, req.PreciseLocation); resp != nil {
+		return &TripPlanningResp{Err: resp.Err, ErrorCode: resp.ErrorCode}
+	}
+	// the lodging place is looked up once for all days
+	anchors := &PlanningRequest{StartLocation: req.StartLocation, EndLocation: req.EndLocation, LodgingPlaceID: req.LodgingPlaceID}
+	if err = s.resolveAnchors(ctx, anchors); err != nil {
+		return &TripPlanningResp{Err: err, ErrorCode: InvalidRequestLocation}
+	}
+	req.StartLocation, req.EndLocation = anchors.StartLocation, anchors.EndLocation
+
+	blockedPlaceIDs := s.blockedPlaces(ctx)
+	dayRequests := make([]*PlanningRequest, len(dates))
+	saveRequests := make([]*iowrappers.PlanningSolutionsSaveRequest, len(dates))
+	for idx, date := range dates {
+		dayRequests[idx] = tripDayRequest(req, date)
+		dayRequests[idx].blockedPlaceIDs = blockedPlaceIDs
+		dayRequests[idx].closures = s.closuresAt(ctx, date, req.Location)
+		saveRequests[idx] = toSolutionsSaveRequest(dayRequests[idx], nil)
+	}
+
+	redisClient := s.Searcher.GetRedisClient()
+	cacheKey, err := iowrappers.TravelTripsCacheKey(saveRequests)
+	if err != nil {
+		return &TripPlanningResp{Err: err, ErrorCode: InternalError}
+	}
+
+	if record, cacheErr := redisClient.TripSolution(ctx, cacheKey); cacheErr == nil && len(record.Days) == len(dates) {
+		logger.Debugf("[request_id: %s] Using cached trip %s for key %s", ctx.Value(iowrappers.ContextRequestIdKey), record.ID, cacheKey)
+		return &TripPlanningResp{Trip: toTripPlan(record, dates)}
+	}
+
+	trip := &TripPlan{ID: uuid.NewString(), Days: make([]TripDay, 0, len(dates))}
+	usedPlaces := make(map[string]bool)
+	for idx, dayReq := range dayRequests {
+		placeClusters, err := s.generatePlacesForSlots(ctx, dayReq)
+		if err != nil {
+			return &TripPlanningResp{Err: err, ErrorCode: placesErrorCode(err)}
+		}
+
+		placeClusters, err = excludePlaces(placeClusters, dayReq.Slots, usedPlaces)
+		if err != nil {
+			return &TripPlanningResp{Err: fmt.Errorf("cannot plan day %s: %w", dates[idx], err), ErrorCode: NoValidSolution}
+		}
+
+		resp := s.solvePlaceClusters(ctx, dayReq, placeClusters)
+		if resp.Err != nil {
+			return &TripPlanningResp{Err: resp.Err, ErrorCode: resp.ErrorCode}
+		}
+		if len(resp.Solutions) == 0 {
+			return &TripPlanningResp{Err: fmt.Errorf("cannot find a valid plan for day %s", dates[idx]), ErrorCode: NoValidSolution}
+		}
+
+		best := resp.Solutions[0]
+		for slotIdx, placeID := range best.PlaceIDS {
+			// pinned places stay available, e.g. the restaurant of the hotel pinned for dinner on every day
+			if dayReq.Slots[slotIdx].PinnedPlaceID == "" {
+				usedPlaces[placeID] = true
+			}
+		}
+		trip.Days = append(trip.Days, TripDay{Date: dates[idx], Weekday: toWeekday(dates[idx]), Solution: best})
+		trip.Score += best.Score
+	}
+
+	if err = redisClient.SaveTripSolution(ctx, cacheKey, toTripSolutionRecord(req, trip, dayRequests)); err != nil {
+		logger.Error(err)
+	}
+	return &TripPlanningResp{Trip: trip}
+}
+
+// tripDates returns the dates of consecutive trip days in the format of yyyy-mm-dd
+func tripDates(startDate string, numDays int) ([]string, error) {
+	start, err := time.Parse(tripDateLayout, startDate)
+	if err != nil {
+		return nil, errors.New("trip start date format must be yyyy-mm-dd")
+	}
+
+	dates := make([]string, numDays)
+	for idx := range dates {
+		dates[idx] = start.AddDate(0, 0, idx).Format(tripDateLayout)
+	}
+	return dates, nil
+}
+
+func tripDayRequest(req *TripPlanningRequest, date string) *PlanningRequest {
+	weekday := toWeekday(date)
+	dayReq := standardRequest(date, weekday, 1, req.PriceLevel)
+	if len(req.Slots) > 0 {
+		dayReq.Slots = make([]SlotRequest, len(req.Slots))
+		for idx, slot := range req.Slots {
+			slot.Weekday = weekday
+			dayReq.Slots[idx] = slot
+		}
+	}
+	dayReq.Location = req.Location
+	dayReq.SearchRadius = req.SearchRadius
+	if dayReq.SearchRadius == 0 {
+		dayReq.SearchRadius = DefaultPlaceSearchRadius
+	}
+	dayReq.PreciseLocation = req.PreciseLocation
+	dayReq.TravelMode = req.TravelMode
+	dayReq.StartLocation = req.StartLocation
+	dayReq.EndLocation = req.EndLocation
+	dayReq.LodgingPlaceID = req.LodgingPlaceID
+	return &dayReq
+}
+
+// excludePlaces removes places already used by the trip from the candidates of every slot without a pinned place
+func excludePlaces(placeClusters [][]matching.Place, slots []SlotRequest, usedPlaces map[string]bool) ([][]matching.Place, error) {
+	results := make([][]matching.Place, len(placeClusters))
+	for idx, places := range placeClusters {
+		if slots[idx].PinnedPlaceID != "" {
+			results[idx] = places
+			continue
+		}
+		results[idx] = iowrappers.Filter(places, func(place matching.Place) bool { return !usedPlaces[place.Id()] })
+		if len(results[idx]) == 0 {
+			return nil, fmt.Errorf("no unused place is left for slot %d", idx)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,121 @@
+package planner
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+func TestTripDates_shouldCrossMonthBoundary(t *testing.T) {
+	dates, err := tripDates("2024-02-28", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"2024-02-28", "2024-02-29", "2024-03-01"}
+	if !reflect.DeepEqual(dates, expected) {
+		t.Errorf("expected %v, got %v", expected, dates)
+	}
+
+	if _, err = tripDates("02/28/2024", 3); err == nil {
+		t.Error("expected an error for an invalid date format")
+	}
+}
+
+func TestTripDayRequest_shouldUseWeekdayOfEachDate(t *testing.T) {
+	req := &TripPlanningRequest{
+		Location:  POI.Location{City: "Kyoto", Country: "Japan"},
+		StartDate: "2024-05-03",
+		NumDays:   2,
+		Slots: []SlotRequest{
+			{Weekday: POI.DateMonday, TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(12, 0)}}, Category: POI.PlaceCategoryVisit},
+			{Weekday: POI.DateMonday, TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: POI.NewClockTime(12, 0), End: POI.NewClockTime(13, 0)}}, Category: POI.PlaceCategoryEatery},
+		},
+	}
+
+	// 2024-05-04 is a Saturday
+	dayReq := tripDayRequest(req, "2024-05-04")
+	for idx, slot := range dayReq.Slots {
+		if slot.Weekday != POI.DateSaturday {
+			t.Errorf("slot %d: expected weekday %d, got %d", idx, POI.DateSaturday, slot.Weekday)
+		}
+	}
+	if req.Slots[0].Weekday != POI.DateMonday {
+		t.Error("trip request slots should not be modified")
+	}
+	if dayReq.SearchRadius != DefaultPlaceSearchRadius {
+		t.Errorf("expected default search radius %d, got %d", DefaultPlaceSearchRadius, dayReq.SearchRadius)
+	}
+
+	hotel := &POI.Location{Latitude: 35.0116, Longitude: 135.7681}
+	req.StartLocation, req.EndLocation = hotel, hotel
+	if dayReq = tripDayRequest(req, "2024-05-04"); dayReq.StartLocation != hotel || dayReq.EndLocation != hotel {
+		t.Errorf("expected every day to start and end at the hotel, got %+v and %+v", dayReq.StartLocation, dayReq.EndLocation)
+	}
+}
+
+func TestExcludePlaces_shouldDropUsedPlaces(t *testing.T) {
+	clusters := [][]matching.Place{
+		{makePlace("a1", 40.7128, -74.0060), makePlace("a2", 40.7130, -74.0058)},
+		{makePlace("b1", 40.7128, -74.0060)},
+	}
+	slots := []SlotRequest{{Category: POI.PlaceCategoryVisit}, {Category: POI.PlaceCategoryEatery}}
+
+	results, err := excludePlaces(clusters, slots, map[string]bool{"a1": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results[0]) != 1 || results[0][0].Id() != "a2" {
+		t.Errorf("expected only a2 to remain in slot 0, got %v", results[0])
+	}
+
+	if _, err = excludePlaces(clusters, slots, map[string]bool{"b1": true}); err == nil {
+		t.Error("expected an error when every place of a slot has been used")
+	}
+
+	// a place pinned on an earlier day can be pinned again
+	slots[1].PinnedPlaceID = "b1"
+	results, err = excludePlaces(clusters, slots, map[string]bool{"b1": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results[1]) != 1 || results[1][0].Id() != "b1" {
+		t.Errorf("expected the pinned place to remain in slot 1, got %v", results[1])
+	}
+}
+
+func TestToTripPlan_shouldUseDatesOfRequestedTrip(t *testing.T) {
+	// a trip cached for Friday 2024-05-03 and Saturday 2024-05-04 is reused a week later
+	record := &iowrappers.TripSolutionRecord{
+		ID:        "trip",
+		StartDate: "2024-05-03",
+		Dates:     []string{"2024-05-03", "2024-05-04"},
+		Days:      []iowrappers.PlanningSolutionRecord{{ID: "day-1"}, {ID: "day-2"}},
+	}
+
+	trip := toTripPlan(record, []string{"2024-05-10", "2024-05-11"})
+	if trip.Days[0].Date != "2024-05-10" || trip.Days[1].Date != "2024-05-11" || trip.Days[1].Weekday != POI.DateSaturday {
+		t.Errorf("expected the days on the requested dates, got %+v", trip.Days)
+	}
+	if trip.Days[1].Solution.ID != "day-2" {
+		t.Errorf("expected the cached plan of each day, got %+v", trip.Days[1].Solution)
+	}
+}
+
+func TestSolveTrip_shouldRejectInvalidRequests(t *testing.T) {
+	s := &Solver{}
+	invalid := []*TripPlanningRequest{
+		{StartDate: "2024-05-06", NumDays: 0},
+		{StartDate: "2024-05-06", NumDays: MaxTripDays + 1},
+		{StartDate: "05/06/2024", NumDays: 2},
+		{StartDate: "2024-05-06", NumDays: 2, TravelMode: "teleporting"},
+	}
+	for idx, req := range invalid {
+		if resp := s.SolveTrip(context.Background(), req); resp.Err == nil || resp.ErrorCode != BadRequest {
+			t.Errorf("request %d: expected a bad request error, got %+v", idx, resp)
+		}
+	}
+}
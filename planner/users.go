@@ -419,3 +419,77 @@ func (p *MyPlanner) userFeedbackHandler(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, gin.H{"msg": "plan is updated"})
 }
+
+type blockedPlaceRequest struct {
+	PlaceID string `json:"place_id" binding:"required"`
+}
+
+// userBlockedPlacesGetHandler lists the places the user never wants to see in plans
+func (p *MyPlanner) userBlockedPlacesGetHandler(ctx *gin.Context) {
+	userView, authErr := p.UserAuthentication(ctx, user.LevelRegular)
+	if authErr != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": authErr.Error()})
+		return
+	}
+
+	if userView.Username != ctx.Param("username") {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "only logged-in users can view their blocked places"})
+		return
+	}
+
+	placeIDs, err := p.RedisClient.BlockedPlaces(ctx, userView.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"place_ids": placeIDs})
+}
+
+func (p *MyPlanner) userBlockedPlacesPostHandler(ctx *gin.Context) {
+	userView, authErr := p.UserAuthentication(ctx, user.LevelRegular)
+	if authErr != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": authErr.Error()})
+		return
+	}
+
+	if userView.Username != ctx.Param("username") {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "only logged-in users can block places"})
+		return
+	}
+
+	req := &blockedPlaceRequest{}
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := p.RedisClient.BlockPlace(ctx, userView.ID, req.PlaceID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "place is blocked"})
+}
+
+func (p *MyPlanner) userBlockedPlaceDeleteHandler(ctx *gin.Context) {
+	userView, authErr := p.UserAuthentication(ctx, user.LevelRegular)
+	if authErr != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": authErr.Error()})
+		return
+	}
+
+	if userView.Username != ctx.Param("username") {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "only logged-in users can unblock places"})
+		return
+	}
+
+	removed, err := p.RedisClient.UnblockPlace(ctx, userView.ID, ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !removed {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "place is not blocked"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "place is unblocked"})
+}
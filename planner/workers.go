@@ -2,8 +2,10 @@ package planner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/weihesdlegend/Vacation-planner/iowrappers"
@@ -18,31 +20,6 @@ type JobHandler interface {
 	JobType() string
 }
 
-// Helper functions for JobStore
-
-func (s *JobStore) shouldSkipJobExecution(jobKey string) bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	curTime := time.Now()
-	if execution, ok := s.execs[jobKey]; ok {
-		if execution.Status == iowrappers.JobStatusCreated || execution.Status == iowrappers.JobStatusRunning || execution.Status == iowrappers.JobStatusCompleted {
-			return execution.ExpiresAt.After(curTime)
-		}
-	}
-	return false
-}
-
-func (s *JobStore) updateJobExecutionStatus(jobKey string, newStatus iowrappers.JobStatus) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if _, ok := s.execs[jobKey]; ok {
-		s.execs[jobKey].Status = newStatus
-	} else {
-		return fmt.Errorf("job to be updated %s does not exist", jobKey)
-	}
-	return nil
-}
-
 func createJobRecord(ctx context.Context, job *iowrappers.Job, c *iowrappers.RedisClient) {
 	logger := iowrappers.Logger
 	if job.Status == iowrappers.JobStatusDuplicated {
@@ -55,29 +32,27 @@ func createJobRecord(ctx context.Context, job *iowrappers.Job, c *iowrappers.Red
 	}
 }
 
-// PlanningJobHandler implements JobHandler for planning solution jobs
+// PlanningJobHandler implements JobHandler for planning solution jobs. Identical planning jobs, with the same solution
+// key, run once across the servers: a worker runs a job while it holds the Redis lease of the solution key.
 type PlanningJobHandler struct {
 	solver *Solver
-	store  *JobStore
 	redis  *iowrappers.RedisClient
 }
 
 // NewPlanningJobHandler creates a new planning job handler
-func NewPlanningJobHandler(solver *Solver, store *JobStore, redis *iowrappers.RedisClient) *PlanningJobHandler {
+func NewPlanningJobHandler(solver *Solver, redis *iowrappers.RedisClient) *PlanningJobHandler {
 	return &PlanningJobHandler{
 		solver: solver,
-		store:  store,
 		redis:  redis,
 	}
 }
 
-// Execute processes a planning job
+// Execute processes a planning job and records the completed job. A failed job is recorded by the worker, as retrying
+// or, after its last attempt, as failed.
 func (h *PlanningJobHandler) Execute(ctx context.Context, job *iowrappers.Job) error {
-	defer createJobRecord(ctx, job, h.redis)
-
 	req, ok := job.Parameters.(*PlanningRequest)
 	if !ok {
-		return fmt.Errorf("invalid job parameters type for planning job")
+		return nonRetryableJobError{fmt.Errorf("invalid job parameters type for planning job")}
 	}
 
 	jobKey, err := toSolutionKey(req)
@@ -85,81 +60,193 @@ func (h *PlanningJobHandler) Execute(ctx context.Context, job *iowrappers.Job) e
 		return err
 	}
 
-	// Check for duplicate execution
-	if h.store.shouldSkipJobExecution(jobKey) {
-		job.Status = iowrappers.JobStatusDuplicated
-		return nil
-	}
-
-	// Create execution record
-	if err = h.createJobExecution(jobKey, job); err != nil {
-		job.Status = iowrappers.JobStatusFailed
+	// Skip the job while another worker runs it or after it completed
+	lease, acquired, err := h.redis.AcquireJobLease(ctx, jobKey, JobLeaseTTL)
+	if err != nil {
 		return err
 	}
-
-	// Update status to running
-	if err = h.store.updateJobExecutionStatus(jobKey, iowrappers.JobStatusRunning); err != nil {
-		job.Status = iowrappers.JobStatusFailed
-		return err
+	if !acquired {
+		job.Status = iowrappers.JobStatusDuplicated
+		return nil
 	}
 
-	// Execute solver
+	job.Status = iowrappers.JobStatusRunning
+	ctx, cancel := context.WithCancel(ctx)
+	stopRenewal := keepJobLease(ctx, h.redis, lease, cancel)
 	resp := h.solver.Solve(ctx, req)
+	stopRenewal()
+	cancel()
+
+	// the lease is released even when the job context is done
+	releaseCtx := context.WithoutCancel(ctx)
 	if resp.Err != nil {
-		job.Status = iowrappers.JobStatusFailed
-		if err = h.store.updateJobExecutionStatus(jobKey, iowrappers.JobStatusFailed); err != nil {
-			job.Status = iowrappers.JobStatusUnknown
-			return err
+		if err = h.redis.ReleaseJobLease(releaseCtx, lease, false); err != nil {
+			iowrappers.Logger.Errorf("failed to release the lease of job %s: %v", job.ID, err)
 		}
-		return resp.Err
+		return planningJobError(resp.Err, resp.ErrorCode)
 	}
 
-	// Mark completed
-	if err = h.store.updateJobExecutionStatus(jobKey, iowrappers.JobStatusCompleted); err != nil {
-		job.Status = iowrappers.JobStatusUnknown
-		return err
+	if err = h.redis.ReleaseJobLease(releaseCtx, lease, true); err != nil {
+		iowrappers.Logger.Errorf("failed to release the lease of job %s: %v", job.ID, err)
 	}
-
 	job.Status = iowrappers.JobStatusCompleted
+	job.Error = ""
+	createJobRecord(releaseCtx, job, h.redis)
 	return nil
 }
 
 // JobType returns the job type identifier
 func (h *PlanningJobHandler) JobType() string {
-	return "Planning"
+	return PlanningJobType
 }
 
-// createJobExecution creates a new job execution record
-func (h *PlanningJobHandler) createJobExecution(jobKey string, job *iowrappers.Job) error {
-	h.store.mu.Lock()
-	defer h.store.mu.Unlock()
+// keepJobLease renews a lease every third of JobLeaseTTL until stop is called. A lease that cannot be renewed may go to
+// another worker, so the job is cancelled with cancel.
+func keepJobLease(ctx context.Context, c *iowrappers.RedisClient, lease *iowrappers.JobLease, cancel context.CancelFunc) (stop func()) {
+	return repeat(ctx, JobLeaseTTL/3, func() bool {
+		if err := c.RenewJobLease(ctx, lease, JobLeaseTTL); err != nil {
+			iowrappers.Logger.Errorf("failed to renew the lease %s: %v", lease.Key, err)
+			if errors.Is(err, iowrappers.ErrJobLeaseLost) {
+				cancel()
+				return false
+			}
+		}
+		return true
+	})
+}
 
-	if _, ok := h.store.execs[jobKey]; ok {
-		return fmt.Errorf("job execution already exists: %v", jobKey)
+// watchJobCancellation checks every JobCancellationPollInterval whether a running job was cancelled and cancels the job
+// context with cancel. stop reports whether the job was cancelled.
+func watchJobCancellation(ctx context.Context, c *iowrappers.RedisClient, jobID string, cancel context.CancelFunc) (stop func() (cancelled bool)) {
+	var cancelled atomic.Bool
+	stopWatching := repeat(ctx, JobCancellationPollInterval, func() bool {
+		isCancelled, err := c.IsJobCancelled(ctx, jobID)
+		if err != nil {
+			iowrappers.Logger.Error(err)
+			return true
+		}
+		if isCancelled {
+			cancelled.Store(true)
+			cancel()
+			return false
+		}
+		return true
+	})
+	return func() bool {
+		stopWatching()
+		return cancelled.Load()
 	}
+}
 
-	h.store.execs[jobKey] = &iowrappers.JobExecution{
-		JobID:     job.ID,
-		Status:    iowrappers.JobStatusCreated,
-		ExpiresAt: time.Now().Add(iowrappers.JobExpirationTime),
+// repeat calls fn every interval until fn returns false, ctx is done or stop is called. stop returns once fn returned.
+func repeat(ctx context.Context, interval time.Duration, fn func() bool) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !fn() {
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
 	}
+}
+
+// JobLeaseTTL is how long a planning job keeps its lease without renewal, the lease of a crashed server expires
+// after it
+var JobLeaseTTL = 30 * time.Second
+
+// JobCancellationPollInterval is how often a worker checks whether the job it runs was cancelled
+var JobCancellationPollInterval = time.Second
+
+const (
+	// PlanningJobType is the job type of the plans computed in the background for other price levels
+	PlanningJobType = "Planning"
+	// AsyncPlanningJobType is the job type of planning requests submitted through the jobs API
+	AsyncPlanningJobType = "AsyncPlanning"
+	// CacheWarmingJobType is the job type of the plans of popular cities computed before their cached plans expire
+	CacheWarmingJobType = "CacheWarming"
+)
+
+// AsyncPlanningJobHandler implements JobHandler for planning requests whose callers poll the job record for the plans
+type AsyncPlanningJobHandler struct {
+	plan  func(ctx context.Context, req *PlanningRequest, user string) PlanningResponse
+	redis *iowrappers.RedisClient
+}
 
+// NewAsyncPlanningJobHandler creates a new handler planning requests with plan, e.g. MyPlanner.Planning
+func NewAsyncPlanningJobHandler(plan func(ctx context.Context, req *PlanningRequest, user string) PlanningResponse, redis *iowrappers.RedisClient) *AsyncPlanningJobHandler {
+	return &AsyncPlanningJobHandler{
+		plan:  plan,
+		redis: redis,
+	}
+}
+
+// Execute plans the request of a job and records the plans in the job record. A failed job is recorded by the worker,
+// as retrying or, after its last attempt, as failed.
+func (h *AsyncPlanningJobHandler) Execute(ctx context.Context, job *iowrappers.Job) error {
+	req, ok := job.Parameters.(*PlanningRequest)
+	if !ok {
+		return nonRetryableJobError{fmt.Errorf("invalid job parameters type for async planning job %s", job.ID)}
+	}
+
+	job.Status = iowrappers.JobStatusRunning
+	createJobRecord(ctx, job, h.redis)
+
+	ctx = context.WithValue(ctx, iowrappers.ContextRequestIdKey, job.ID)
+	resp := h.plan(ctx, req, "guest")
+	if resp.Err != nil {
+		return planningJobError(resp.Err, resp.StatusCode)
+	}
+
+	job.Status = iowrappers.JobStatusCompleted
+	job.Error = ""
+	job.Result = resp
+	createJobRecord(ctx, job, h.redis)
 	return nil
 }
 
+// JobType returns the job type identifier
+func (h *AsyncPlanningJobHandler) JobType() string {
+	return AsyncPlanningJobType
+}
+
+// planningJobError returns the error failing a planning job, the requests that cannot be planned are not retried
+func planningJobError(err error, errorCode int) error {
+	if errorCode == InvalidRequestLocation || errorCode == NoValidSolution {
+		return nonRetryableJobError{err}
+	}
+	return err
+}
+
 // GenericWorker is a flexible worker that can handle jobs using registered JobHandlers
 type GenericWorker struct {
 	idx      int
-	jobQueue *PriorityJobQueue
+	jobQueue JobQueue
+	c        *iowrappers.RedisClient // records retried jobs and keeps dead-lettered jobs
 	wg       *sync.WaitGroup
 	handlers map[string]JobHandler // Map of job type to handler
 }
 
 // NewGenericWorker creates a new generic worker
-func NewGenericWorker(idx int, jobQueue *PriorityJobQueue, wg *sync.WaitGroup) *GenericWorker {
+func NewGenericWorker(idx int, jobQueue JobQueue, c *iowrappers.RedisClient, wg *sync.WaitGroup) *GenericWorker {
 	return &GenericWorker{
 		idx:      idx,
 		jobQueue: jobQueue,
+		c:        c,
 		wg:       wg,
 		handlers: make(map[string]JobHandler),
 	}
@@ -186,17 +273,36 @@ func (w *GenericWorker) Run(ctx context.Context) {
 			// Find the appropriate handler for this job
 			handler, ok := w.handlers[job.Name]
 			if !ok {
-				logger.Errorf("worker %d: no handler registered for job type %s", w.idx, job.Name)
-				job.Status = iowrappers.JobStatusFailed
+				err := fmt.Errorf("no handler registered for job type %s", job.Name)
+				logger.Errorf("worker %d: %v", w.idx, err)
+				w.fail(ctx, job, err)
 				continue
 			}
 
-			// Execute the job using the handler
-			err := handler.Execute(ctx, job)
+			if cancelled, err := w.c.IsJobCancelled(ctx, job.ID); err != nil {
+				logger.Error(err)
+			} else if cancelled {
+				w.cancel(ctx, job)
+				continue
+			}
+
+			// Execute the job using the handler, with a context cancelled when the job is
+			job.Attempts++
+			jobCtx, cancelJob := context.WithCancel(ctx)
+			stopWatching := watchJobCancellation(jobCtx, w.c, job.ID, cancelJob)
+			err := handler.Execute(jobCtx, job)
+			cancelled := stopWatching()
+			cancelJob()
+			if err != nil && cancelled {
+				w.cancel(ctx, job)
+				continue
+			}
 			if err != nil {
 				logger.Error(err)
+				w.retry(ctx, job, err)
 				continue
 			}
+			w.jobQueue.Ack(job)
 
 			logger.Debugf("worker %d successfully handled %s job %s", w.idx, job.Name, job.ID)
 		}
@@ -204,3 +310,54 @@ func (w *GenericWorker) Run(ctx context.Context) {
 		logger.Debugf("worker %d is shutting down", w.idx)
 	}()
 }
+
+// cancel records a cancelled job and removes it from the queue
+func (w *GenericWorker) cancel(ctx context.Context, job *iowrappers.Job) {
+	iowrappers.Logger.Infof("worker %d: %s job %s is cancelled", w.idx, job.Name, job.ID)
+	job.Status = iowrappers.JobStatusCancelled
+	job.Error = ""
+	createJobRecord(ctx, job, w.c)
+	w.jobQueue.Ack(job)
+}
+
+// fail records a job that failed its last attempt and removes it from the queue
+func (w *GenericWorker) fail(ctx context.Context, job *iowrappers.Job, err error) {
+	job.Status = iowrappers.JobStatusFailed
+	job.Error = err.Error()
+	createJobRecord(ctx, job, w.c)
+	w.jobQueue.Ack(job)
+}
+
+// retry queues a failed job again after the backoff of its retry policy, or moves the job to the dead-letter store
+// once every attempt failed. A retried job is acknowledged once it is queued again, so that a durable queue hands it to
+// another worker if the server stops during the backoff.
+func (w *GenericWorker) retry(ctx context.Context, job *iowrappers.Job, err error) {
+	logger := iowrappers.Logger
+	if !isRetryable(err) {
+		w.fail(ctx, job, err)
+		return
+	}
+
+	policy := retryPolicyOf(job.Name)
+	if job.Attempts >= policy.MaxAttempts {
+		logger.Errorf("worker %d: %s job %s failed %d attempts, moving it to the dead-letter store", w.idx, job.Name, job.ID, job.Attempts)
+		if dlqErr := w.c.SaveDeadLetterJob(ctx, job, err.Error()); dlqErr != nil {
+			logger.Error(dlqErr)
+		}
+		w.fail(ctx, job, err)
+		return
+	}
+
+	backoff := policy.Backoff(job.Attempts)
+	job.Status = iowrappers.JobStatusRetrying
+	job.Error = err.Error()
+	createJobRecord(ctx, job, w.c)
+	logger.Debugf("worker %d: retrying %s job %s in %s", w.idx, job.Name, job.ID, backoff)
+	time.AfterFunc(backoff, func() {
+		if !w.jobQueue.Enqueue(job) {
+			logger.Errorf("failed to retry job %s: queue is closed", job.ID)
+			return
+		}
+		w.jobQueue.Ack(job)
+	})
+}
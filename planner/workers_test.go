@@ -0,0 +1,170 @@
+package planner
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/test/redis_client_mocks"
+)
+
+func TestPlanningJobHandler_shouldSkipJobsLeasedByOtherWorkers(t *testing.T) {
+	ctx := context.Background()
+	redisClient := redis_client_mocks.RedisClient
+	req := &PlanningRequest{Location: POI.Location{City: "Lisbon", Country: "Portugal"}, TravelDate: "2026-12-01", PriceLevel: POI.PriceLevelThree}
+	jobKey, err := toSolutionKey(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lease, acquired, err := redisClient.AcquireJobLease(ctx, jobKey, time.Minute)
+	if err != nil || !acquired {
+		t.Fatalf("failed to acquire lease: %v", err)
+	}
+	defer func() { _ = redisClient.ReleaseJobLease(ctx, lease, false) }()
+
+	// the solver is not needed by a job leased by another worker
+	handler := NewPlanningJobHandler(nil, redisClient)
+	job := &iowrappers.Job{ID: "leased-planning-job", Name: PlanningJobType, Parameters: req}
+	if err = handler.Execute(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != iowrappers.JobStatusDuplicated {
+		t.Errorf("expected a duplicated job, got %s", job.Status)
+	}
+}
+
+func TestKeepJobLease_shouldCancelJobsWithLostLeases(t *testing.T) {
+	defaultTTL := JobLeaseTTL
+	JobLeaseTTL = 30 * time.Millisecond
+	defer func() { JobLeaseTTL = defaultTTL }()
+
+	redisClient := redis_client_mocks.RedisClient
+	redis_client_mocks.RedisMockSvr.Del(iowrappers.JobLeaseRedisKeyPrefix + "lost-lease-job")
+	lease, acquired, err := redisClient.AcquireJobLease(context.Background(), "lost-lease-job", time.Minute)
+	if err != nil || !acquired {
+		t.Fatalf("failed to acquire lease: %v", err)
+	}
+	// another worker took over the lease
+	if err = redis_client_mocks.RedisMockSvr.Set(lease.Key, "other-worker"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := keepJobLease(ctx, redisClient, lease, cancel)
+	defer stop()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected the job to be cancelled")
+	}
+}
+
+// blockingJobHandler runs jobs until their context is done
+type blockingJobHandler struct {
+	started chan string
+}
+
+func (h *blockingJobHandler) Execute(ctx context.Context, job *iowrappers.Job) error {
+	h.started <- job.ID
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (h *blockingJobHandler) JobType() string {
+	return "Blocking"
+}
+
+func TestGenericWorker_shouldCancelQueuedAndRunningJobs(t *testing.T) {
+	defaultInterval := JobCancellationPollInterval
+	JobCancellationPollInterval = 10 * time.Millisecond
+	defer func() { JobCancellationPollInterval = defaultInterval }()
+
+	ctx := context.Background()
+	redisClient := redis_client_mocks.RedisClient
+	for _, id := range []string{"cancelled-queued-job", "cancelled-running-job"} {
+		redis_client_mocks.RedisMockSvr.Del(iowrappers.JobCancellationRedisKeyPrefix + id)
+	}
+	if err := redisClient.CancelJob(ctx, "cancelled-queued-job"); err != nil {
+		t.Fatal(err)
+	}
+
+	queue := NewPriorityJobQueue(10)
+	handler := &blockingJobHandler{started: make(chan string, 2)}
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	worker := NewGenericWorker(0, queue, redisClient, wg)
+	worker.RegisterHandler(handler)
+	worker.Run(ctx)
+
+	queue.Enqueue(&iowrappers.Job{ID: "cancelled-queued-job", Name: handler.JobType(), Status: iowrappers.JobStatusNew})
+	queue.Enqueue(&iowrappers.Job{ID: "cancelled-running-job", Name: handler.JobType(), Status: iowrappers.JobStatusNew})
+	select {
+	case id := <-handler.started:
+		if id != "cancelled-running-job" {
+			t.Fatalf("expected the cancelled queued job to be skipped, got %s", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the running job to start")
+	}
+	if err := redisClient.CancelJob(ctx, "cancelled-running-job"); err != nil {
+		t.Fatal(err)
+	}
+	queue.Close()
+	wg.Wait()
+
+	for _, id := range []string{"cancelled-queued-job", "cancelled-running-job"} {
+		job, err := redisClient.GetJob(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != iowrappers.JobStatusCancelled {
+			t.Errorf("expected job %s to be cancelled, got %s", id, job.Status)
+		}
+	}
+}
+
+func TestJobHandlers_shouldLeaveFailedJobsToWorker(t *testing.T) {
+	redisClient := redis_client_mocks.RedisClient
+	// the lease of a job cannot be acquired with a cancelled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	handlers := []JobHandler{NewPlanningJobHandler(nil, redisClient), NewCacheWarmingJobHandler(nil, redisClient, time.Hour)}
+	for _, handler := range handlers {
+		req := &PlanningRequest{Location: POI.Location{City: "Porto", Country: "Portugal"}, TravelDate: "2026-12-01"}
+		job := &iowrappers.Job{ID: "failed-" + handler.JobType() + "-job", Name: handler.JobType(), Status: iowrappers.JobStatusNew, Parameters: req}
+		if err := handler.Execute(ctx, job); err == nil {
+			t.Fatalf("%s: expected an error", handler.JobType())
+		}
+		if job.Status != iowrappers.JobStatusNew {
+			t.Errorf("%s: expected the failure to be left to the worker, got %s", handler.JobType(), job.Status)
+		}
+		if _, err := redisClient.GetJob(context.Background(), job.ID); err == nil {
+			t.Errorf("%s: expected the handler not to record the failed job", handler.JobType())
+		}
+	}
+}
+
+func TestGenericWorker_shouldRecordJobsWithoutHandlerAsFailed(t *testing.T) {
+	ctx := context.Background()
+	redisClient := redis_client_mocks.RedisClient
+	queue := NewPriorityJobQueue(10)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	NewGenericWorker(0, queue, redisClient, wg).Run(ctx)
+
+	queue.Enqueue(&iowrappers.Job{ID: "unknown-type-job", Name: "Unknown", Status: iowrappers.JobStatusNew})
+	queue.Close()
+	wg.Wait()
+
+	job, err := redisClient.GetJob(ctx, "unknown-type-job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != iowrappers.JobStatusFailed || job.Error != "no handler registered for job type Unknown" {
+		t.Errorf("expected a failed job, got %+v", job)
+	}
+}
@@ -0,0 +1,110 @@
+package test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+func TestClockTime_shouldUnmarshalHoursAndClockStrings(t *testing.T) {
+	cases := map[string]POI.ClockTime{
+		`10`:       POI.NewClockTime(10, 0),
+		`12.5`:     POI.NewClockTime(12, 30),
+		`"12:30"`:  POI.NewClockTime(12, 30),
+		`"9"`:      POI.NewClockTime(9, 0),
+		`"09:05"`:  POI.NewClockTime(9, 5),
+		`"2:00+1"`: POI.NewClockTime(26, 0),
+		`26`:       POI.NewClockTime(26, 0),
+	}
+	for input, expected := range cases {
+		var actual POI.ClockTime
+		if err := json.Unmarshal([]byte(input), &actual); err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if actual != expected {
+			t.Errorf("%s: expected %s, got %s", input, expected.ToString(), actual.ToString())
+		}
+	}
+
+	for _, input := range []string{`-1`, `"12:60"`, `"12:3"`, `"noon"`, `true`, `"24:00+1"`} {
+		var actual POI.ClockTime
+		if err := json.Unmarshal([]byte(input), &actual); err == nil {
+			t.Errorf("%s: expected an error, got %s", input, actual.ToString())
+		}
+	}
+}
+
+func TestClockTime_shouldMarshalWholeHoursAsNumbers(t *testing.T) {
+	interval := POI.TimeInterval{Start: POI.NewClockTime(12, 0), End: POI.NewClockTime(13, 30)}
+	data, err := json.Marshal(interval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"start":12,"end":"13:30"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+
+	// a night out running past midnight ends on the next day
+	interval = POI.TimeInterval{Start: POI.NewClockTime(22, 0), End: POI.LatestSlotEnd}
+	if data, err = json.Marshal(interval); err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"start":22,"end":"2:00+1"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+}
+
+func TestClockTime_shouldFormatTimesAfterMidnightOnNextDay(t *testing.T) {
+	cases := map[POI.ClockTime]string{
+		POI.EndOfDay:             "0:00+1",
+		POI.NewClockTime(25, 30): "1:30+1",
+		POI.LatestSlotEnd:        "2:00+1",
+	}
+	for clockTime, expected := range cases {
+		if clockTime.String() != expected {
+			t.Errorf("expected %q, got %q", expected, clockTime.String())
+		}
+		parsed, err := POI.ParseClockTime(expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed != clockTime {
+			t.Errorf("%s: expected to parse %d minutes, got %d", expected, clockTime, parsed)
+		}
+	}
+}
+
+func TestParseTimeSlot_shouldRoundTripToString(t *testing.T) {
+	for _, s := range []string{"from 10 to 12", "from 12:30 to 13:45", "from 22 to 2:00+1"} {
+		slot, err := matching.ParseTimeSlot(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slot.ToString() != s {
+			t.Errorf("expected %q, got %q", s, slot.ToString())
+		}
+	}
+	// time slots saved before times after midnight were formatted on the next day
+	if slot, err := matching.ParseTimeSlot("from 23 to 26"); err != nil || slot.Slot.End != POI.LatestSlotEnd {
+		t.Errorf("expected a slot ending at 2:00+1, got %v, %v", slot, err)
+	}
+	if _, err := matching.ParseTimeSlot("from noon to 13"); err == nil {
+		t.Error("expected an error for an invalid time slot")
+	}
+}
+
+func TestIsOpenBetween_shouldRespectOpeningMinutes(t *testing.T) {
+	place := matching.Place{Place: &POI.Place{ID: "bakery"}}
+	place.Place.SetHour(POI.DateMonday, "Monday: 9:30 AM - 5:00 PM")
+
+	early := matching.TimeInterval{Day: POI.DateMonday, Start: POI.NewClockTime(9, 0), End: POI.NewClockTime(12, 0)}
+	if place.IsOpenBetween(early, 60) {
+		t.Error("expected the place to be closed at 9:00")
+	}
+	onTime := matching.TimeInterval{Day: POI.DateMonday, Start: POI.NewClockTime(9, 30), End: POI.NewClockTime(12, 0)}
+	if !place.IsOpenBetween(onTime, 60) {
+		t.Error("expected the place to be open from 9:30")
+	}
+}
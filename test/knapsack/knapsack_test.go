@@ -23,8 +23,8 @@ func TestKnapsack(t *testing.T) {
 		places[idx] = matching.CreatePlace(p, POI.PlaceCategoryVisit)
 	}
 	budget := uint(80)
-	querystart := matching.TimeInterval{StartHour: 8, Day: POI.DateMonday, EndHour: 16}
-	timeLimit := querystart.EndHour - querystart.StartHour
+	querystart := matching.TimeInterval{Start: POI.NewClockTime(8, 0), Day: POI.DateMonday, End: POI.NewClockTime(16, 0)}
+	timeLimit := uint8(querystart.Duration() / 60)
 	result := matching.KnapsackV1(places, querystart, budget)
 	if len(result) == 0 {
 		t.Error("No result is returned.")
@@ -57,3 +57,27 @@ func TestKnapsack(t *testing.T) {
 	}
 	assert.Equal(t, "ChIJ36yUcg3xNIgRtvNioeVfK7E", result2[0].Id())
 }
+
+func TestKnapsack_shouldNotConfuseTimeAndCost(t *testing.T) {
+	newPlace := func(id string, price float64, lat float64) matching.Place {
+		return matching.Place{
+			Place: &POI.Place{
+				ID:               id,
+				LocationType:     POI.LocationTypeCafe,
+				Rating:           4.5,
+				UserRatingsTotal: 100,
+				Location:         POI.Location{Latitude: lat, Longitude: -74.0060},
+			},
+			Price: price,
+		}
+	}
+	// a state of one hour spent at a cost equal to the budget must not be mistaken for two hours spent for free
+	places := []matching.Place{newPlace("paid", 1, 40.7128), newPlace("free", 0, 40.7130)}
+	interval := matching.TimeInterval{Day: POI.DateMonday, Start: POI.NewClockTime(9, 0), End: POI.NewClockTime(11, 0)}
+
+	totalScore := func(places []matching.Place) float64 { return matching.TotalScore(places, matching.KnapsackDistanceNorm) }
+	result, totalCost, totalTimeSpent := matching.KnapsackWithScore(places, interval, 1, totalScore)
+	assert.Len(t, result, 2)
+	assert.Equal(t, uint(1), totalCost)
+	assert.Equal(t, uint8(2), totalTimeSpent)
+}
@@ -1,55 +1,148 @@
 package test
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
 )
 
-func TestParseTimeIntervals(t *testing.T) {
+func TestParseOpenIntervals(t *testing.T) {
+	endOfDay := POI.EndOfDay
 	cases := []struct {
-		name             string
-		openHour         string
-		expectedInterval POI.TimeInterval
+		name              string
+		openHour          string
+		expectedIntervals POI.OpenIntervals
 	}{
 		{
-			name:     "should parse closed correctly",
-			openHour: "Wednesday: Closed",
-			expectedInterval: POI.TimeInterval{
-				Start: 255,
-				End:   255,
-			},
+			name:              "should parse closed correctly",
+			openHour:          "Wednesday: Closed",
+			expectedIntervals: POI.OpenIntervals{},
+		},
+		{
+			name:              "should parse regular opening hours correctly",
+			openHour:          "Saturday: 10:30AM - 10:00PM",
+			expectedIntervals: POI.OpenIntervals{{Start: POI.NewClockTime(10, 30), End: POI.NewClockTime(22, 0)}},
+		},
+		{
+			name:              "should parse overnight hours correctly",
+			openHour:          "Friday: 7:30PM - 4:30AM",
+			expectedIntervals: POI.OpenIntervals{{Start: POI.NewClockTime(19, 30), End: POI.NewClockTime(28, 30)}},
 		},
 		{
-			name:     "should parse regular opening hours correctly",
-			openHour: "Saturday: 10:30AM - 10:00PM",
-			expectedInterval: POI.TimeInterval{
-				Start: 10,
-				End:   22,
+			name:              "should take the evening for a range ending after midnight",
+			openHour:          "Saturday: 10:00 – 2:00 AM",
+			expectedIntervals: POI.OpenIntervals{{Start: POI.NewClockTime(22, 0), End: POI.NewClockTime(26, 0)}},
+		},
+		{
+			name:     "should parse split hours with a shared meridiem",
+			openHour: "Monday: 11:30 AM – 2:30 PM, 5:00 – 10:00 PM",
+			expectedIntervals: POI.OpenIntervals{
+				{Start: POI.NewClockTime(11, 30), End: POI.NewClockTime(14, 30)},
+				{Start: POI.NewClockTime(17, 0), End: POI.NewClockTime(22, 0)},
 			},
 		},
 		{
-			name:     "should parse overnight hours correctly",
-			openHour: "Friday: 7:30PM - 4:30AM",
-			expectedInterval: POI.TimeInterval{
-				Start: 19,
-				End:   24,
+			name:              "should take the morning for a range ending in the afternoon",
+			openHour:          "Tuesday: 11:00 – 2:00 PM",
+			expectedIntervals: POI.OpenIntervals{{Start: POI.NewClockTime(11, 0), End: POI.NewClockTime(14, 0)}},
+		},
+		{
+			name:              "should parse open 24 hours",
+			openHour:          "Sunday: Open 24 hours",
+			expectedIntervals: POI.OpenIntervals{{Start: 0, End: endOfDay}},
+		},
+		{
+			name:     "should parse the 24-hour clock",
+			openHour: "Montag: 09:00–12:00, 14:00–18:30",
+			expectedIntervals: POI.OpenIntervals{
+				{Start: POI.NewClockTime(9, 0), End: POI.NewClockTime(12, 0)},
+				{Start: POI.NewClockTime(14, 0), End: POI.NewClockTime(18, 30)},
 			},
 		},
+		{
+			name:              "should parse hours without minutes and midnight",
+			openHour:          "Monday: 7AM-12AM",
+			expectedIntervals: POI.OpenIntervals{{Start: POI.NewClockTime(7, 0), End: endOfDay}},
+		},
+		{
+			name:              "should parse the default opening hours",
+			openHour:          POI.DefaultOpeningHours,
+			expectedIntervals: POI.OpenIntervals{{Start: POI.NewClockTime(8, 30), End: POI.NewClockTime(21, 30)}},
+		},
 	}
 	for _, tc := range cases {
 		t.Run(tc.name,
 			func(t *testing.T) {
-				actualInterval, err := POI.ParseTimeInterval(tc.openHour)
+				actualIntervals, err := POI.ParseOpenIntervals(tc.openHour)
 				if err != nil {
 					t.Fatal(err)
 				}
-				if actualInterval.Start != tc.expectedInterval.Start {
-					t.Errorf("expected expectedInterval start %d, got %d", tc.expectedInterval.Start, actualInterval.Start)
-				}
-				if actualInterval.End != tc.expectedInterval.End {
-					t.Errorf("expected expectedInterval end %d, got %d", tc.expectedInterval.End, actualInterval.End)
+				if !reflect.DeepEqual(actualIntervals, tc.expectedIntervals) {
+					t.Errorf("expected intervals %+v, got %+v", tc.expectedIntervals, actualIntervals)
 				}
 			})
 	}
+
+	for _, openHour := range []string{"", "Monday: by appointment", "Monday: 9:00 AM", "Monday: 25:00 - 26:00"} {
+		if _, err := POI.ParseOpenIntervals(openHour); err == nil {
+			t.Errorf("expected an error for %q", openHour)
+		}
+	}
+}
+
+func TestMatcherForTime_shouldMatchAnyOpenInterval(t *testing.T) {
+	place := matching.Place{Place: &POI.Place{ID: "bistro"}}
+	place.Place.SetHour(POI.DateFriday, "Friday: 11:30 AM – 2:30 PM, 5:00 – 10:00 PM")
+
+	cases := map[POI.TimeInterval]bool{
+		{Start: POI.NewClockTime(12, 0), End: POI.NewClockTime(13, 0)}:   true,
+		{Start: POI.NewClockTime(18, 0), End: POI.NewClockTime(20, 0)}:   true,
+		{Start: POI.NewClockTime(14, 0), End: POI.NewClockTime(18, 0)}:   false,
+		{Start: POI.NewClockTime(21, 30), End: POI.NewClockTime(22, 30)}: false,
+	}
+	for interval, open := range cases {
+		req := &matching.FilterRequest{
+			Places: []matching.Place{place},
+			Params: map[matching.FilterCriteria]interface{}{
+				matching.FilterByTimePeriod: matching.TimeFilterParams{Day: POI.DateFriday, TimeInterval: interval},
+			},
+		}
+		matched, err := matching.MatcherForTime{}.Match(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (len(matched) == 1) != open {
+			t.Errorf("slot %+v: expected open %v", interval, open)
+		}
+	}
+}
+
+func TestOpenIntervalsAround_shouldConsultAdjacentWeekdays(t *testing.T) {
+	place := &POI.Place{ID: "club"}
+	place.SetHour(POI.DateThursday, "Thursday: 8:00 PM – 1:00 AM")
+	place.SetHour(POI.DateFriday, "Friday: 6:00 PM – 12:00 AM")
+	place.SetHour(POI.DateSaturday, "Saturday: 12:00 – 3:00 AM, 6:00 PM – 12:00 AM")
+
+	intervals, err := place.OpenIntervalsAround(POI.DateFriday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := POI.OpenIntervals{
+		{Start: 0, End: POI.NewClockTime(1, 0)},
+		{Start: POI.NewClockTime(18, 0), End: POI.NewClockTime(27, 0)},
+		{Start: POI.NewClockTime(42, 0), End: POI.NewClockTime(48, 0)},
+	}
+	if !reflect.DeepEqual(intervals, expected) {
+		t.Errorf("expected intervals %+v, got %+v", expected, intervals)
+	}
+
+	nightOut := POI.TimeInterval{Start: POI.NewClockTime(23, 30), End: POI.LatestSlotEnd}
+	if !intervals.Include(&nightOut) {
+		t.Error("expected the club to be open until 02:00 with the hours of Saturday")
+	}
+	if thursday, _ := place.OpenIntervalsAround(POI.DateThursday); thursday.Include(&nightOut) {
+		t.Error("expected the club to close at 01:00 after Thursday")
+	}
 }
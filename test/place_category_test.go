@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
 	"googlemaps.github.io/maps"
 )
 
@@ -443,3 +444,86 @@ func TestReclassifyForCategoryKeepsAllFormerlySearchedTypes(t *testing.T) {
 		}
 	}
 }
+
+// TestMatcherForPriceRangeHandlesEveryCategory pins that only eateries are filtered on price: Google
+// reports no reliable price level for the other categories, so their places are kept at any level.
+func TestMatcherForPriceRangeHandlesEveryCategory(t *testing.T) {
+	places := []matching.Place{
+		{Place: &POI.Place{ID: "free", PriceLevel: POI.PriceLevelZero}},
+		{Place: &POI.Place{ID: "moderate", PriceLevel: POI.PriceLevelTwo}},
+	}
+	for _, cat := range POI.AllPlaceCategories {
+		got, err := matching.MatcherForPriceRange{}.Match(&matching.FilterRequest{
+			Places: places,
+			Params: map[matching.FilterCriteria]interface{}{
+				matching.FilterByPriceRange: matching.PriceRangeFilterParams{Category: cat, PriceLevel: POI.PriceLevelTwo},
+			},
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", cat, err)
+		}
+		want := len(places)
+		if cat == POI.PlaceCategoryEatery {
+			want = 1
+		}
+		if len(got) != want {
+			t.Errorf("%s: expected %d places at price level 2, got %d", cat, want, len(got))
+		}
+	}
+
+	_, err := matching.MatcherForPriceRange{}.Match(&matching.FilterRequest{
+		Places: places,
+		Params: map[matching.FilterCriteria]interface{}{
+			matching.FilterByPriceRange: matching.PriceRangeFilterParams{Category: POI.PlaceCategory("Nightlife")},
+		},
+	})
+	if err == nil {
+		t.Error("expected an error for an unknown category")
+	}
+}
+
+func TestGetStayingTimeForLocationType(t *testing.T) {
+	cases := map[POI.LocationType]POI.StayingTime{
+		POI.LocationTypeMuseum:       POI.StayingTimeLocationTypeMuseum,
+		POI.LocationTypeShoppingMall: POI.StayingTimeLocationTypeShoppingMall,
+		POI.LocationTypeSpa:          POI.StayingTimeLocationTypeSpa,
+		POI.LocationTypeLodging:      POI.StayingTimeDefault,
+		POI.LocationTypeGym:          POI.StayingTimeDefault,
+	}
+	for locationType, want := range cases {
+		if got := POI.GetStayingTimeForLocationType(locationType); got != want {
+			t.Errorf("GetStayingTimeForLocationType(%s) = %d, want %d", locationType, got, want)
+		}
+	}
+}
+
+func TestMatcherForLocationTypes(t *testing.T) {
+	places := []matching.Place{
+		{Place: &POI.Place{ID: "bakery-cafe", LocationType: POI.LocationTypeCafe, Types: []string{"bakery", "cafe", "food"}}},
+		{Place: &POI.Place{ID: "restaurant", LocationType: POI.LocationTypeRestaurant, Types: []string{"restaurant", "food"}}},
+		// cached before place types were recorded, matched on the type it was searched with
+		{Place: &POI.Place{ID: "old-bakery", LocationType: POI.LocationTypeBakery}},
+	}
+	match := func(params map[matching.FilterCriteria]interface{}) []string {
+		t.Helper()
+		got, err := matching.MatcherForLocationTypes{}.Match(&matching.FilterRequest{Places: places, Params: params})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, len(got))
+		for idx, place := range got {
+			ids[idx] = place.Id()
+		}
+		return ids
+	}
+
+	bakeries := match(map[matching.FilterCriteria]interface{}{
+		matching.FilterByLocationTypes: matching.LocationTypesFilterParams{LocationTypes: []POI.LocationType{POI.LocationTypeBakery}},
+	})
+	if len(bakeries) != 2 || bakeries[0] != "bakery-cafe" || bakeries[1] != "old-bakery" {
+		t.Errorf("expected both bakeries, got %v", bakeries)
+	}
+	if all := match(map[matching.FilterCriteria]interface{}{}); len(all) != len(places) {
+		t.Errorf("expected requests without location types to keep every place, got %v", all)
+	}
+}
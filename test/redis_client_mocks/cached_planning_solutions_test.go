@@ -2,6 +2,7 @@ package redis_client_mocks
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/go-playground/assert/v2"
@@ -14,12 +15,12 @@ func TestGetSavedPlanningSolutions_shouldReturnCorrectResults(t *testing.T) {
 		Location: POI.Location{City: "Beijing", Country: "China"},
 		Intervals: []POI.TimeInterval{
 			{
-				Start: 8,
-				End:   10,
+				Start: POI.NewClockTime(8, 0),
+				End:   POI.NewClockTime(10, 0),
 			},
 			{
-				Start: 11,
-				End:   13,
+				Start: POI.NewClockTime(11, 0),
+				End:   POI.NewClockTime(13, 0),
 			},
 		},
 		Weekdays: []POI.Weekday{
@@ -38,6 +39,16 @@ func TestGetSavedPlanningSolutions_shouldReturnCorrectResults(t *testing.T) {
 				PlaceNames: []string{"Tian Tan Park", "Yuan Ming Yuan"},
 				Weekdays:   []string{"Wednesday", "Friday"},
 				TimeSlots:  []string{"from 8 to 10", "from 11 to 13"},
+				ScoreBreakdown: &POI.ScoreBreakdown{
+					Places: []POI.PlaceScoreBreakdown{
+						{PlaceID: "1", PlaceScore: 250, ReviewCountFactor: 50, BoostFactor: 5},
+						{PlaceID: "2", PlaceScore: 160, ReviewCountFactor: 40, BoostFactor: 4},
+					},
+					AveragePlaceScore: 205,
+					TravelPenalty:     5,
+					DistancePenalty:   0.3,
+					Score:             200,
+				},
 			},
 			{
 				ID:         "33523-32533",
@@ -90,6 +101,7 @@ func TestGetSavedPlanningSolutions_shouldReturnCorrectResults(t *testing.T) {
 		assert.Equal(t, record.PlaceNames, planningSolution.PlaceNames)
 		assert.Equal(t, record.TimeSlots, planningSolution.TimeSlots)
 		assert.Equal(t, record.Weekdays, planningSolution.Weekdays)
+		assert.Equal(t, record.ScoreBreakdown, planningSolution.ScoreBreakdown)
 	}
 
 	planningSolutions, err = RedisClient.PlanningSolutions(ctx, request2)
@@ -104,3 +116,163 @@ func TestGetSavedPlanningSolutions_shouldReturnCorrectResults(t *testing.T) {
 		assert.Equal(t, record.PlaceIDs, planningSolution.PlaceIDs)
 	}
 }
+
+func TestTravelPlansCacheKey_shouldSeparatePinnedPlaces(t *testing.T) {
+	request := &iowrappers.PlanningSolutionsSaveRequest{
+		Location:        POI.Location{City: "Beijing", Country: "China"},
+		Intervals:       []POI.TimeInterval{{Start: POI.NewClockTime(8, 0), End: POI.NewClockTime(10, 0)}, {Start: POI.NewClockTime(11, 0), End: POI.NewClockTime(13, 0)}},
+		Weekdays:        []POI.Weekday{POI.DateWednesday, POI.DateWednesday},
+		PlaceCategories: []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery},
+	}
+	key, err := iowrappers.TravelPlansCacheKey(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request.PinnedPlaceIDs = []string{"", ""}
+	unpinnedKey, _ := iowrappers.TravelPlansCacheKey(request)
+	assert.Equal(t, key, unpinnedKey)
+
+	request.PinnedPlaceIDs = []string{"", "ChIJabc"}
+	pinnedKey, _ := iowrappers.TravelPlansCacheKey(request)
+	request.PinnedPlaceIDs = []string{"", "ChIJABC"}
+	otherPinnedKey, _ := iowrappers.TravelPlansCacheKey(request)
+	assert.NotEqual(t, key, pinnedKey)
+	assert.NotEqual(t, pinnedKey, otherPinnedKey)
+}
+
+func TestTravelPlansCacheKey_shouldSeparateAnchors(t *testing.T) {
+	request := &iowrappers.PlanningSolutionsSaveRequest{
+		Location:        POI.Location{City: "Beijing", Country: "China"},
+		Intervals:       []POI.TimeInterval{{Start: POI.NewClockTime(8, 0), End: POI.NewClockTime(10, 0)}, {Start: POI.NewClockTime(11, 0), End: POI.NewClockTime(13, 0)}},
+		Weekdays:        []POI.Weekday{POI.DateWednesday, POI.DateWednesday},
+		PlaceCategories: []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery},
+	}
+	key, err := iowrappers.TravelPlansCacheKey(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hotel := &POI.Location{Latitude: 39.9042, Longitude: 116.4074}
+	request.StartLocation = hotel
+	startKey, _ := iowrappers.TravelPlansCacheKey(request)
+	request.EndLocation = hotel
+	roundTripKey, _ := iowrappers.TravelPlansCacheKey(request)
+	request.EndLocation = &POI.Location{Latitude: 39.9042, Longitude: 116.4074}
+	sameRoundTripKey, _ := iowrappers.TravelPlansCacheKey(request)
+	assert.NotEqual(t, key, startKey)
+	assert.NotEqual(t, startKey, roundTripKey)
+	assert.Equal(t, roundTripKey, sameRoundTripKey)
+}
+
+func TestTravelPlansCacheKey_shouldHandleEveryPlaceCategory(t *testing.T) {
+	request := &iowrappers.PlanningSolutionsSaveRequest{
+		Location:  POI.Location{City: "Beijing", Country: "China"},
+		Intervals: []POI.TimeInterval{{Start: POI.NewClockTime(8, 0), End: POI.NewClockTime(10, 0)}},
+		Weekdays:  []POI.Weekday{POI.DateWednesday},
+	}
+	keys := make(map[string]POI.PlaceCategory)
+	for _, category := range POI.AllPlaceCategories {
+		request.PlaceCategories = []POI.PlaceCategory{category}
+		key, err := iowrappers.TravelPlansCacheKey(request)
+		if err != nil {
+			t.Fatalf("%s: %v", category, err)
+		}
+		if other, exists := keys[key]; exists {
+			t.Errorf("categories %s and %s share the cache key %s", other, category, key)
+		}
+		keys[key] = category
+	}
+
+	// keys of the original categories must stay unchanged for existing cache entries
+	request.PlaceCategories = []POI.PlaceCategory{POI.PlaceCategoryVisit}
+	key, _ := iowrappers.TravelPlansCacheKey(request)
+	assert.Equal(t, "travel_plans:china::beijing:0:v-8-10-2", key)
+
+	request.PlaceCategories = []POI.PlaceCategory{POI.PlaceCategory("Nightlife")}
+	if _, err := iowrappers.TravelPlansCacheKey(request); err == nil {
+		t.Error("expected an error for an unknown category")
+	}
+}
+
+func TestTravelPlansCacheKey_shouldSeparateSlotNarrowings(t *testing.T) {
+	request := &iowrappers.PlanningSolutionsSaveRequest{
+		Location:        POI.Location{City: "Beijing", Country: "China"},
+		Intervals:       []POI.TimeInterval{{Start: POI.NewClockTime(8, 0), End: POI.NewClockTime(10, 0)}, {Start: POI.NewClockTime(11, 0), End: POI.NewClockTime(13, 0)}},
+		Weekdays:        []POI.Weekday{POI.DateWednesday, POI.DateWednesday},
+		PlaceCategories: []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery},
+	}
+	key, err := iowrappers.TravelPlansCacheKey(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request.LocationTypes, request.Keywords = [][]POI.LocationType{nil, nil}, []string{"", ""}
+	unnarrowedKey, _ := iowrappers.TravelPlansCacheKey(request)
+	assert.Equal(t, key, unnarrowedKey)
+
+	request.LocationTypes = [][]POI.LocationType{{POI.LocationTypeMuseum, POI.LocationTypeGallery}, {POI.LocationTypeBakery}}
+	typesKey, _ := iowrappers.TravelPlansCacheKey(request)
+	request.LocationTypes = [][]POI.LocationType{{POI.LocationTypeGallery, POI.LocationTypeMuseum}, {POI.LocationTypeBakery}}
+	reorderedTypesKey, _ := iowrappers.TravelPlansCacheKey(request)
+	request.LocationTypes = [][]POI.LocationType{{POI.LocationTypeMuseum, POI.LocationTypeGallery}, {POI.LocationTypeCafe}}
+	otherTypesKey, _ := iowrappers.TravelPlansCacheKey(request)
+	assert.NotEqual(t, key, typesKey)
+	assert.Equal(t, typesKey, reorderedTypesKey)
+	assert.NotEqual(t, typesKey, otherTypesKey)
+
+	request.LocationTypes = nil
+	request.Keywords = []string{"", "Blue Bottle"}
+	keywordKey, _ := iowrappers.TravelPlansCacheKey(request)
+	request.Keywords = []string{"", "blue bottle"}
+	sameKeywordKey, _ := iowrappers.TravelPlansCacheKey(request)
+	request.Keywords = []string{"Blue Bottle", ""}
+	otherSlotKeywordKey, _ := iowrappers.TravelPlansCacheKey(request)
+	assert.NotEqual(t, key, keywordKey)
+	assert.Equal(t, keywordKey, sameKeywordKey)
+	assert.NotEqual(t, keywordKey, otherSlotKeywordKey)
+}
+
+func TestTravelPlansCacheKey_shouldEncodeMinutes(t *testing.T) {
+	request := &iowrappers.PlanningSolutionsSaveRequest{
+		Location:        POI.Location{City: "Beijing", Country: "China"},
+		PlaceCategories: []POI.PlaceCategory{POI.PlaceCategoryEatery},
+		Intervals:       []POI.TimeInterval{{Start: POI.NewClockTime(12, 30), End: POI.NewClockTime(13, 30)}},
+		Weekdays:        []POI.Weekday{POI.DateWednesday},
+	}
+	key, err := iowrappers.TravelPlansCacheKey(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "travel_plans:china::beijing:0:e-12h30-13h30-2", key)
+
+	// slots of a night out end after midnight
+	request.Intervals[0] = POI.TimeInterval{Start: POI.NewClockTime(23, 0), End: POI.LatestSlotEnd}
+	key, err = iowrappers.TravelPlansCacheKey(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "travel_plans:china::beijing:0:e-23-26-2", key)
+
+	request.Intervals[0].End = POI.LatestSlotEnd + 30
+	if _, err = iowrappers.TravelPlansCacheKey(request); err == nil {
+		t.Error("expected an error for a slot ending after the latest slot end")
+	}
+}
+
+func TestTravelPlansCacheKey_shouldSeparateDatesWithClosures(t *testing.T) {
+	request := &iowrappers.PlanningSolutionsSaveRequest{
+		Location:        POI.Location{City: "New York", Country: "United States"},
+		Intervals:       []POI.TimeInterval{{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(12, 0)}},
+		Weekdays:        []POI.Weekday{POI.DateFriday},
+		PlaceCategories: []POI.PlaceCategory{POI.PlaceCategoryVisit},
+	}
+	key, _ := iowrappers.TravelPlansCacheKey(request)
+
+	request.ClosureDate = "2026-12-25"
+	holidayKey, _ := iowrappers.TravelPlansCacheKey(request)
+	assert.NotEqual(t, key, holidayKey)
+
+	tripKey, _ := iowrappers.TravelTripsCacheKey([]*iowrappers.PlanningSolutionsSaveRequest{request, request})
+	assert.Equal(t, strings.Count(tripKey, "closed-2026-12-25"), 2)
+}
@@ -0,0 +1,52 @@
+package redis_client_mocks
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+func TestPopularCities_shouldRankCitiesByRecentVisits(t *testing.T) {
+	for _, key := range RedisMockSvr.Keys() {
+		if strings.HasPrefix(key, iowrappers.NumVisitorsPlanningAPI) {
+			RedisMockSvr.Del(key)
+		}
+	}
+
+	now := time.Date(2026, 10, 18, 15, 30, 0, 0, time.UTC)
+	events := []struct {
+		event iowrappers.PlanningEvent
+		count int
+	}{
+		{iowrappers.PlanningEvent{City: "San Francisco", AdminAreaLevelOne: "CA", Country: "USA", Timestamp: now.Format(time.RFC3339)}, 2},
+		{iowrappers.PlanningEvent{City: "San Francisco", AdminAreaLevelOne: "CA", Country: "USA", Timestamp: now.Add(-time.Hour).Format(time.RFC3339)}, 2},
+		{iowrappers.PlanningEvent{City: "Paris", Country: "France", Timestamp: now.Format(time.RFC3339)}, 3},
+		{iowrappers.PlanningEvent{City: "Lisbon", Country: "Portugal", Timestamp: now.Format(time.RFC3339)}, 1},
+		// visits before the window are not counted
+		{iowrappers.PlanningEvent{City: "Lisbon", Country: "Portugal", Timestamp: now.Add(-48 * time.Hour).Format(time.RFC3339)}, 5},
+	}
+	for _, e := range events {
+		for range e.count {
+			RedisClient.CollectPlanningAPIStats(RedisContext, e.event, 0)
+		}
+	}
+
+	cities, err := RedisClient.PopularCities(RedisContext, now.Add(-24*time.Hour), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, cities, []iowrappers.CityDemand{
+		{City: "san francisco", AdminAreaLevelOne: "ca", Country: "usa", Visits: 4},
+		{City: "paris", Country: "france", Visits: 3},
+		{City: "lisbon", Country: "portugal", Visits: 1},
+	})
+
+	cities, err = RedisClient.PopularCities(RedisContext, now.Add(-24*time.Hour), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(cities), 2)
+}
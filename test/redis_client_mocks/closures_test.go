@@ -0,0 +1,102 @@
+package redis_client_mocks
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+)
+
+func TestClosures_shouldSaveListAndRemoveClosures(t *testing.T) {
+	const date = "2030-12-25"
+	closures := []POI.Closure{
+		{Date: date, Country: "United States", Categories: []POI.PlaceCategory{POI.PlaceCategoryVisit}, Reason: "Christmas Day"},
+		{Date: date, Country: "Japan", Categories: []POI.PlaceCategory{POI.PlaceCategoryVisit}, Reason: "Museum holiday"},
+		{Date: date, PlaceID: "closure-museum", Reason: "Exhibition change"},
+		{Date: date, PlaceID: "closure-far-museum", Reason: "Renovation"},
+		{Date: date, PlaceID: "closure-unknown-museum", Reason: "Renovation"},
+	}
+	// the museum is in San Francisco, the far museum in Los Angeles
+	museums := []POI.Place{
+		{ID: "closure-museum", Name: "Museum", Location: POI.Location{Latitude: 37.8017, Longitude: -122.3984}},
+		{ID: "closure-far-museum", Name: "Far Museum", Location: POI.Location{Latitude: 34.0522, Longitude: -118.2437}},
+	}
+	for _, museum := range museums {
+		if err := RedisClient.SetPlace(RedisContext, museum); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, closure := range closures {
+		if err := RedisClient.SaveClosure(RedisContext, closure); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// replaces the holiday of the same country on the date
+	closures[0].Categories = append(closures[0].Categories, POI.PlaceCategoryShopping)
+	if err := RedisClient.SaveClosure(RedisContext, closures[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := RedisClient.Closures(RedisContext, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(all), 5)
+
+	// countries match by code, so "USA" finds the holidays of "United States", and only closed places near the
+	// destination are kept
+	sanFrancisco := POI.Location{Latitude: 37.7749, Longitude: -122.4194, City: "San Francisco", Country: "USA"}
+	atDestination, err := RedisClient.ClosuresAt(RedisContext, date, sanFrancisco)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(atDestination), 2)
+	closure, closed := atDestination.ClosureOf("some-store", POI.PlaceCategoryShopping)
+	assert.Equal(t, closed, true)
+	assert.Equal(t, closure.Reason, "Christmas Day")
+	closure, closed = atDestination.ClosureOf("closure-museum", POI.PlaceCategoryVisit)
+	assert.Equal(t, closed, true)
+	assert.Equal(t, closure.Reason, "Exhibition change")
+	_, closed = atDestination.ClosureOf("some-restaurant", POI.PlaceCategoryEatery)
+	assert.Equal(t, closed, false)
+	_, closed = atDestination.ClosureOf("closure-far-museum", POI.PlaceCategoryEatery)
+	assert.Equal(t, closed, false)
+
+	removed, err := RedisClient.RemoveClosure(RedisContext, date, closures[2].ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, removed, true)
+	removed, _ = RedisClient.RemoveClosure(RedisContext, date, closures[2].ID())
+	assert.Equal(t, removed, false)
+}
+
+func TestClosures_shouldRejectInvalidClosures(t *testing.T) {
+	invalid := []POI.Closure{
+		{Date: "12/25/2030", Country: "France", Reason: "Christmas Day"},
+		{Date: "2030-12-25", Reason: "Christmas Day"},
+		{Date: "2030-12-25", Country: "France", PlaceID: "louvre", Reason: "Christmas Day"},
+		{Date: "2030-12-25", PlaceID: "louvre", Categories: []POI.PlaceCategory{POI.PlaceCategoryVisit}, Reason: "Christmas Day"},
+		{Date: "2030-12-25", Country: "France", Categories: []POI.PlaceCategory{"Nightlife"}, Reason: "Christmas Day"},
+		{Date: "2030-12-25", Country: "France"},
+	}
+	for _, closure := range invalid {
+		if err := RedisClient.SaveClosure(RedisContext, closure); err == nil {
+			t.Errorf("expected an error for closure %+v", closure)
+		}
+	}
+}
+
+func TestLoadClosures_shouldSeedCalendarFromDataFile(t *testing.T) {
+	numClosures, err := RedisClient.LoadClosures(RedisContext, "../../data/closures.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numClosures == 0 {
+		t.Error("expected the data file to list closures")
+	}
+
+	if _, err = RedisClient.LoadClosures(RedisContext, "../../data/missing.json"); err == nil {
+		t.Error("expected an error for a missing data file")
+	}
+}
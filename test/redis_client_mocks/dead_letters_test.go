@@ -0,0 +1,47 @@
+package redis_client_mocks
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+func TestDeadLetterJobs_shouldSaveListAndRemoveJobs(t *testing.T) {
+	jobs := []*iowrappers.Job{
+		{ID: "dead-letter-1", Name: "Planning", Status: iowrappers.JobStatusFailed, Attempts: 4},
+		{ID: "dead-letter-2", Name: "AsyncPlanning", Status: iowrappers.JobStatusFailed, Attempts: 3},
+	}
+	for _, job := range jobs {
+		if err := RedisClient.SaveDeadLetterJob(RedisContext, job, "maps API unavailable"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadLetters, err := RedisClient.DeadLetterJobs(RedisContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(deadLetters), 2)
+	assert.Equal(t, deadLetters[0].Job.ID, "dead-letter-2")
+
+	deadLetter, err := RedisClient.GetDeadLetterJob(RedisContext, "dead-letter-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, deadLetter.Job.Attempts, 4)
+	assert.Equal(t, deadLetter.Error, "maps API unavailable")
+
+	removed, err := RedisClient.RemoveDeadLetterJob(RedisContext, "dead-letter-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, removed, true)
+	if _, err = RedisClient.GetDeadLetterJob(RedisContext, "dead-letter-1"); !errors.Is(err, iowrappers.ErrJobNotFound) {
+		t.Errorf("expected a removed job not to be found, got %v", err)
+	}
+	if removed, _ = RedisClient.RemoveDeadLetterJob(RedisContext, "dead-letter-1"); removed {
+		t.Error("expected a removed job not to be removed again")
+	}
+}
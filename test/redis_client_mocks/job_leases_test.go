@@ -0,0 +1,77 @@
+package redis_client_mocks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+func TestJobLeases_shouldRunJobsOnce(t *testing.T) {
+	const ttl = 30 * time.Second
+	RedisMockSvr.Del(iowrappers.JobLeaseRedisKeyPrefix + "lease-test-job")
+	lease, acquired, err := RedisClient.AcquireJobLease(RedisContext, "lease-test-job", ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, acquired, true)
+
+	// a worker of another server skips the job while the lease is held
+	_, acquired, err = RedisClient.AcquireJobLease(RedisContext, "lease-test-job", ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, acquired, false)
+
+	RedisMockSvr.FastForward(ttl / 2)
+	if err = RedisClient.RenewJobLease(RedisContext, lease, ttl); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, RedisMockSvr.TTL(lease.Key), ttl)
+
+	// a failed job frees the lease for a retry
+	if err = RedisClient.ReleaseJobLease(RedisContext, lease, false); err != nil {
+		t.Fatal(err)
+	}
+	retry, acquired, err := RedisClient.AcquireJobLease(RedisContext, "lease-test-job", ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, acquired, true)
+
+	// a completed job is not run again until its results expire
+	if err = RedisClient.ReleaseJobLease(RedisContext, retry, true); err != nil {
+		t.Fatal(err)
+	}
+	_, acquired, _ = RedisClient.AcquireJobLease(RedisContext, "lease-test-job", ttl)
+	assert.Equal(t, acquired, false)
+	assert.Equal(t, RedisMockSvr.TTL(retry.Key), iowrappers.JobExpirationTime)
+}
+
+func TestJobLeases_shouldNotReleaseLeasesOfOtherWorkers(t *testing.T) {
+	const ttl = 30 * time.Second
+	RedisMockSvr.Del(iowrappers.JobLeaseRedisKeyPrefix + "lease-test-expired-job")
+	expired, acquired, err := RedisClient.AcquireJobLease(RedisContext, "lease-test-expired-job", ttl)
+	if err != nil || !acquired {
+		t.Fatalf("failed to acquire lease: %v", err)
+	}
+
+	// the lease of a stalled worker expires and goes to another worker
+	RedisMockSvr.FastForward(ttl + time.Second)
+	current, acquired, err := RedisClient.AcquireJobLease(RedisContext, "lease-test-expired-job", ttl)
+	if err != nil || !acquired {
+		t.Fatalf("failed to acquire expired lease: %v", err)
+	}
+
+	if err = RedisClient.RenewJobLease(RedisContext, expired, ttl); !errors.Is(err, iowrappers.ErrJobLeaseLost) {
+		t.Errorf("expected the stalled worker to lose the lease, got %v", err)
+	}
+	if err = RedisClient.ReleaseJobLease(RedisContext, expired, false); !errors.Is(err, iowrappers.ErrJobLeaseLost) {
+		t.Errorf("expected the stalled worker not to release the lease, got %v", err)
+	}
+	if err = RedisClient.RenewJobLease(RedisContext, current, ttl); err != nil {
+		t.Errorf("expected the lease of the current worker to be kept, got %v", err)
+	}
+}
@@ -12,7 +12,7 @@ var TestReverseIndex = &iowrappers.ReverseIndex{
 	Metadata: &iowrappers.IndexMetadata{
 		Location: POI.Location{City: "Beijing", Country: "China"},
 		Weekday:  POI.DateSaturday,
-		Interval: POI.TimeInterval{Start: 10, End: 13},
+		Interval: POI.TimeInterval{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(13, 0)},
 	},
 	Scores:   []float64{7.2, 5.6, 6.8},
 	PlaceIDs: []string{"Summer Palace", "Art Museum", "Peking Restaurant"},
@@ -0,0 +1,79 @@
+package redis_client_mocks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+func tripDaySaveRequest(weekday POI.Weekday) *iowrappers.PlanningSolutionsSaveRequest {
+	return &iowrappers.PlanningSolutionsSaveRequest{
+		Location:        POI.Location{City: "Kyoto", AdminAreaLevelOne: "Kyoto", Country: "Japan"},
+		PriceLevel:      POI.PriceLevelTwo,
+		Intervals:       []POI.TimeInterval{{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(12, 0)}, {Start: POI.NewClockTime(12, 0), End: POI.NewClockTime(13, 0)}},
+		Weekdays:        []POI.Weekday{weekday, weekday},
+		PlaceCategories: []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery},
+	}
+}
+
+func TestTravelTripsCacheKey_shouldDependOnEveryDay(t *testing.T) {
+	twoDays := []*iowrappers.PlanningSolutionsSaveRequest{tripDaySaveRequest(POI.DateFriday), tripDaySaveRequest(POI.DateSaturday)}
+	key, err := iowrappers.TravelTripsCacheKey(twoDays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.HasPrefix(key, iowrappers.TravelTripsRedisCacheKeyPrefix+":japan:kyoto:kyoto:2:2:"), true)
+
+	otherKey, err := iowrappers.TravelTripsCacheKey([]*iowrappers.PlanningSolutionsSaveRequest{tripDaySaveRequest(POI.DateSaturday), tripDaySaveRequest(POI.DateSunday)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, key, otherKey)
+
+	if _, err = iowrappers.TravelTripsCacheKey(nil); err == nil {
+		t.Error("expected an error for a trip without days")
+	}
+}
+
+func TestSaveTripSolution_shouldReturnSavedTrip(t *testing.T) {
+	days := []*iowrappers.PlanningSolutionsSaveRequest{tripDaySaveRequest(POI.DateFriday), tripDaySaveRequest(POI.DateSaturday)}
+	key, err := iowrappers.TravelTripsCacheKey(days)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = RedisClient.TripSolution(RedisContext, key); err == nil {
+		t.Fatal("expected a cache miss before the trip is saved")
+	}
+
+	trip := &iowrappers.TripSolutionRecord{
+		ID:        "trip-1",
+		StartDate: "2024-05-03",
+		Dates:     []string{"2024-05-03", "2024-05-04"},
+		Days: []iowrappers.PlanningSolutionRecord{
+			{ID: "day-1", PlaceIDs: []string{"1", "2"}, Score: 10},
+			{ID: "day-2", PlaceIDs: []string{"3", "4"}, Score: 8},
+		},
+		Score: 18,
+	}
+	if err = RedisClient.SaveTripSolution(RedisContext, key, trip); err != nil {
+		t.Fatal(err)
+	}
+
+	cached, err := RedisClient.TripSolution(RedisContext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, cached.ID, trip.ID)
+	assert.Equal(t, cached.Dates, trip.Dates)
+	assert.Equal(t, len(cached.Days), 2)
+
+	day := &iowrappers.PlanningSolutionRecord{}
+	if err = RedisClient.FetchSingleRecord(RedisContext, iowrappers.TravelPlanRedisCacheKeyPrefix+":day-2", day); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, day.PlaceIDs, []string{"3", "4"})
+}
@@ -0,0 +1,45 @@
+package redis_client_mocks
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestUserBlockedPlaces_shouldAddListAndRemovePlaces(t *testing.T) {
+	const userID = "blocklist_user"
+
+	for _, placeID := range []string{"place-b", "place-a", "place-b"} {
+		if err := RedisClient.BlockPlace(RedisContext, userID, placeID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	placeIDs, err := RedisClient.BlockedPlaces(RedisContext, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, placeIDs, []string{"place-a", "place-b"})
+
+	removed, err := RedisClient.UnblockPlace(RedisContext, userID, "place-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, removed, true)
+
+	removed, err = RedisClient.UnblockPlace(RedisContext, userID, "place-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, removed, false)
+
+	placeIDs, _ = RedisClient.BlockedPlaces(RedisContext, userID)
+	assert.Equal(t, placeIDs, []string{"place-b"})
+
+	otherUserPlaceIDs, _ := RedisClient.BlockedPlaces(RedisContext, "another_user")
+	assert.Equal(t, len(otherUserPlaceIDs), 0)
+
+	if err = RedisClient.BlockPlace(RedisContext, userID, ""); err == nil {
+		t.Error("expected an error for an empty place ID")
+	}
+}
@@ -30,7 +30,7 @@ func TestSolutionCandidateSelection(t *testing.T) {
 		t.Fatal(err)
 	}
 	topSolutionsCount := 100
-	res := s.FindBestPlanningSolutions(context.Background(), clusters, planner.MaxSolutionsToSaveCount, iterator, planner.DefaultPlaceSearchRadius, "test-spec")
+	res := s.FindBestPlanningSolutions(context.Background(), clusters, planner.MaxSolutionsToSaveCount, iterator, &planner.PlanningRequest{SearchRadius: planner.DefaultPlaceSearchRadius})
 	if err != nil {
 		t.Fatal(err)
 	}
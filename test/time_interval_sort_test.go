@@ -9,16 +9,16 @@ import (
 
 func TestTimeIntervalSort(t *testing.T) {
 	interval1 := POI.TimeInterval{
-		Start: 15,
-		End:   20,
+		Start: POI.NewClockTime(15, 0),
+		End:   POI.NewClockTime(20, 0),
 	}
 	interval2 := POI.TimeInterval{
-		Start: 13,
-		End:   16,
+		Start: POI.NewClockTime(13, 0),
+		End:   POI.NewClockTime(16, 0),
 	}
 	interval3 := POI.TimeInterval{
-		Start: 12,
-		End:   20,
+		Start: POI.NewClockTime(12, 0),
+		End:   POI.NewClockTime(20, 0),
 	}
 
 	intervals := []POI.TimeInterval{interval1, interval2, interval3}
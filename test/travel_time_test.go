@@ -0,0 +1,62 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+// about 330 meters apart in straight line
+var unionSquare = POI.Location{Latitude: 37.7880, Longitude: -122.4075}
+var chinatownGate = POI.Location{Latitude: 37.7906, Longitude: -122.4058}
+
+var sanJose = POI.Location{Latitude: 37.3382, Longitude: -121.8863}
+
+func TestEstimateTravelLeg(t *testing.T) {
+	walk := POI.EstimateTravelLeg(unionSquare, sanJose, POI.TravelModeWalking)
+	drive := POI.EstimateTravelLeg(unionSquare, sanJose, POI.TravelModeDriving)
+	transit := POI.EstimateTravelLeg(unionSquare, sanJose, POI.TravelModeTransit)
+
+	assert.Equal(t, walk.Distance, drive.Distance)
+	assert.Greater(t, walk.Minutes, drive.Minutes)
+	assert.Greater(t, transit.Minutes, drive.Minutes)
+
+	auto := POI.EstimateTravelLeg(unionSquare, chinatownGate, POI.TravelModeAuto)
+	assert.Equal(t, POI.TravelModeWalking, auto.Mode)
+	assert.Equal(t, POI.TravelModeDriving, POI.EstimateTravelLeg(unionSquare, sanJose, POI.TravelModeAuto).Mode)
+
+	_, err := POI.ParseTravelMode("teleport")
+	assert.Error(t, err)
+}
+
+func TestFitsTimeSlots(t *testing.T) {
+	slots := []matching.TimeSlot{
+		{Slot: POI.TimeInterval{Start: POI.NewClockTime(10, 0), End: POI.NewClockTime(12, 0)}},
+		{Slot: POI.TimeInterval{Start: POI.NewClockTime(12, 0), End: POI.NewClockTime(13, 0)}},
+	}
+
+	// leaving at 11:00 after half of the first slot, must arrive by 12:30
+	assert.True(t, matching.FitsTimeSlots([]POI.TravelLeg{{Minutes: 90}}, slots))
+	assert.False(t, matching.FitsTimeSlots([]POI.TravelLeg{{Minutes: 91}}, slots))
+
+	// slots without matching legs are not validated
+	assert.True(t, matching.FitsTimeSlots(nil, slots))
+}
+
+func TestScoreWithTravel_shouldPreferShorterTravel(t *testing.T) {
+	near := []matching.Place{
+		matching.CreatePlace(POI.Place{Rating: 4.5, UserRatingsTotal: 500, Location: unionSquare}, POI.PlaceCategoryVisit),
+		matching.CreatePlace(POI.Place{Rating: 4.5, UserRatingsTotal: 500, Location: chinatownGate}, POI.PlaceCategoryEatery),
+	}
+	far := []matching.Place{
+		near[0],
+		matching.CreatePlace(POI.Place{Rating: 4.5, UserRatingsTotal: 500, Location: sanJose}, POI.PlaceCategoryEatery),
+	}
+
+	nearScore := matching.ScoreWithTravel(near, matching.TravelLegs(near, POI.TravelModeAuto), 20000)
+	farScore := matching.ScoreWithTravel(far, matching.TravelLegs(far, POI.TravelModeAuto), 20000)
+	assert.Greater(t, nearScore, farScore)
+	assert.Equal(t, matching.PlaceScore(near[0]), matching.ScoreWithTravel(near[:1], nil, 20000))
+}